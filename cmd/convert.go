@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	convertTo     string
+	convertOutput string
+)
+
+// convertCmd converts a workflow definition between the TOML, YAML and JSON formats.
+var convertCmd = &cobra.Command{
+	Use:   "convert <workflow | path | ->",
+	Short: "Convert a workflow between TOML, YAML and JSON",
+	Long:  "Convert a workflow definition to another format, writing to stdout or to the file given with --output",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workflowName := args[0]
+
+		format, err := dag.ParseFormat(convertTo)
+		if err != nil {
+			return err
+		}
+
+		d, err := dag.Resolve(workflowName)
+		if err != nil {
+			logger.L().Error("failed to load workflow", zap.String("workflow", workflowName), zap.Error(err))
+			return fmt.Errorf("failed to load workflow %s: %w", workflowName, err)
+		}
+
+		data, err := d.Encode(format)
+		if err != nil {
+			logger.L().Error("failed to encode workflow", zap.String("workflow", workflowName), zap.String("format", string(format)), zap.Error(err))
+			return fmt.Errorf("failed to encode workflow as %s: %w", format, err)
+		}
+
+		if convertOutput == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+
+		if err := os.WriteFile(convertOutput, data, 0644); err != nil {
+			logger.L().Error("failed to write converted workflow", zap.String("path", convertOutput), zap.Error(err))
+			return fmt.Errorf("failed to write %s: %w", convertOutput, err)
+		}
+
+		logger.L().Info("workflow converted",
+			zap.String("workflow", d.Name),
+			zap.String("format", string(format)),
+			zap.String("output", convertOutput),
+		)
+		fmt.Printf("✓ Converted %s to %s: %s\n", d.Name, format, convertOutput)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringVar(&convertTo, "to", "", "Target format: toml, yaml, or json")
+	convertCmd.Flags().StringVarP(&convertOutput, "output", "o", "", "Write to a file instead of stdout")
+	convertCmd.MarkFlagRequired("to")
+}
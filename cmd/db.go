@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/joelfokou/workflow/internal/config"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	dbStatusJSON bool
+)
+
+// dbCmd groups commands that manage the run database.
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the run database",
+	Long: `Inspect and upgrade the schema of the SQLite database that records workflow runs.
+
+Other commands migrate the database automatically when it is opened; use these commands to
+check what a new version of wf will change, or to migrate ahead of time.`,
+}
+
+// dbMigrateCmd applies pending schema migrations, backing up the database first.
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending database migrations",
+	Long: `Apply every pending schema migration in order, each in its own transaction.
+An existing database is copied to <database>.v<version>-<timestamp>-<pid>.bak before it is changed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openRunDatabase()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		result, err := store.Migrate()
+		if err != nil {
+			logger.L().Error("failed to migrate database", zap.String("path", store.Path()), zap.Error(err))
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+
+		if len(result.Applied) == 0 {
+			fmt.Printf("✓ Database is up to date (version %d)\n", result.To)
+			return nil
+		}
+
+		for _, m := range result.Applied {
+			fmt.Printf("  %d  %s\n", m.Version, m.Description)
+		}
+		fmt.Printf("✓ Migrated database from version %d to %d\n", result.From, result.To)
+		if result.Backup != "" {
+			fmt.Printf("  Backup: %s\n", result.Backup)
+		}
+
+		logger.L().Info("database migrated",
+			zap.String("path", store.Path()),
+			zap.Int("from", result.From),
+			zap.Int("to", result.To),
+			zap.String("backup", result.Backup),
+		)
+
+		return nil
+	},
+}
+
+// dbStatusResult is the JSON form of wf db status.
+type dbStatusResult struct {
+	Database   string                `json:"database"`
+	Version    int                   `json:"version"`
+	Latest     int                   `json:"latest"`
+	Migrations []run.MigrationStatus `json:"migrations"`
+}
+
+// dbStatusCmd reports the schema version of the database and any pending migrations.
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the database schema version and pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openRunDatabase()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		version, err := store.SchemaVersion()
+		if err != nil {
+			return fmt.Errorf("failed to read schema version: %w", err)
+		}
+		migrations, err := store.Migrations()
+		if err != nil {
+			return fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+
+		result := dbStatusResult{
+			Database:   store.Path(),
+			Version:    version,
+			Latest:     run.LatestSchemaVersion(),
+			Migrations: migrations,
+		}
+
+		if dbStatusJSON {
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "VERSION\tDESCRIPTION\tAPPLIED AT\n")
+		fmt.Fprintf(w, "-------\t-----------\t----------\n")
+
+		pending := 0
+		for _, m := range migrations {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Format("2006-01-02 15:04:05")
+			} else {
+				pending++
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Description, applied)
+		}
+		w.Flush()
+
+		fmt.Printf("\nDatabase: %s\n", result.Database)
+		switch {
+		case version > result.Latest:
+			fmt.Printf("✗ Schema version %d is newer than this wf supports (%d); upgrade wf\n", version, result.Latest)
+		case pending > 0:
+			fmt.Printf("Schema version %d of %d: %d migration(s) pending, run 'wf db migrate'\n", version, result.Latest, pending)
+		default:
+			fmt.Printf("✓ Schema version %d is up to date\n", version)
+		}
+
+		return nil
+	},
+}
+
+// openRunDatabase opens the configured run database without migrating it. Only the
+// sqlite backend has a schema to manage.
+func openRunDatabase() (*run.Store, error) {
+	if backend := run.Backend(config.C.Storage.Backend); backend != "" && backend != run.BackendSQLite {
+		return nil, fmt.Errorf("wf db manages SQLite databases, but storage.backend is %q", backend)
+	}
+
+	dbPath := config.C.Paths.Database
+	store, err := run.OpenStore(dbPath)
+	if err != nil {
+		logger.L().Error("failed to open database", zap.String("path", dbPath), zap.Error(err))
+		return nil, fmt.Errorf("failed to open database %s: %w", dbPath, err)
+	}
+	return store, nil
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+
+	dbStatusCmd.Flags().BoolVar(&dbStatusJSON, "json", false, "Output in JSON format")
+}
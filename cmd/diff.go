@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	diffRev  string
+	diffJSON bool
+)
+
+// diffOutput is the JSON representation of a definition diff.
+type diffOutput struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	*dag.Diff
+}
+
+// diffCmd compares workflow definitions between two runs, or between a git revision and the working tree.
+var diffCmd = &cobra.Command{
+	Use:   "diff <run_a> <run_b> | diff <workflow> [--rev <revision>]",
+	Short: "Compare workflow definitions between runs or revisions",
+	Long: `Compare workflow definitions task by task.
+
+With two run IDs, the definition snapshots stored with each run are compared.
+With a single workflow, the working tree definition is compared with the same
+file at a git revision (HEAD by default).`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			from, to         *dag.DAG
+			fromName, toName string
+			err              error
+		)
+
+		if len(args) == 2 {
+			if cmd.Flags().Changed("rev") {
+				return fmt.Errorf("--rev cannot be used when comparing two runs")
+			}
+			from, fromName, to, toName, err = loadRunDefinitions(args[0], args[1])
+		} else {
+			from, fromName, to, toName, err = loadRevisionDefinitions(args[0], diffRev)
+		}
+		if err != nil {
+			return err
+		}
+
+		diff, err := dag.Compare(from, to)
+		if err != nil {
+			logger.L().Error("failed to compare definitions", zap.Error(err))
+			return fmt.Errorf("failed to compare definitions: %w", err)
+		}
+
+		logger.L().Info("compared workflow definitions",
+			zap.String("from", fromName),
+			zap.String("to", toName),
+			zap.Bool("changed", !diff.Empty()),
+		)
+
+		if diffJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(diffOutput{From: fromName, To: toName, Diff: diff})
+		}
+
+		printDiff(diff, fromName, toName)
+		return nil
+	},
+}
+
+// loadRunDefinitions loads the definition snapshots stored with two runs.
+func loadRunDefinitions(runA, runB string) (*dag.DAG, string, *dag.DAG, string, error) {
+	store, err := openRunStore()
+	if err != nil {
+		return nil, "", nil, "", err
+	}
+	defer store.Close()
+
+	var (
+		dags [2]*dag.DAG
+		ids  [2]string
+	)
+	for i, ref := range []string{runA, runB} {
+		wr, err := loadRun(store, ref)
+		if err != nil {
+			return nil, "", nil, "", err
+		}
+		if !wr.Definition.Valid {
+			return nil, "", nil, "", fmt.Errorf("run '%s' has no stored definition (recorded by an earlier version)", wr.ID)
+		}
+		ids[i] = wr.ID
+
+		d, err := dag.FromSnapshot([]byte(wr.Definition.String))
+		if err != nil {
+			return nil, "", nil, "", err
+		}
+		dags[i] = d
+	}
+
+	return dags[0], "run " + ids[0], dags[1], "run " + ids[1], nil
+}
+
+// loadRevisionDefinitions loads a workflow from the working tree and from a git revision.
+func loadRevisionDefinitions(ref, rev string) (*dag.DAG, string, *dag.DAG, string, error) {
+	current, err := dag.Resolve(ref)
+	if err != nil {
+		logger.L().Error("failed to load workflow", zap.String("workflow", ref), zap.Error(err))
+		return nil, "", nil, "", fmt.Errorf("failed to load workflow %s: %w", ref, err)
+	}
+	if current.Source == "" || current.Source == dag.StdinSource {
+		return nil, "", nil, "", fmt.Errorf("workflow %s was not loaded from a file and cannot be compared with a revision", ref)
+	}
+
+	previous, err := dag.LoadRevision(ref, rev)
+	if err != nil {
+		logger.L().Error("failed to load workflow at revision", zap.String("workflow", ref), zap.String("rev", rev), zap.Error(err))
+		return nil, "", nil, "", fmt.Errorf("failed to load %s at %s: %w", ref, rev, err)
+	}
+
+	return previous, ref + "@" + rev, current, ref + " (working tree)", nil
+}
+
+// printDiff displays a definition diff in a human-readable format.
+func printDiff(diff *dag.Diff, fromName, toName string) {
+	fmt.Printf("--- %s\n", fromName)
+	fmt.Printf("+++ %s\n", toName)
+
+	if diff.Empty() {
+		fmt.Println("\nNo differences.")
+		return
+	}
+
+	fmt.Println()
+
+	if diff.Name != nil {
+		fmt.Printf("~ name: %q -> %q\n", diff.Name.Old, diff.Name.New)
+	}
+	if diff.Concurrency != nil {
+		fmt.Printf("~ concurrency: %q -> %q\n", diff.Concurrency.Old, diff.Concurrency.New)
+	}
+
+	for _, name := range diff.Added {
+		fmt.Printf("+ task %s\n", name)
+	}
+
+	for _, name := range diff.Removed {
+		fmt.Printf("- task %s\n", name)
+	}
+
+	for _, td := range diff.Changed {
+		fmt.Printf("~ task %s\n", td.Task)
+
+		if td.Cmd != nil {
+			fmt.Printf("    cmd:        %q -> %q\n", td.Cmd.Old, td.Cmd.New)
+		}
+		if td.Retries != nil {
+			fmt.Printf("    retries:    %v -> %v\n", td.Retries.Old, td.Retries.New)
+		}
+		if td.Priority != nil {
+			fmt.Printf("    priority:   %v -> %v\n", td.Priority.Old, td.Priority.New)
+		}
+		if len(td.AddedDeps) > 0 || len(td.RemovedDeps) > 0 {
+			fmt.Printf("    depends_on: %s\n", setChange(td.AddedDeps, td.RemovedDeps))
+		}
+		if len(td.AddedTags) > 0 || len(td.RemovedTags) > 0 {
+			fmt.Printf("    tags:       %s\n", setChange(td.AddedTags, td.RemovedTags))
+		}
+		if len(td.AddedGroups) > 0 || len(td.RemovedGroups) > 0 {
+			fmt.Printf("    groups:     %s\n", setChange(td.AddedGroups, td.RemovedGroups))
+		}
+	}
+
+	for _, name := range diff.AddedGroups {
+		fmt.Printf("+ group %s\n", name)
+	}
+
+	for _, name := range diff.RemovedGroups {
+		fmt.Printf("- group %s\n", name)
+	}
+
+	for _, gd := range diff.ChangedGroups {
+		fmt.Printf("~ group %s\n", gd.Group)
+
+		if gd.Description != nil {
+			fmt.Printf("    description: %q -> %q\n", gd.Description.Old, gd.Description.New)
+		}
+		if len(gd.AddedTasks) > 0 || len(gd.RemovedTasks) > 0 {
+			fmt.Printf("    tasks:       %s\n", setChange(gd.AddedTasks, gd.RemovedTasks))
+		}
+	}
+
+	fmt.Printf("\n%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+	if groups := len(diff.AddedGroups) + len(diff.RemovedGroups) + len(diff.ChangedGroups); groups > 0 {
+		fmt.Printf("%d group(s) added, %d removed, %d changed\n", len(diff.AddedGroups), len(diff.RemovedGroups), len(diff.ChangedGroups))
+	}
+}
+
+// setChange formats the elements added to and removed from a list as "+a -b".
+func setChange(added, removed []string) string {
+	var parts []string
+	for _, s := range added {
+		parts = append(parts, "+"+s)
+	}
+	for _, s := range removed {
+		parts = append(parts, "-"+s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVar(&diffRev, "rev", "HEAD", "Git revision to compare the working tree definition with")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Output in JSON format")
+}
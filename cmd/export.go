@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	exportSince    string
+	exportWorkflow string
+	exportOutput   string
+)
+
+// exportCmd bundles run history into an archive that wf import can merge elsewhere.
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export run history to an archive",
+	Long: `Bundle workflow runs, their task attempts, definition snapshots and log files into a
+gzipped tar archive, e.g. to move audit history from an edge node to another machine.
+Merge the archive into another run store with 'wf import'. Running runs are not exported.`,
+	Example: `  wf export -o runs.tar.gz
+  wf export --since 2026-09-01 --workflow etl -o runs.tar.gz
+  wf export -o - | ssh office wf import -`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := run.ExportOptions{Workflow: exportWorkflow}
+		if exportSince != "" {
+			since, err := parseSince(exportSince)
+			if err != nil {
+				return err
+			}
+			opts.Since = since
+		}
+
+		store, err := openRunStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		// Summaries go to stderr when the archive is written to stdout.
+		out := os.Stdout
+		var result *run.ExportResult
+		if exportOutput == "-" {
+			out = os.Stderr
+			result, err = run.Export(store, os.Stdout, opts)
+		} else {
+			result, err = exportToFile(store, exportOutput, opts)
+		}
+		if err != nil {
+			logger.L().Error("failed to export runs", zap.String("output", exportOutput), zap.Error(err))
+			return fmt.Errorf("failed to export runs: %w", err)
+		}
+
+		logger.L().Info("exported runs",
+			zap.String("output", exportOutput),
+			zap.Int("runs", len(result.Runs)),
+			zap.Int("task_runs", result.TaskRuns),
+			zap.Int("logs", result.Logs),
+		)
+
+		fmt.Fprintf(out, "✓ Exported %d run(s) (%d task runs, %d log files)", len(result.Runs), result.TaskRuns, result.Logs)
+		if exportOutput != "-" {
+			fmt.Fprintf(out, " to %s", exportOutput)
+		}
+		fmt.Fprintln(out)
+		if result.MissingLogs > 0 {
+			fmt.Fprintf(out, "⚠ %d log file(s) recorded for task runs no longer exist and were not exported\n", result.MissingLogs)
+		}
+		return nil
+	},
+}
+
+// exportToFile writes an archive to a temporary file next to path and renames it into
+// place, so a failed export does not leave a truncated archive behind.
+func exportToFile(store run.Repository, path string, opts run.ExportOptions) (*run.ExportResult, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	result, err := run.Export(store, tmp, opts)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, err
+	}
+	return result, os.Rename(tmp.Name(), path)
+}
+
+// parseSince parses a date (2006-01-02, local time) or an RFC 3339 timestamp.
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (expected a date such as 2026-09-01 or an RFC 3339 timestamp)", s)
+}
+
+// openArchive opens an archive for reading, or stdin for "-".
+func openArchive(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "Only export runs started on or after this date (2006-01-02 or RFC 3339)")
+	exportCmd.Flags().StringVarP(&exportWorkflow, "workflow", "w", "", "Only export runs of this workflow")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Archive to write (.tar.gz), or - for stdout")
+	exportCmd.MarkFlagRequired("output")
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joelfokou/workflow/internal/config"
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	fmtCheck bool
+	fmtOrder string
+)
+
+// fmtCmd rewrites TOML workflow files in the canonical layout, keeping their comments.
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [workflow | path | -]...",
+	Short: "Format workflow files",
+	Long: `Rewrite TOML workflow files in a canonical layout: name first, one table per task
+with keys in a fixed order, sorted depends_on lists, and groups last. Comments are kept.
+
+Without arguments, every TOML workflow is formatted. With "-", the definition is read
+from stdin and written to stdout. With --check, files are not changed and the command
+fails if any file is not formatted, for use in CI.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		order, err := dag.ParseTaskOrder(fmtOrder)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 && args[0] == dag.StdinSource {
+			return formatStdin(order)
+		}
+
+		paths, err := fmtPaths(args)
+		if err != nil {
+			return err
+		}
+
+		var unformatted int
+		for _, path := range paths {
+			changed, err := formatFile(path, order)
+			if err != nil {
+				return err
+			}
+			if changed {
+				unformatted++
+			}
+		}
+
+		if fmtCheck && unformatted > 0 {
+			return fmt.Errorf("%d workflow file(s) not formatted; run wf fmt to fix", unformatted)
+		}
+		return nil
+	},
+}
+
+// fmtPaths returns the files to format: the arguments, resolved as paths or workflow
+// names, or every TOML workflow if there are none.
+func fmtPaths(args []string) ([]string, error) {
+	if len(args) == 0 {
+		entries, err := dag.Discover()
+		if err != nil {
+			logger.L().Error("failed to read workflows directory",
+				zap.String("directory", config.C.Paths.Workflows),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to read workflows directory: %w", err)
+		}
+
+		var paths []string
+		for _, entry := range entries {
+			if format, _ := dag.FormatFromPath(entry.Path); format == dag.FormatTOML {
+				paths = append(paths, entry.Path)
+			}
+		}
+		return paths, nil
+	}
+
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		path := arg
+		if !dag.IsPath(arg) {
+			path, _ = dag.Locate(arg)
+		}
+		if format, ok := dag.FormatFromPath(path); ok && format != dag.FormatTOML {
+			return nil, fmt.Errorf("cannot format %s: only TOML workflows can be formatted", arg)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// formatFile formats a workflow file in place, or only reports it with --check.
+// It returns whether the file was not already formatted.
+func formatFile(path string, order dag.TaskOrder) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read workflow file %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read workflow file %s: %w", path, err)
+	}
+
+	formatted, err := dag.Canonicalise(data, order)
+	if err != nil {
+		logger.L().Error("failed to format workflow", zap.String("path", path), zap.Error(err))
+		return false, fmt.Errorf("failed to format %s: %w", path, err)
+	}
+
+	if bytes.Equal(data, formatted) {
+		return false, nil
+	}
+
+	if fmtCheck {
+		fmt.Printf("✗ %s: not formatted\n", path)
+		return true, nil
+	}
+
+	if err := os.WriteFile(path, formatted, info.Mode().Perm()); err != nil {
+		logger.L().Error("failed to write formatted workflow", zap.String("path", path), zap.Error(err))
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	logger.L().Info("workflow formatted", zap.String("path", path))
+	fmt.Printf("✓ Formatted %s\n", path)
+	return true, nil
+}
+
+// formatStdin formats a definition read from stdin and writes it to stdout.
+func formatStdin(order dag.TaskOrder) error {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read workflow from stdin: %w", err)
+	}
+
+	formatted, err := dag.Canonicalise(data, order)
+	if err != nil {
+		return fmt.Errorf("failed to format workflow: %w", err)
+	}
+
+	if fmtCheck {
+		if !bytes.Equal(data, formatted) {
+			return fmt.Errorf("workflow is not formatted")
+		}
+		return nil
+	}
+
+	_, err = os.Stdout.Write(formatted)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "Report unformatted files and exit non-zero instead of rewriting them")
+	fmtCmd.Flags().StringVar(&fmtOrder, "order", "declared", "Task order: declared or topological")
+}
@@ -19,14 +19,14 @@ var (
 // graphCmd displays the directed acyclic graph (DAG) structure of a workflow.
 // Supports multiple output formats: ascii, dot (Graphviz), and json.
 var graphCmd = &cobra.Command{
-	Use:   "graph <workflow>",
+	Use:   "graph <workflow | path | ->",
 	Short: "Display workflow DAG structure",
 	Long:  "Visualise the workflow as a directed acyclic graph in various formats",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		workflowName := args[0]
 
-		d, err := dag.Load(workflowName)
+		d, err := dag.Resolve(workflowName)
 		if err != nil {
 			logger.L().Error("failed to load workflow", zap.String("workflow", workflowName), zap.Error(err))
 			return fmt.Errorf("failed to load workflow %s: %w", workflowName, err)
@@ -69,6 +69,12 @@ func renderASCII(d *dag.DAG, detailed bool) error {
 			} else {
 				fmt.Printf("    Depends:  none (root task)\n")
 			}
+			if len(task.Tags) > 0 {
+				fmt.Printf("    Tags:     %v\n", task.Tags)
+			}
+			if groups := d.GroupsOf(task.Name); len(groups) > 0 {
+				fmt.Printf("    Groups:   %v\n", groups)
+			}
 		}
 	}
 
@@ -81,15 +87,39 @@ func renderDot(d *dag.DAG) error {
 	fmt.Println("  rankdir=LR;")
 	fmt.Println("  node [shape=box, style=rounded];")
 
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return err
+	}
+
+	// Draw each group as a cluster; a task in several groups is drawn in the first
+	clustered := make(map[string]bool)
+	for _, g := range d.SortedGroups() {
+		fmt.Printf("  subgraph \"cluster_%s\" {\n", sanitiseName(g.Name))
+		fmt.Printf("    label=\"%s\";\n", g.Name)
+		fmt.Println("    style=dashed;")
+		for _, name := range g.Tasks {
+			if clustered[name] {
+				continue
+			}
+			clustered[name] = true
+			fmt.Printf("    \"%s\" [label=\"%s\"];\n", name, name)
+		}
+		fmt.Println("  }")
+	}
+
 	// Add nodes
-	for _, task := range d.Tasks {
+	for _, task := range order {
+		if clustered[task.Name] {
+			continue
+		}
 		fmt.Printf("  \"%s\" [label=\"%s\"];\n", task.Name, task.Name)
 	}
 
 	fmt.Println()
 
 	// Add edges
-	for _, task := range d.Tasks {
+	for _, task := range order {
 		for _, dep := range task.DependsOn {
 			fmt.Printf("  \"%s\" -> \"%s\";\n", dep, task.Name)
 		}
@@ -110,11 +140,14 @@ func renderJSON(d *dag.DAG) error {
 		Cmd       string   `json:"cmd"`
 		Retries   int      `json:"retries"`
 		DependsOn []string `json:"depends_on,omitempty"`
+		Tags      []string `json:"tags,omitempty"`
+		Groups    []string `json:"groups,omitempty"`
 	}
 
 	type dagJSON struct {
-		Name  string     `json:"name"`
-		Tasks []taskJSON `json:"tasks"`
+		Name   string       `json:"name"`
+		Tasks  []taskJSON   `json:"tasks"`
+		Groups []*dag.Group `json:"groups,omitempty"`
 	}
 
 	order, _ := d.TopologicalSort()
@@ -126,14 +159,17 @@ func renderJSON(d *dag.DAG) error {
 			Cmd:       task.Cmd,
 			Retries:   task.Retries,
 			DependsOn: task.DependsOn,
+			Tags:      task.Tags,
+			Groups:    d.GroupsOf(task.Name),
 		})
 	}
 
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(dagJSON{
-		Name:  d.Name,
-		Tasks: tasks,
+		Name:   d.Name,
+		Tasks:  tasks,
+		Groups: d.SortedGroups(),
 	})
 }
 
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/joelfokou/workflow/internal/config"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// importCmd merges an archive written by wf export into the run store.
+var importCmd = &cobra.Command{
+	Use:   "import <archive>",
+	Short: "Import run history from an archive",
+	Long: `Merge the runs of an archive written by 'wf export' into the run store, and their log
+files into the logs directory. Runs are matched by ID: runs already present are skipped, so
+importing the same archive twice is harmless. Imported runs keep their original timestamps
+and record the host they were exported from as their origin. Use - to read from stdin.`,
+	Example: `  wf import runs.tar.gz`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archive, err := openArchive(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer archive.Close()
+
+		store, err := openRunStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		result, err := run.Import(store, archive, config.C.Paths.Logs)
+		if err != nil {
+			if result != nil && len(result.Imported) > 0 {
+				fmt.Printf("Imported %d run(s) before the error; importing again skips them\n", len(result.Imported))
+			}
+			logger.L().Error("failed to import runs", zap.String("archive", args[0]), zap.Error(err))
+			return fmt.Errorf("failed to import runs: %w", err)
+		}
+
+		logger.L().Info("imported runs",
+			zap.String("archive", args[0]),
+			zap.String("origin", result.Manifest.Host),
+			zap.Int("imported", len(result.Imported)),
+			zap.Int("skipped", len(result.Skipped)),
+			zap.Int("logs", result.Logs),
+		)
+
+		for _, r := range result.Imported {
+			fmt.Printf("  + %s  %s  %s  %s\n", r.ID, r.Workflow, coloriseStatus(r.Status), r.StartedAt.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Printf("✓ Imported %d run(s) with %d log files from %s (exported %s)",
+			len(result.Imported), result.Logs, result.Manifest.Host, result.Manifest.ExportedAt.Format("2006-01-02 15:04:05"))
+		if len(result.Skipped) > 0 {
+			fmt.Printf(", skipped %d already present", len(result.Skipped))
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/logger"
-	"github.com/joelfokou/workflow/internal/run"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -21,6 +21,7 @@ var initCmd = &cobra.Command{
 		dirs := []string{
 			config.C.Paths.Workflows,
 			config.C.Paths.Logs,
+			filepath.Dir(config.C.Paths.Database),
 		}
 
 		for _, dir := range dirs {
@@ -31,12 +32,11 @@ var initCmd = &cobra.Command{
 			logger.L().Debug("directory created or already exists", zap.String("path", dir))
 		}
 
-		// Initialise SQLite database
-		dbPath := config.C.Paths.Database
-		store, err := run.NewStore(dbPath)
+		// Initialise run store
+		dbPath := storagePath()
+		store, err := openRunStore()
 		if err != nil {
-			logger.L().Error("failed to initialise database", zap.String("path", dbPath), zap.Error(err))
-			return fmt.Errorf("failed to initialise database: %w", err)
+			return err
 		}
 		store.Close()
 
@@ -67,6 +67,9 @@ var initCmd = &cobra.Command{
 		fmt.Printf("  Workflows:  %s\n", config.C.Paths.Workflows)
 		fmt.Printf("  Logs:       %s\n", config.C.Paths.Logs)
 		fmt.Printf("  Database:   %s\n", dbPath)
+		if config.P != nil {
+			fmt.Printf("  Project:    %s\n", config.P.Root)
+		}
 		fmt.Println("\nConfigure paths via environment variables or config file.")
 
 		logger.L().Info("project initialised",
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/joelfokou/workflow/internal/config"
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/lint"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	lintFormat string
+	lintFailOn string
+	lintRules  bool
+)
+
+// lintCmd checks workflow definitions for patterns that are valid but likely to cause
+// problems. Findings can be suppressed with "# wf:ignore RULE" comments.
+var lintCmd = &cobra.Command{
+	Use:   "lint [workflow | path | -]",
+	Short: "Check workflows for common mistakes",
+	Long: `Check all workflows or a specific workflow for patterns that are valid but likely
+to cause problems, such as redundant dependencies or retries on non-idempotent commands.
+
+Suppress a finding with a "# wf:ignore RULE" comment on or above a task, or at the top
+of the file to suppress a rule for the whole workflow.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if lintRules {
+			return printLintRules()
+		}
+
+		failOn := lint.Severity(lintFailOn)
+		switch failOn {
+		case lint.SeverityError, lint.SeverityWarning, lint.SeverityInfo:
+		default:
+			return fmt.Errorf("unsupported severity: %s (supported: error, warning, info)", lintFailOn)
+		}
+
+		var findings []lint.Finding
+		if len(args) == 1 {
+			f, err := lintWorkflow(args[0])
+			if err != nil {
+				return err
+			}
+			findings = f
+		} else {
+			entries, err := dag.Discover()
+			if err != nil {
+				logger.L().Error("failed to read workflows directory",
+					zap.String("directory", config.C.Paths.Workflows),
+					zap.Error(err),
+				)
+				return fmt.Errorf("failed to read workflows directory: %w", err)
+			}
+			for _, entry := range entries {
+				f, err := lintWorkflow(entry.Path)
+				if err != nil {
+					return err
+				}
+				findings = append(findings, f...)
+			}
+		}
+
+		if err := printLintFindings(findings); err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, f := range findings {
+			if f.Severity.AtLeast(failOn) {
+				failed++
+			}
+		}
+
+		logger.L().Info("lint finished", zap.Int("findings", len(findings)), zap.Int("failing", failed))
+
+		if failed > 0 {
+			return fmt.Errorf("%d finding(s) at or above %s", failed, failOn)
+		}
+		return nil
+	},
+}
+
+// lintWorkflow loads a workflow with its raw source and lints it.
+func lintWorkflow(ref string) ([]lint.Finding, error) {
+	var (
+		d   *dag.DAG
+		src []byte
+		err error
+	)
+
+	if ref == dag.StdinSource {
+		src, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read workflow from stdin: %w", err)
+		}
+		d, err = dag.LoadBytes(src, "", dag.StdinSource)
+	} else {
+		d, err = dag.Resolve(ref)
+		if err == nil {
+			src, err = os.ReadFile(d.Source)
+		}
+	}
+	if err != nil {
+		logger.L().Error("failed to load workflow", zap.String("workflow", ref), zap.Error(err))
+		return nil, fmt.Errorf("failed to load workflow %s: %w", ref, err)
+	}
+
+	return lint.Lint(d, src), nil
+}
+
+// printLintFindings writes findings in the selected output format.
+func printLintFindings(findings []lint.Finding) error {
+	switch lintFormat {
+	case "text":
+		for _, f := range findings {
+			location := f.Workflow
+			if f.File != "" {
+				location = f.File
+				if f.Line > 0 {
+					location = fmt.Sprintf("%s:%d", f.File, f.Line)
+				}
+			}
+			subject := f.Workflow
+			if f.Task != "" {
+				subject += "." + f.Task
+			}
+			fmt.Printf("%s: %s %s %s: %s\n", location, f.Severity, f.Rule, subject, f.Message)
+		}
+		if len(findings) == 0 {
+			fmt.Println("✓ no findings")
+		} else {
+			fmt.Printf("\n%d finding(s)\n", len(findings))
+		}
+		return nil
+	case "json":
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(findings)
+	case "sarif":
+		data, err := lint.SARIF(findings, rootCmd.Version)
+		if err != nil {
+			return fmt.Errorf("failed to encode SARIF: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s (supported: text, json, sarif)", lintFormat)
+	}
+}
+
+// printLintRules lists every lint rule.
+func printLintRules() error {
+	if lintFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(lint.Rules())
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tNAME\tSEVERITY\tDESCRIPTION\n")
+	fmt.Fprintf(w, "--\t----\t--------\t-----------\n")
+	for _, r := range lint.Rules() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.Name, r.Severity, r.Description)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "Output format: text, json, or sarif")
+	lintCmd.Flags().StringVar(&lintFailOn, "fail-on", "warning", "Exit with an error on findings at or above this severity: error, warning, or info")
+	lintCmd.Flags().BoolVar(&lintRules, "rules", false, "List the available lint rules")
+}
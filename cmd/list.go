@@ -19,6 +19,7 @@ import (
 // workflowInfo holds metadata about a workflow.
 type workflowInfo struct {
 	Name         string `json:"name"`
+	Scope        string `json:"scope"`
 	Tasks        int    `json:"tasks"`
 	Valid        bool   `json:"valid"`
 	LastRun      string `json:"last_run,omitempty"`
@@ -38,49 +39,60 @@ type runStats struct {
 var (
 	listJSON     bool
 	listDetailed bool
+	listTree     bool
 )
 
 // listCmd lists all available workflows with metadata including recent run statistics.
 var listCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [namespace]",
 	Short: "List workflows",
-	Long:  "List all available workflows with optional run statistics",
+	Long: `List all available workflows with optional run statistics.
+
+Workflows in subdirectories are namespaced by their path, so workflows/etl/daily.toml
+is listed as etl/daily. Pass a namespace to only list the workflows it contains.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entries, err := os.ReadDir(config.C.Paths.Workflows)
+		entries, err := dag.Discover()
 		if err != nil {
 			logger.L().Error("list command failed", zap.Error(err))
 			return fmt.Errorf("failed to read workflows directory: %w", err)
 		}
 
+		namespace := ""
+		if len(args) == 1 {
+			namespace = args[0]
+		}
+
 		var workflows []*workflowInfo
 		for _, entry := range entries {
-			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".toml") {
-				name := strings.TrimSuffix(entry.Name(), ".toml")
-				info := &workflowInfo{Name: name}
-
-				// Load workflow definition to get task count
-				d, err := dag.Load(name)
-				if err != nil {
-					logger.L().Warn("failed to load workflow definition", zap.String("workflow", name), zap.Error(err))
-					info.Tasks = 0
-					info.Valid = false
-				} else {
-					info.Tasks = len(d.Tasks)
-					info.Valid = true
-				}
+			if !dag.InNamespace(entry.Name, namespace) {
+				continue
+			}
 
-				// Get recent run statistics if detailed output requested
-				if listDetailed {
-					if stats, err := getRunStats(name); err == nil {
-						info.LastRun = stats.LastRun
-						info.TotalRuns = stats.TotalRuns
-						info.SuccessCount = stats.SuccessCount
-						info.FailedCount = stats.FailedCount
-					}
-				}
+			info := &workflowInfo{Name: entry.Name, Scope: entry.Scope}
+
+			// Load workflow definition to get task count
+			d, err := dag.LoadFile(entry.Path)
+			if err != nil {
+				logger.L().Warn("failed to load workflow definition", zap.String("workflow", entry.Name), zap.Error(err))
+				info.Tasks = 0
+				info.Valid = false
+			} else {
+				info.Tasks = len(d.Tasks)
+				info.Valid = true
+			}
 
-				workflows = append(workflows, info)
+			// Get recent run statistics if detailed output requested
+			if listDetailed {
+				if stats, err := getRunStats(entry.Name); err == nil {
+					info.LastRun = stats.LastRun
+					info.TotalRuns = stats.TotalRuns
+					info.SuccessCount = stats.SuccessCount
+					info.FailedCount = stats.FailedCount
+				}
 			}
+
+			workflows = append(workflows, info)
 		}
 
 		if len(workflows) == 0 {
@@ -103,6 +115,10 @@ var listCmd = &cobra.Command{
 			return printWorkflowsJSON(workflows)
 		}
 
+		if listTree {
+			return printWorkflowsTree(workflows)
+		}
+
 		if listDetailed {
 			return printWorkflowsDetailedTable(workflows)
 		}
@@ -113,8 +129,7 @@ var listCmd = &cobra.Command{
 
 // getRunStats queries the database for workflow run statistics.
 func getRunStats(workflowName string) (*runStats, error) {
-	dbPath := config.C.Paths.Database
-	store, err := run.NewStore(dbPath)
+	store, err := openRunStore()
 	if err != nil {
 		return nil, err
 	}
@@ -148,15 +163,15 @@ func getRunStats(workflowName string) (*runStats, error) {
 // printWorkflowsTable displays workflows in simple table format.
 func printWorkflowsTable(workflows []*workflowInfo) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-	fmt.Fprintf(w, "WORKFLOW\tTASKS\tSTATUS\n")
-	fmt.Fprintf(w, "--------\t-----\t------\n")
+	fmt.Fprintf(w, "WORKFLOW\tSCOPE\tTASKS\tSTATUS\n")
+	fmt.Fprintf(w, "--------\t-----\t-----\t------\n")
 
 	for _, wf := range workflows {
 		status := "✓ valid"
 		if !wf.Valid {
 			status = "✗ invalid"
 		}
-		fmt.Fprintf(w, "%s\t%d\t%s\n", wf.Name, wf.Tasks, status)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", wf.Name, wf.Scope, wf.Tasks, status)
 	}
 
 	w.Flush()
@@ -166,8 +181,8 @@ func printWorkflowsTable(workflows []*workflowInfo) error {
 // printWorkflowsDetailedTable displays workflows with run statistics.
 func printWorkflowsDetailedTable(workflows []*workflowInfo) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-	fmt.Fprintf(w, "WORKFLOW\tTASKS\tTOTAL RUNS\tSUCCESS\tFAILED\tLAST RUN\n")
-	fmt.Fprintf(w, "--------\t-----\t----------\t-------\t------\t--------\n")
+	fmt.Fprintf(w, "WORKFLOW\tSCOPE\tTASKS\tTOTAL RUNS\tSUCCESS\tFAILED\tLAST RUN\n")
+	fmt.Fprintf(w, "--------\t-----\t-----\t----------\t-------\t------\t--------\n")
 
 	for _, wf := range workflows {
 		lastRun := "-"
@@ -175,8 +190,9 @@ func printWorkflowsDetailedTable(workflows []*workflowInfo) error {
 			lastRun = wf.LastRun
 		}
 
-		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n",
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
 			wf.Name,
+			wf.Scope,
 			wf.Tasks,
 			wf.TotalRuns,
 			wf.SuccessCount,
@@ -189,6 +205,62 @@ func printWorkflowsDetailedTable(workflows []*workflowInfo) error {
 	return nil
 }
 
+// printWorkflowsTree displays workflows grouped by namespace as a tree.
+func printWorkflowsTree(workflows []*workflowInfo) error {
+	type node struct {
+		children map[string]*node
+		info     *workflowInfo
+	}
+	newNode := func() *node { return &node{children: map[string]*node{}} }
+
+	root := newNode()
+	for _, wf := range workflows {
+		n := root
+		for _, part := range strings.Split(wf.Name, "/") {
+			child, ok := n.children[part]
+			if !ok {
+				child = newNode()
+				n.children[part] = child
+			}
+			n = child
+		}
+		n.info = wf
+	}
+
+	var render func(n *node, prefix string)
+	render = func(n *node, prefix string) {
+		names := make([]string, 0, len(n.children))
+		for name := range n.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for i, name := range names {
+			child := n.children[name]
+			edge, next := "├── ", "│   "
+			if i == len(names)-1 {
+				edge, next = "└── ", "    "
+			}
+
+			label := name + "/"
+			if child.info != nil {
+				status := "✓"
+				if !child.info.Valid {
+					status = "✗"
+				}
+				label = fmt.Sprintf("%s %s (%d tasks, %s)", status, name, child.info.Tasks, child.info.Scope)
+			}
+
+			fmt.Println(prefix + edge + label)
+			render(child, prefix+next)
+		}
+	}
+
+	fmt.Println(".")
+	render(root, "")
+	return nil
+}
+
 // printWorkflowsJSON outputs workflows in JSON format.
 func printWorkflowsJSON(workflows []*workflowInfo) error {
 	encoder := json.NewEncoder(os.Stdout)
@@ -201,4 +273,5 @@ func init() {
 
 	listCmd.Flags().BoolVar(&listJSON, "json", false, "Output in JSON format")
 	listCmd.Flags().BoolVarP(&listDetailed, "detailed", "d", false, "Show detailed statistics including run history")
+	listCmd.Flags().BoolVar(&listTree, "tree", false, "Show workflows grouped by namespace")
 }
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/logger"
 	"github.com/joelfokou/workflow/internal/run"
 	"github.com/spf13/cobra"
@@ -18,22 +17,18 @@ var logsCmd = &cobra.Command{
 	Long:  "Display logs for a workflow run or a specific task within that run",
 	Args:  cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		runID := args[0]
-		dbPath := config.C.Paths.Database
-
-		store, err := run.NewStore(dbPath)
+		store, err := openRunStore()
 		if err != nil {
-			logger.L().Error("failed to initialise run store", zap.Error(err))
-			return fmt.Errorf("failed to initialise run store: %w", err)
+			return err
 		}
 		defer store.Close()
 
 		// Verify run exists
-		workflowRun, err := store.Load(runID)
+		workflowRun, err := loadRun(store, args[0])
 		if err != nil {
-			logger.L().Error("run not found", zap.String("run_id", runID), zap.Error(err))
-			return fmt.Errorf("run '%s' not found: %w", runID, err)
+			return err
 		}
+		runID := workflowRun.ID
 
 		// Load all tasks for this run
 		tasks, err := store.LoadTaskRuns(runID)
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/joelfokou/workflow/internal/config"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	pruneOlderThan string
+	pruneKeepLast  int
+	pruneStatus    string
+	pruneWorkflow  string
+	pruneDryRun    bool
+	pruneJSON      bool
+)
+
+// pruneCmd deletes old runs together with their task runs and logs.
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old runs and their logs",
+	Long: `Delete workflow runs, their task runs and their log directories.
+
+A run is deleted only if it matches every limit given: --older-than deletes runs created
+longer ago than the age (e.g. 30d, 2w, 12h), and --keep-last keeps the most recent matching
+runs of each workflow. At least one of them is required. Running runs are never deleted.
+
+Configure a retention section to prune automatically after each 'wf run'.`,
+	Example: `  wf prune --older-than 30d
+  wf prune --keep-last 50 --status success --workflow etl --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy := run.RetentionPolicy{
+			KeepLast: pruneKeepLast,
+			Status:   run.WorkflowStatus(pruneStatus),
+			Workflow: pruneWorkflow,
+		}
+		if pruneOlderThan != "" {
+			age, err := run.ParseAge(pruneOlderThan)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+			policy.OlderThan = age
+		}
+		if pruneKeepLast < 0 {
+			return fmt.Errorf("--keep-last must not be negative")
+		}
+		if !policy.Limited() {
+			return fmt.Errorf("specify --older-than, --keep-last or both")
+		}
+		if err := checkPruneStatus(policy.Status); err != nil {
+			return err
+		}
+
+		store, err := openRunStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		result, err := run.Prune(store, policy, config.C.Paths.Logs, pruneDryRun)
+		if err != nil {
+			logger.L().Error("failed to prune runs", zap.Error(err))
+			return fmt.Errorf("failed to prune runs: %w", err)
+		}
+
+		logger.L().Info("pruned runs",
+			zap.Int("runs", len(result.Runs)),
+			zap.Int("task_runs", result.TaskRuns),
+			zap.Int64("reclaimed_bytes", result.Reclaimed()),
+			zap.Bool("dry_run", pruneDryRun),
+		)
+
+		if pruneJSON {
+			return printPruneJSON(result)
+		}
+		printPruneTable(result)
+		return nil
+	},
+}
+
+// checkPruneStatus rejects statuses no run can be pruned with.
+func checkPruneStatus(status run.WorkflowStatus) error {
+	switch status {
+	case "", run.StatusPending, run.StatusSuccess, run.StatusFailed, run.StatusInterrupted:
+		return nil
+	case run.StatusRunning:
+		return fmt.Errorf("running runs are never pruned")
+	default:
+		return fmt.Errorf("invalid status %q (expected pending, success, failed or interrupted)", status)
+	}
+}
+
+// pruneResultJSON is the JSON form of a prune.
+type pruneResultJSON struct {
+	DryRun         bool            `json:"dry_run"`
+	Runs           []prunedRunJSON `json:"runs"`
+	TaskRuns       int             `json:"task_runs"`
+	LogBytes       int64           `json:"log_bytes"`
+	DatabaseBytes  int64           `json:"database_bytes"`
+	ReclaimedBytes int64           `json:"reclaimed_bytes"`
+}
+
+type prunedRunJSON struct {
+	ID        string `json:"id"`
+	Workflow  string `json:"workflow"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+	TaskRuns  int    `json:"task_runs"`
+	LogDir    string `json:"log_dir,omitempty"`
+	LogBytes  int64  `json:"log_bytes"`
+}
+
+// printPruneJSON outputs the result of a prune in JSON format.
+func printPruneJSON(result *run.PruneResult) error {
+	out := pruneResultJSON{
+		DryRun:         result.DryRun,
+		Runs:           []prunedRunJSON{},
+		TaskRuns:       result.TaskRuns,
+		LogBytes:       result.LogBytes,
+		DatabaseBytes:  result.DatabaseBytes,
+		ReclaimedBytes: result.Reclaimed(),
+	}
+	for _, p := range result.Runs {
+		out.Runs = append(out.Runs, prunedRunJSON{
+			ID:        p.Run.ID,
+			Workflow:  p.Run.Workflow,
+			Status:    string(p.Run.Status),
+			CreatedAt: p.Run.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			TaskRuns:  p.TaskRuns,
+			LogDir:    p.LogDir,
+			LogBytes:  p.LogBytes,
+		})
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printPruneTable displays the pruned runs and a summary of the space reclaimed.
+func printPruneTable(result *run.PruneResult) {
+	if len(result.Runs) == 0 {
+		fmt.Println("No runs to prune")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "RUN ID\tWORKFLOW\tSTATUS\tCREATED AT\tTASK RUNS\tLOGS\n")
+	fmt.Fprintf(w, "------\t--------\t------\t----------\t---------\t----\n")
+	for _, p := range result.Runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			p.Run.ID,
+			p.Run.Workflow,
+			coloriseStatus(p.Run.Status),
+			p.Run.CreatedAt.Format("2006-01-02 15:04:05"),
+			p.TaskRuns,
+			formatBytes(p.LogBytes),
+		)
+	}
+	w.Flush()
+
+	fmt.Println()
+	if result.DryRun {
+		fmt.Printf("Would prune %d run(s) (%d task runs) and reclaim %s of logs; run without --dry-run to delete them\n",
+			len(result.Runs), result.TaskRuns, formatBytes(result.LogBytes))
+		return
+	}
+	fmt.Printf("✓ %s\n", pruneSummary(result))
+}
+
+// pruneSummary describes what a prune deleted and the space it reclaimed.
+func pruneSummary(result *run.PruneResult) string {
+	summary := fmt.Sprintf("Pruned %d run(s) (%d task runs), reclaimed %s", len(result.Runs), result.TaskRuns, formatBytes(result.Reclaimed()))
+	if result.DatabaseBytes > 0 {
+		summary += fmt.Sprintf(" (logs %s, database %s)", formatBytes(result.LogBytes), formatBytes(result.DatabaseBytes))
+	}
+	return summary
+}
+
+// applyRetention prunes runs according to the retention section of the config, if any.
+// Failures are reported but do not fail the command that triggered it.
+func applyRetention(store run.Repository) {
+	cfg := config.C.Retention
+	policy := run.RetentionPolicy{
+		KeepLast: cfg.KeepLast,
+		Status:   run.WorkflowStatus(cfg.Status),
+	}
+	if cfg.OlderThan != "" {
+		age, err := run.ParseAge(cfg.OlderThan)
+		if err != nil {
+			logger.L().Warn("invalid retention.older_than", zap.String("value", cfg.OlderThan), zap.Error(err))
+			fmt.Fprintf(os.Stderr, "⚠ Skipping retention: invalid retention.older_than: %v\n", err)
+			return
+		}
+		policy.OlderThan = age
+	}
+	if !policy.Limited() {
+		return
+	}
+	if err := checkPruneStatus(policy.Status); err != nil {
+		logger.L().Warn("invalid retention.status", zap.String("value", cfg.Status), zap.Error(err))
+		fmt.Fprintf(os.Stderr, "⚠ Skipping retention: invalid retention.status: %v\n", err)
+		return
+	}
+
+	result, err := run.Prune(store, policy, config.C.Paths.Logs, false)
+	if err != nil {
+		logger.L().Warn("retention failed", zap.Error(err))
+		fmt.Fprintf(os.Stderr, "⚠ Retention failed: %v\n", err)
+		return
+	}
+	if len(result.Runs) == 0 {
+		return
+	}
+
+	logger.L().Info("retention pruned runs",
+		zap.Int("runs", len(result.Runs)),
+		zap.Int("task_runs", result.TaskRuns),
+		zap.Int64("reclaimed_bytes", result.Reclaimed()),
+	)
+	fmt.Printf("✓ Retention: %s\n", pruneSummary(result))
+}
+
+// formatBytes formats a size in bytes with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Prune runs created longer ago than this age (e.g. 30d, 2w, 12h)")
+	pruneCmd.Flags().IntVar(&pruneKeepLast, "keep-last", 0, "Keep the most recent matching runs of each workflow")
+	pruneCmd.Flags().StringVarP(&pruneStatus, "status", "s", "", "Only prune runs with this status (success|failed|interrupted|pending)")
+	pruneCmd.Flags().StringVarP(&pruneWorkflow, "workflow", "w", "", "Only prune runs of this workflow")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be pruned without deleting anything")
+	pruneCmd.Flags().BoolVar(&pruneJSON, "json", false, "Output in JSON format")
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/joelfokou/workflow/internal/executor"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var rerunDownstream bool
+
+var rerunCmd = &cobra.Command{
+	Use:   "rerun <run_id> <task>",
+	Short: "Rerun a task within an existing workflow run",
+	Long: `Rerun a task of an existing workflow run, whether it failed or succeeded.
+
+The task is executed again against the definition snapshot stored with the
+run. With --downstream, every task that depends on it is rerun as well.
+Previous task attempts are archived and kept for audit.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		taskName := args[1]
+
+		// Initialise run store
+		store, err := openRunStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		// Verify run exists
+		workflowRun, err := loadRun(store, args[0])
+		if err != nil {
+			return err
+		}
+		runID := workflowRun.ID
+
+		if err := recoverRun(store, workflowRun); err != nil {
+			return err
+		}
+
+		// Setup context with cancellation
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Handle Ctrl+C
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt)
+		go func() {
+			<-sigChan
+			fmt.Println("\n✖ Received interrupt. Cancelling workflow...")
+			cancel()
+		}()
+
+		ex := executor.NewExecutor(store)
+		if err := ex.Rerun(ctx, workflowRun, taskName, rerunDownstream); err != nil {
+			logger.L().Error("failed to rerun task", zap.String("run_id", runID), zap.String("task", taskName), zap.Error(err))
+			return fmt.Errorf("failed to rerun task '%s' of run '%s': %w", taskName, runID, err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rerunCmd)
+
+	rerunCmd.Flags().BoolVar(&rerunDownstream, "downstream", false, "Also rerun every task that depends on the task")
+}
@@ -2,11 +2,12 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"time"
 
-	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/executor"
 	"github.com/joelfokou/workflow/internal/logger"
 	"github.com/joelfokou/workflow/internal/run"
@@ -14,36 +15,50 @@ import (
 	"go.uber.org/zap"
 )
 
+var (
+	resumeAcceptChanges bool
+	resumeUseSnapshot   bool
+)
+
 var resumeCmd = &cobra.Command{
 	Use:   "resume <run_id>",
-	Short: "Resume a failed workflow run",
-	Long:  "Resume a failed workflow run from the point of failure",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		runID := args[0]
+	Short: "Resume a failed or interrupted workflow run",
+	Long: `Resume a failed or interrupted workflow run from the point of failure.
 
+A run left running by a process that crashed or whose machine rebooted is marked
+interrupted first; a run whose process is still alive is refused.
+
+If the workflow definition changed since the run started, resume refuses to
+continue. Use --use-snapshot to resume against the definition stored with the
+run, or --accept-changes to resume against the current definition.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Initialise run store
-		dbPath := config.C.Paths.Database
-		store, err := run.NewStore(dbPath)
+		store, err := openRunStore()
 		if err != nil {
-			logger.L().Error("failed to initialise run store", zap.Error(err))
-			return fmt.Errorf("failed to initialise run store: %w", err)
+			return err
 		}
 		defer store.Close()
 
 		// Verify run exists
-		workflowRun, err := store.Load(runID)
+		workflowRun, err := loadRun(store, args[0])
 		if err != nil {
-			logger.L().Error("run not found", zap.String("run_id", runID), zap.Error(err))
-			return fmt.Errorf("run '%s' not found: %w", runID, err)
+			return err
+		}
+		runID := workflowRun.ID
+
+		if err := recoverRun(store, workflowRun); err != nil {
+			return err
 		}
 
 		// Check if the run is in a resumable state
-		if workflowRun.Status != run.StatusFailed {
+		if workflowRun.Status != run.StatusFailed && workflowRun.Status != run.StatusInterrupted {
 			logger.L().Warn("workflow run is not in a resumable state", zap.String("run_id", runID), zap.String("status", string(workflowRun.Status)))
 			return fmt.Errorf("workflow run '%s' is not in a resumable state (current status: %s)", runID, workflowRun.Status)
 		}
 
+		warnStaleRuns(store)
+
 		// Setup context with cancellation
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -58,10 +73,22 @@ var resumeCmd = &cobra.Command{
 		}()
 
 		// Create executor and resume workflow
-		executor := executor.NewExecutor(store)
-		err = executor.Resume(ctx, workflowRun)
+		ex := executor.NewExecutor(store)
+		switch {
+		case resumeAcceptChanges:
+			ex.ResumePolicy = executor.ResumeAcceptChanges
+		case resumeUseSnapshot:
+			ex.ResumePolicy = executor.ResumeFromSnapshot
+		}
+
+		err = ex.Resume(ctx, workflowRun)
 		if err != nil {
 			logger.L().Error("failed to resume workflow run", zap.String("run_id", runID), zap.Error(err))
+
+			var changed *executor.DefinitionChangedError
+			if errors.As(err, &changed) {
+				return fmt.Errorf("failed to resume workflow run '%s': %w\nUse --use-snapshot to resume the original definition or --accept-changes to resume the new one", runID, err)
+			}
 			return fmt.Errorf("failed to resume workflow run '%s': %w", runID, err)
 		}
 
@@ -69,6 +96,35 @@ var resumeCmd = &cobra.Command{
 	},
 }
 
+// recoverRun marks a run left running by a dead process as interrupted. It returns an
+// error if the run is still being executed.
+func recoverRun(store run.Repository, wr *run.WorkflowRun) error {
+	if wr.Status != run.StatusRunning {
+		return nil
+	}
+
+	if !wr.Stale(time.Now(), staleAfter()) {
+		logger.L().Warn("workflow run is still running", zap.String("run_id", wr.ID), zap.Int64("pid", wr.PID.Int64), zap.String("host", wr.Host.String))
+		if wr.PID.Valid {
+			return fmt.Errorf("workflow run '%s' is still running (pid %d on %s)", wr.ID, wr.PID.Int64, wr.Host.String)
+		}
+		return fmt.Errorf("workflow run '%s' is still running", wr.ID)
+	}
+
+	if err := store.MarkInterrupted(wr); err != nil {
+		logger.L().Error("failed to mark run interrupted", zap.String("run_id", wr.ID), zap.Error(err))
+		return fmt.Errorf("failed to mark run '%s' interrupted: %w", wr.ID, err)
+	}
+
+	logger.L().Info("marked stale run interrupted", zap.String("run_id", wr.ID))
+	fmt.Printf("⚠ Run %s was left running by a process that no longer responds; marked as interrupted\n", wr.ID)
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(resumeCmd)
+
+	resumeCmd.Flags().BoolVar(&resumeAcceptChanges, "accept-changes", false, "Resume against the current definition if it changed since the run started")
+	resumeCmd.Flags().BoolVar(&resumeUseSnapshot, "use-snapshot", false, "Resume against the definition stored with the run")
+	resumeCmd.MarkFlagsMutuallyExclusive("accept-changes", "use-snapshot")
 }
@@ -2,11 +2,16 @@
 package cmd
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -76,6 +81,49 @@ func initConfig() {
 	logger.L().Debug("logger initialised", zap.String("level", logLevel))
 }
 
+// openRunStore opens the run repository selected by storage.backend.
+func openRunStore() (run.Repository, error) {
+	backend := run.Backend(config.C.Storage.Backend)
+	path := storagePath()
+
+	store, err := run.Open(backend, path)
+	if err != nil {
+		logger.L().Error("failed to initialise run store", zap.String("backend", string(backend)), zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to initialise run store: %w", err)
+	}
+	return store, nil
+}
+
+// storagePath returns the file runs are recorded in: the database for the sqlite backend,
+// or the database path with a .jsonl extension for the jsonl backend.
+func storagePath() string {
+	dbPath := config.C.Paths.Database
+	if run.Backend(config.C.Storage.Backend) == run.BackendJSONL {
+		return strings.TrimSuffix(dbPath, filepath.Ext(dbPath)) + ".jsonl"
+	}
+	return dbPath
+}
+
+// loadRun finds the run a command argument refers to: a run ID, a unique prefix of one,
+// or an alias such as last, last-failed or etl@~2.
+func loadRun(store run.Repository, ref string) (*run.WorkflowRun, error) {
+	wr, err := run.Resolve(store, ref)
+	if err == nil {
+		return wr, nil
+	}
+
+	logger.L().Error("failed to resolve run", zap.String("run", ref), zap.Error(err))
+	var ambiguous *run.AmbiguousRunError
+	switch {
+	case errors.As(err, &ambiguous):
+		return nil, err
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("run '%s' not found", ref)
+	default:
+		return nil, fmt.Errorf("failed to find run '%s': %w", ref, err)
+	}
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 
-	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/dag"
 	"github.com/joelfokou/workflow/internal/executor"
 	"github.com/joelfokou/workflow/internal/logger"
@@ -19,25 +20,64 @@ import (
 var (
 	runDryRun bool
 	runJSON   bool
+	runFile   string
+	runRev    string
+
+	runOnly  []string
+	runFrom  []string
+	runUntil []string
+	runSkip  []string
+	runTags  []string
 )
 
 // runCmd executes a specified workflow by loading its definition, setting up a context with cancellation support, handling interrupts (Ctrl+C), and then running the workflow using an executor.
 var runCmd = &cobra.Command{
-	Use:   "run <workflow>",
+	Use:   "run [workflow | path | -]",
 	Short: "Run a workflow",
-	Args:  cobra.ExactArgs(1),
+	Long: `Run a workflow by name, from a file path, or from stdin.
+
+A bare name is resolved in the configured workflows directory. Paths such as
+./ci/build.toml, or any path given with --file, are loaded directly. Use "-"
+to read the TOML definition from standard input.
+
+With --rev, the workflow file is read as it existed at a git revision instead
+of from the working tree. The commit SHA and whether the working tree had
+uncommitted changes are recorded with the run.
+
+A subset of tasks can be selected. --only runs exactly the named tasks, --from
+runs tasks and everything downstream, --until runs tasks and everything they
+depend on, --tags runs tasks carrying any of the tags and --skip excludes tasks.
+Dependencies outside the selection are treated as already satisfied.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		workflowName := args[0]
+		workflowName, err := workflowRef(args, runFile)
+		if err != nil {
+			return err
+		}
 
 		// Load workflow DAG
-		d, err := dag.Load(workflowName)
+		var d *dag.DAG
+		if runRev != "" {
+			d, err = dag.LoadRevision(workflowName, runRev)
+		} else {
+			d, err = loadWorkflowRef(workflowName, runFile != "")
+		}
 		if err != nil {
 			logger.L().Error("failed to load workflow", zap.String("workflow", workflowName), zap.Error(err))
 			return err
 		}
+		d.DetectGitState()
+
+		sel := dag.Selection{
+			Only:  runOnly,
+			From:  runFrom,
+			Until: runUntil,
+			Skip:  runSkip,
+			Tags:  runTags,
+		}
 
 		if runDryRun {
-			plan, err := planRun(d)
+			plan, err := planRun(d, sel)
 			if err != nil {
 				logger.L().Error("failed to generate execution plan", zap.String("workflow", workflowName), zap.Error(err))
 				return fmt.Errorf("failed to generate execution plan: %w", err)
@@ -65,19 +105,25 @@ var runCmd = &cobra.Command{
 		}()
 
 		// Initialise run store
-		dbPath := config.C.Paths.Database
-		store, err := run.NewStore(dbPath)
+		store, err := openRunStore()
 		if err != nil {
-			logger.L().Error("failed to initialise run store", zap.Error(err))
-			return fmt.Errorf("failed to initialise run store: %w", err)
+			return err
 		}
 		defer store.Close()
 
+		warnStaleRuns(store)
+
 		// Create executor and run workflow
 		executor := executor.NewExecutor(store)
-		if err := executor.Run(ctx, d); err != nil {
-			logger.L().Error("workflow execution failed", zap.String("workflow", workflowName), zap.Error(err))
-			return err
+		executor.Selection = sel
+		runErr := executor.Run(ctx, d)
+
+		// Prune old runs whether or not this one succeeded
+		applyRetention(store)
+
+		if runErr != nil {
+			logger.L().Error("workflow execution failed", zap.String("workflow", workflowName), zap.Error(runErr))
+			return runErr
 		}
 
 		return nil
@@ -89,20 +135,68 @@ func init() {
 
 	runCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "Print execution plan without running tasks")
 	runCmd.Flags().BoolVar(&runJSON, "json", false, "Output in JSON format")
+	runCmd.Flags().StringVarP(&runFile, "file", "f", "", "Path to a workflow file (\"-\" reads from stdin)")
+	runCmd.Flags().StringVar(&runRev, "rev", "", "Run the workflow as it existed at a git revision")
+	runCmd.Flags().StringSliceVar(&runOnly, "only", nil, "Run only these tasks (comma-separated)")
+	runCmd.Flags().StringSliceVar(&runFrom, "from", nil, "Run these tasks and everything downstream of them")
+	runCmd.Flags().StringSliceVar(&runUntil, "until", nil, "Run these tasks and everything they depend on")
+	runCmd.Flags().StringSliceVar(&runSkip, "skip", nil, "Skip these tasks")
+	runCmd.Flags().StringSliceVar(&runTags, "tags", nil, "Run only tasks with any of these tags (\"!tag\" excludes a tag)")
+}
 
+// workflowRef returns the workflow reference from either the positional argument or the --file flag.
+func workflowRef(args []string, file string) (string, error) {
+	switch {
+	case file != "" && len(args) > 0:
+		return "", fmt.Errorf("specify a workflow either as an argument or with --file, not both")
+	case file != "":
+		return file, nil
+	case len(args) == 1:
+		return args[0], nil
+	default:
+		return "", fmt.Errorf("a workflow name, path or \"-\" is required")
+	}
 }
 
-func planRun(d *dag.DAG) (*run.WorkflowPlan, error) {
-	order, err := d.TopologicalSort()
-	if err != nil {
-		return nil, err
+// loadWorkflowRef loads a workflow reference. When explicitFile is set the reference
+// is always treated as a path (or stdin), never as a name in the workflows directory.
+func loadWorkflowRef(ref string, explicitFile bool) (*dag.DAG, error) {
+	if explicitFile && ref != dag.StdinSource {
+		return dag.LoadFile(ref)
 	}
 
+	return dag.Resolve(ref)
+}
+
+// planRun computes the execution plan of d, restricted to the tasks matched by sel.
+func planRun(d *dag.DAG, sel dag.Selection) (*run.WorkflowPlan, error) {
 	plan := &run.WorkflowPlan{
 		Workflow: d.Name,
 		Tasks:    []run.TaskPlan{},
 	}
 
+	if !sel.Empty() {
+		selected, err := d.Select(sel)
+		if err != nil {
+			return nil, err
+		}
+
+		for name := range d.Tasks {
+			if _, ok := selected.Tasks[name]; !ok {
+				plan.Skipped = append(plan.Skipped, name)
+			}
+		}
+		sort.Strings(plan.Skipped)
+
+		plan.Selection = sel.String()
+		d = selected
+	}
+
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
 	for i, t := range order {
 		plan.Tasks = append(plan.Tasks, run.TaskPlan{
 			Order:     i + 1,
@@ -110,6 +204,7 @@ func planRun(d *dag.DAG) (*run.WorkflowPlan, error) {
 			Cmd:       t.Cmd,
 			DependsOn: t.DependsOn,
 			Retries:   t.Retries,
+			Priority:  t.Priority,
 		})
 	}
 	return plan, nil
@@ -118,6 +213,12 @@ func planRun(d *dag.DAG) (*run.WorkflowPlan, error) {
 func printPlan(plan *run.WorkflowPlan) {
 	fmt.Print("========== DRY RUN MODE ==========\n\n")
 	fmt.Printf("Execution Plan for Workflow: %s\n", plan.Workflow)
+	if plan.Selection != "" {
+		fmt.Printf("Selection: %s\n", plan.Selection)
+		if len(plan.Skipped) > 0 {
+			fmt.Printf("Skipped: %s\n", strings.Join(plan.Skipped, ", "))
+		}
+	}
 	fmt.Println("--------------------------------------------------")
 	for _, task := range plan.Tasks {
 		fmt.Printf("Task %d: %s\n", task.Order, task.Name)
@@ -126,6 +227,9 @@ func printPlan(plan *run.WorkflowPlan) {
 			fmt.Printf("  Depends On: %v\n", task.DependsOn)
 		}
 		fmt.Printf("  Retries: %d\n", task.Retries)
+		if task.Priority != 0 {
+			fmt.Printf("  Priority: %d\n", task.Priority)
+		}
 		fmt.Println("--------------------------------------------------")
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/logger"
@@ -20,22 +21,34 @@ var (
 	runsLimit    int
 	runsOffset   int
 	runsJSON     bool
+
+	runsStale           bool
+	runsMarkInterrupted bool
 )
 
 // runsCmd lists workflow runs with filtering and pagination.
 var runsCmd = &cobra.Command{
 	Use:   "runs",
 	Short: "List workflow runs",
-	Long:  "List all workflow runs with optional filtering by workflow name and status",
+	Long: `List all workflow runs with optional filtering by workflow name and status.
+
+With --stale, list only runs still marked running whose process has died or has sent no
+heartbeat for runs.stale_after, e.g. after a crash or reboot. Add --mark-interrupted to
+mark them interrupted so they can be resumed with 'wf resume'.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dbPath := config.C.Paths.Database
-		store, err := run.NewStore(dbPath)
+		store, err := openRunStore()
 		if err != nil {
-			logger.L().Error("failed to initialise run store", zap.Error(err))
-			return fmt.Errorf("failed to initialise run store: %w", err)
+			return err
 		}
 		defer store.Close()
 
+		if runsMarkInterrupted && !runsStale {
+			return fmt.Errorf("--mark-interrupted requires --stale")
+		}
+		if runsStale {
+			return listStaleRuns(store)
+		}
+
 		runs, err := store.ListRuns(runsWorkflow, runsStatus, runsLimit, runsOffset)
 		if err != nil {
 			logger.L().Error("failed to list runs", zap.Error(err))
@@ -55,11 +68,107 @@ var runsCmd = &cobra.Command{
 	},
 }
 
+// listStaleRuns lists stale runs and, with --mark-interrupted, marks them interrupted.
+func listStaleRuns(store run.Repository) error {
+	runs, err := store.ListStaleRuns(staleAfter())
+	if err != nil {
+		logger.L().Error("failed to list stale runs", zap.Error(err))
+		return fmt.Errorf("failed to list stale runs: %w", err)
+	}
+
+	if runsWorkflow != "" {
+		filtered := runs[:0]
+		for _, r := range runs {
+			if r.Workflow == runsWorkflow {
+				filtered = append(filtered, r)
+			}
+		}
+		runs = filtered
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No stale runs found")
+		return nil
+	}
+
+	if runsMarkInterrupted {
+		for _, r := range runs {
+			if err := store.MarkInterrupted(r); err != nil {
+				logger.L().Error("failed to mark run interrupted", zap.String("run_id", r.ID), zap.Error(err))
+				return fmt.Errorf("failed to mark run '%s' interrupted: %w", r.ID, err)
+			}
+			logger.L().Info("marked run interrupted", zap.String("run_id", r.ID), zap.String("workflow", r.Workflow))
+			fmt.Printf("✓ Marked run %s (%s) as interrupted\n", r.ID, r.Workflow)
+		}
+		fmt.Println("\nResume with 'wf resume <run-id>'.")
+		return nil
+	}
+
+	if runsJSON {
+		return printRunsJSON(runs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "RUN ID\tWORKFLOW\tSTARTED AT\tLAST HEARTBEAT\tHOST\tPID\n")
+	fmt.Fprintf(w, "------\t--------\t----------\t--------------\t----\t---\n")
+
+	for _, r := range runs {
+		host, pid := "-", "-"
+		if r.Host.Valid && r.Host.String != "" {
+			host = r.Host.String
+		}
+		if r.PID.Valid {
+			pid = fmt.Sprintf("%d", r.PID.Int64)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.ID,
+			r.Workflow,
+			r.StartedAt.Format("2006-01-02 15:04:05"),
+			r.LastSeen().Format("2006-01-02 15:04:05"),
+			host,
+			pid,
+		)
+	}
+	w.Flush()
+
+	fmt.Println("\nMark them interrupted with 'wf runs --stale --mark-interrupted', then resume with 'wf resume <run-id>'.")
+	return nil
+}
+
+// warnStaleRuns reports runs left running by a process that crashed or whose machine
+// rebooted, so they do not go unnoticed.
+func warnStaleRuns(store run.Repository) {
+	runs, err := store.ListStaleRuns(staleAfter())
+	if err != nil {
+		logger.L().Warn("failed to check for stale runs", zap.Error(err))
+		return
+	}
+	if len(runs) == 0 {
+		return
+	}
+
+	ids := make([]string, len(runs))
+	for i, r := range runs {
+		ids[i] = r.ID
+	}
+	logger.L().Warn("found stale runs", zap.Strings("run_ids", ids))
+	fmt.Fprintf(os.Stderr, "⚠ %d run(s) were left running by a process that no longer responds; see 'wf runs --stale'\n", len(runs))
+}
+
+// staleAfter returns how long a running run may go without a heartbeat before it is considered stale.
+func staleAfter() time.Duration {
+	if config.C.Runs.StaleAfter > 0 {
+		return config.C.Runs.StaleAfter
+	}
+	return run.DefaultStaleAfter
+}
+
 // printRunsTable displays runs in a formatted table.
 func printRunsTable(runs []*run.WorkflowRun) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-	fmt.Fprintf(w, "RUN ID\tWORKFLOW\tSTATUS\tSTARTED AT\tDURATION\n")
-	fmt.Fprintf(w, "------\t--------\t------\t----------\t--------\n")
+	fmt.Fprintf(w, "RUN ID\tWORKFLOW\tSTATUS\tSTARTED AT\tDURATION\tCOMMIT\n")
+	fmt.Fprintf(w, "------\t--------\t------\t----------\t--------\t------\n")
 
 	for _, r := range runs {
 		duration := "-"
@@ -68,12 +177,13 @@ func printRunsTable(runs []*run.WorkflowRun) error {
 			duration = fmt.Sprintf("%.2fs", d.Seconds())
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
 			r.ID,
 			r.Workflow,
 			coloriseStatus(r.Status),
 			r.StartedAt.Format("2006-01-02 15:04:05"),
 			duration,
+			shortCommit(r),
 		)
 	}
 
@@ -101,6 +211,22 @@ func printRunsJSON(runs []*run.WorkflowRun) error {
 	return nil
 }
 
+// shortCommit formats the git commit of a run as a short SHA, marked with "*" if the working tree was dirty.
+func shortCommit(r *run.WorkflowRun) string {
+	if !r.GitCommit.Valid || r.GitCommit.String == "" {
+		return "-"
+	}
+
+	commit := r.GitCommit.String
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if r.GitDirty.Bool {
+		commit += "*"
+	}
+	return commit
+}
+
 // coloriseStatus adds color to status strings for better readability.
 func coloriseStatus(status run.WorkflowStatus) string {
 	switch status {
@@ -110,6 +236,8 @@ func coloriseStatus(status run.WorkflowStatus) string {
 		return "✗ " + string(status)
 	case run.StatusRunning:
 		return "⟳ " + string(status)
+	case run.StatusInterrupted:
+		return "⚠ " + string(status)
 	default:
 		return string(status)
 	}
@@ -119,8 +247,10 @@ func init() {
 	rootCmd.AddCommand(runsCmd)
 
 	runsCmd.Flags().StringVarP(&runsWorkflow, "workflow", "w", "", "Filter by workflow name")
-	runsCmd.Flags().StringVarP(&runsStatus, "status", "s", "", "Filter by status (pending|running|success|failed)")
+	runsCmd.Flags().StringVarP(&runsStatus, "status", "s", "", "Filter by status (pending|running|success|failed|interrupted)")
 	runsCmd.Flags().IntVarP(&runsLimit, "limit", "l", 10, "Limit number of results")
 	runsCmd.Flags().IntVarP(&runsOffset, "offset", "o", 0, "Offset for pagination")
 	runsCmd.Flags().BoolVar(&runsJSON, "json", false, "Output in JSON format")
+	runsCmd.Flags().BoolVar(&runsStale, "stale", false, "List only runs left running by a process that died or stopped sending heartbeats")
+	runsCmd.Flags().BoolVar(&runsMarkInterrupted, "mark-interrupted", false, "Mark the stale runs as interrupted so they can be resumed")
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	schemaOutput string
+)
+
+// schemaCmd prints the JSON Schema for workflow definitions, for editor completion and CI validation.
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for workflow files",
+	Long: `Print the JSON Schema describing workflow definitions. Editors can use it to complete
+and check TOML, YAML and JSON workflow files as they are written.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := json.MarshalIndent(dag.Schema(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode schema: %w", err)
+		}
+		data = append(data, '\n')
+
+		if schemaOutput == "" {
+			_, err := os.Stdout.Write(data)
+			return err
+		}
+
+		if err := os.WriteFile(schemaOutput, data, 0644); err != nil {
+			logger.L().Error("failed to write schema", zap.String("path", schemaOutput), zap.Error(err))
+			return fmt.Errorf("failed to write schema to %s: %w", schemaOutput, err)
+		}
+
+		fmt.Printf("✓ Schema written to %s\n", schemaOutput)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().StringVarP(&schemaOutput, "output", "o", "", "Write the schema to a file instead of stdout")
+}
@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	showDefinition bool
+	showFormat     string
+	showJSON       bool
+)
+
+// showCmd displays the details of a single workflow run.
+var showCmd = &cobra.Command{
+	Use:   "show <run_id>",
+	Short: "Show details of a workflow run",
+	Long: `Show the metadata of a workflow run, its tasks in execution order with their status,
+attempts, duration and exit code, and its DAG marked with the status of each task.
+
+With --definition, print the exact workflow definition the run executed instead.`,
+	Example: `  wf show 3f9c2a1e
+  wf show 3f9c2a1e --json
+  wf show 3f9c2a1e --definition --format yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openRunStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		workflowRun, err := loadRun(store, args[0])
+		if err != nil {
+			return err
+		}
+		runID := workflowRun.ID
+
+		if showDefinition {
+			return printRunDefinition(workflowRun, showFormat)
+		}
+
+		taskRuns, err := store.LoadTaskRuns(runID)
+		if err != nil {
+			logger.L().Error("failed to load task runs", zap.String("run_id", runID), zap.Error(err))
+			return fmt.Errorf("failed to load task runs of '%s': %w", runID, err)
+		}
+
+		d, tasks := runTasks(workflowRun, taskRuns)
+
+		if showJSON {
+			return printRunJSON(workflowRun, tasks)
+		}
+
+		printRunSummary(workflowRun)
+		fmt.Println()
+		printTaskTable(tasks)
+		if d != nil {
+			fmt.Println()
+			printRunGraph(d, tasks)
+		}
+		return nil
+	},
+}
+
+// printRunDefinition prints the workflow definition snapshot stored with a run.
+func printRunDefinition(wr *run.WorkflowRun, formatName string) error {
+	if !wr.Definition.Valid {
+		return fmt.Errorf("run '%s' has no stored definition (recorded by an earlier version)", wr.ID)
+	}
+
+	format, err := dag.ParseFormat(formatName)
+	if err != nil {
+		return err
+	}
+
+	d, err := dag.FromSnapshot([]byte(wr.Definition.String))
+	if err != nil {
+		logger.L().Error("failed to decode run definition", zap.String("run_id", wr.ID), zap.Error(err))
+		return err
+	}
+
+	data, err := d.Encode(format)
+	if err != nil {
+		return fmt.Errorf("failed to encode definition as %s: %w", format, err)
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// printRunSummary prints the metadata of a run.
+func printRunSummary(wr *run.WorkflowRun) {
+	fmt.Printf("Run:       %s\n", wr.ID)
+	fmt.Printf("Workflow:  %s\n", wr.Workflow)
+	fmt.Printf("Status:    %s\n", coloriseStatus(wr.Status))
+	fmt.Printf("Started:   %s\n", wr.StartedAt.Format("2006-01-02 15:04:05"))
+
+	if wr.EndedAt.Valid {
+		fmt.Printf("Ended:     %s\n", wr.EndedAt.Time.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Duration:  %.2fs\n", wr.EndedAt.Time.Sub(wr.StartedAt).Seconds())
+	}
+
+	if wr.ExitCode.Valid {
+		fmt.Printf("Exit code: %d\n", wr.ExitCode.Int64)
+	}
+
+	fmt.Printf("Hash:      %s\n", wr.WorkflowHash)
+
+	if wr.Source.Valid {
+		fmt.Printf("Source:    %s\n", wr.Source.String)
+	}
+
+	if wr.Selection.Valid {
+		var sel dag.Selection
+		if err := json.Unmarshal([]byte(wr.Selection.String), &sel); err == nil {
+			fmt.Printf("Selection: %s\n", sel.String())
+		}
+	}
+
+	if params := runParams(wr); params != "" {
+		fmt.Printf("Params:    %s\n", params)
+	}
+
+	if wr.Host.Valid && wr.Host.String != "" {
+		host := wr.Host.String
+		if wr.PID.Valid {
+			host += fmt.Sprintf(" (pid %d)", wr.PID.Int64)
+		}
+		fmt.Printf("Host:      %s\n", host)
+	}
+
+	if wr.Origin.Valid {
+		fmt.Printf("Origin:    %s (imported)\n", wr.Origin.String)
+	}
+
+	if wr.GitCommit.Valid {
+		fmt.Printf("Commit:    %s\n", wr.GitCommit.String)
+		if wr.GitRev.Valid {
+			fmt.Printf("Revision:  %s\n", wr.GitRev.String)
+		}
+		if wr.GitDirty.Bool {
+			fmt.Println("Worktree:  dirty (uncommitted changes)")
+		}
+	}
+}
+
+// runParams formats the metadata recorded with a run as sorted key=value pairs.
+func runParams(wr *run.WorkflowRun) string {
+	meta, err := wr.UnmarshalMeta()
+	if err != nil || len(meta) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", k, meta[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// taskStatusSkipped marks a task of the definition left out of the run by its selection.
+const taskStatusSkipped run.TaskStatus = "skipped"
+
+// runTask is a task of a run: its definition, if the run stored one, and its latest attempt,
+// if it started.
+type runTask struct {
+	Name      string
+	DependsOn []string
+	Status    run.TaskStatus
+	TaskRun   *run.TaskRun
+}
+
+// runTasks returns the DAG a run executed, or nil if it stored no definition, and its tasks
+// in execution order. Tasks that never started are pending, or skipped if the run's selection
+// excluded them. Task runs missing from the definition follow in the order they started.
+func runTasks(wr *run.WorkflowRun, taskRuns []run.TaskRun) (*dag.DAG, []runTask) {
+	byName := map[string]*run.TaskRun{}
+	for i := range taskRuns {
+		byName[taskRuns[i].Name] = &taskRuns[i]
+	}
+
+	var tasks []runTask
+	d, order := runDefinition(wr)
+	if d != nil {
+		selected := selectedTasks(wr, d)
+		for _, t := range order {
+			task := runTask{Name: t.Name, DependsOn: t.DependsOn, Status: run.TaskPending, TaskRun: byName[t.Name]}
+			switch {
+			case task.TaskRun != nil:
+				task.Status = task.TaskRun.Status
+			case selected != nil && !selected[t.Name]:
+				task.Status = taskStatusSkipped
+			}
+			tasks = append(tasks, task)
+			delete(byName, t.Name)
+		}
+	}
+
+	var rest []*run.TaskRun
+	for _, tr := range byName {
+		rest = append(rest, tr)
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		if !rest[i].StartedAt.Equal(rest[j].StartedAt) {
+			return rest[i].StartedAt.Before(rest[j].StartedAt)
+		}
+		return rest[i].ID < rest[j].ID
+	})
+	for _, tr := range rest {
+		tasks = append(tasks, runTask{Name: tr.Name, Status: tr.Status, TaskRun: tr})
+	}
+
+	return d, tasks
+}
+
+// runDefinition decodes the definition snapshot of a run and sorts its tasks, returning nil
+// if the run stored no definition or it cannot be decoded.
+func runDefinition(wr *run.WorkflowRun) (*dag.DAG, []*dag.Task) {
+	if !wr.Definition.Valid {
+		return nil, nil
+	}
+
+	d, err := dag.FromSnapshot([]byte(wr.Definition.String))
+	if err != nil {
+		logger.L().Warn("failed to decode run definition", zap.String("run_id", wr.ID), zap.Error(err))
+		return nil, nil
+	}
+
+	order, err := d.TopologicalSort()
+	if err != nil {
+		logger.L().Warn("failed to sort run definition", zap.String("run_id", wr.ID), zap.Error(err))
+		return nil, nil
+	}
+	return d, order
+}
+
+// selectedTasks returns the tasks of d the run's selection included, or nil if it ran them all.
+func selectedTasks(wr *run.WorkflowRun, d *dag.DAG) map[string]bool {
+	if !wr.Selection.Valid || wr.Selection.String == "" {
+		return nil
+	}
+
+	var sel dag.Selection
+	if err := json.Unmarshal([]byte(wr.Selection.String), &sel); err != nil || sel.Empty() {
+		return nil
+	}
+
+	sub, err := d.Select(sel)
+	if err != nil {
+		return nil
+	}
+
+	selected := map[string]bool{}
+	for name := range sub.Tasks {
+		selected[name] = true
+	}
+	return selected
+}
+
+// taskDuration returns how long a task run took, or false if it has not finished.
+func taskDuration(tr *run.TaskRun) (time.Duration, bool) {
+	if tr == nil || !tr.EndedAt.Valid {
+		return 0, false
+	}
+	return tr.EndedAt.Time.Sub(tr.StartedAt), true
+}
+
+// printTaskTable displays the tasks of a run in execution order.
+func printTaskTable(tasks []runTask) {
+	if len(tasks) == 0 {
+		fmt.Println("No tasks recorded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "TASK\tSTATUS\tATTEMPTS\tDURATION\tEXIT CODE\n")
+	fmt.Fprintf(w, "----\t------\t--------\t--------\t---------\n")
+
+	for _, t := range tasks {
+		attempts, duration, exitCode := "-", "-", "-"
+		if t.TaskRun != nil {
+			attempts = fmt.Sprintf("%d", t.TaskRun.Attempts)
+			if t.TaskRun.ExitCode.Valid {
+				exitCode = fmt.Sprintf("%d", t.TaskRun.ExitCode.Int64)
+			}
+		}
+		if d, ok := taskDuration(t.TaskRun); ok {
+			duration = fmt.Sprintf("%.2fs", d.Seconds())
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Name, coloriseTaskStatus(t.Status), attempts, duration, exitCode)
+	}
+	w.Flush()
+}
+
+// printRunGraph displays the DAG of a run with each task marked with its status.
+func printRunGraph(d *dag.DAG, tasks []runTask) {
+	status := map[string]run.TaskStatus{}
+	for _, t := range tasks {
+		status[t.Name] = t.Status
+	}
+
+	fmt.Print(d.RenderASCIIWith(func(name string) string {
+		return taskMarker(status[name]) + " " + name
+	}))
+}
+
+// taskMarker returns the symbol marking a task status in the run graph.
+func taskMarker(status run.TaskStatus) string {
+	switch status {
+	case run.TaskSuccess:
+		return "✓"
+	case run.TaskFailed:
+		return "✗"
+	case run.TaskRunning:
+		return "⟳"
+	case run.TaskInterrupted:
+		return "⚠"
+	case taskStatusSkipped:
+		return "-"
+	default:
+		return "·"
+	}
+}
+
+// coloriseTaskStatus prefixes a task status with its marker, as coloriseStatus does for runs.
+func coloriseTaskStatus(status run.TaskStatus) string {
+	return taskMarker(status) + " " + string(status)
+}
+
+// runDetailJSON is the JSON form of wf show.
+type runDetailJSON struct {
+	Run   json.RawMessage `json:"run"`
+	Tasks []taskJSON      `json:"tasks"`
+}
+
+type taskJSON struct {
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	DependsOn []string   `json:"depends_on"`
+	Attempts  int        `json:"attempts"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Duration  *float64   `json:"duration_seconds,omitempty"`
+	ExitCode  *int64     `json:"exit_code,omitempty"`
+	LogPath   string     `json:"log_path,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// printRunJSON outputs a run and its tasks in execution order in JSON format.
+func printRunJSON(wr *run.WorkflowRun, tasks []runTask) error {
+	data, err := run.MarshalRun(wr)
+	if err != nil {
+		return err
+	}
+
+	out := runDetailJSON{Run: data, Tasks: []taskJSON{}}
+	for _, t := range tasks {
+		task := taskJSON{Name: t.Name, Status: string(t.Status), DependsOn: t.DependsOn}
+		if task.DependsOn == nil {
+			task.DependsOn = []string{}
+		}
+		if tr := t.TaskRun; tr != nil {
+			task.Attempts = tr.Attempts
+			task.StartedAt = &tr.StartedAt
+			task.LogPath = tr.LogPath
+			task.LastError = tr.LastError
+			if tr.EndedAt.Valid {
+				task.EndedAt = &tr.EndedAt.Time
+			}
+			if tr.ExitCode.Valid {
+				task.ExitCode = &tr.ExitCode.Int64
+			}
+		}
+		if d, ok := taskDuration(t.TaskRun); ok {
+			seconds := d.Seconds()
+			task.Duration = &seconds
+		}
+		out.Tasks = append(out.Tasks, task)
+	}
+
+	encoded, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+
+	logger.L().Info("displayed run in JSON", zap.String("run_id", wr.ID), zap.Int("tasks", len(tasks)))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().BoolVar(&showDefinition, "definition", false, "Print the workflow definition the run executed")
+	showCmd.Flags().StringVarP(&showFormat, "format", "f", "toml", "Definition format: toml, yaml, or json")
+	showCmd.Flags().BoolVar(&showJSON, "json", false, "Output the run and its tasks in JSON format")
+}
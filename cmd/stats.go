@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	statsLast int
+	statsDays int
+	statsJSON bool
+)
+
+// statsFlakiest is the number of tasks listed as the flakiest.
+const statsFlakiest = 5
+
+// statsCmd summarises the recent runs of a workflow.
+var statsCmd = &cobra.Command{
+	Use:   "stats <workflow>",
+	Short: "Show run statistics and duration trends of a workflow",
+	Long: `Summarise the most recent finished runs of a workflow: the success rate overall and per
+day, the trend of run durations, the p50, p95 and maximum duration of each task, and the
+flakiest tasks, those that needed the most retries.
+
+Trends are drawn as sparklines, oldest run first. Use --json to export the statistics.`,
+	Example: `  wf stats etl
+  wf stats etl --last 200 --days 30
+  wf stats etl --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workflow := args[0]
+		if statsLast <= 0 {
+			return fmt.Errorf("--last must be positive")
+		}
+		if statsDays <= 0 {
+			return fmt.Errorf("--days must be positive")
+		}
+
+		store, err := openRunStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		stats, err := run.Stats(store, workflow, statsLast)
+		if err != nil {
+			logger.L().Error("failed to compute run statistics", zap.String("workflow", workflow), zap.Error(err))
+			return fmt.Errorf("failed to compute statistics of %s: %w", workflow, err)
+		}
+
+		logger.L().Info("computed run statistics",
+			zap.String("workflow", workflow),
+			zap.Int("runs", stats.Runs),
+			zap.Int("tasks", len(stats.Tasks)),
+		)
+
+		if statsJSON {
+			return printStatsJSON(stats)
+		}
+		if stats.Runs == 0 {
+			fmt.Printf("No finished runs of %s found\n", workflow)
+			return nil
+		}
+		printStats(stats)
+		return nil
+	},
+}
+
+// printStats displays the statistics of a workflow.
+func printStats(stats *run.WorkflowStats) {
+	durations := make([]time.Duration, len(stats.Durations))
+	for i, s := range stats.Durations {
+		durations[i] = s.Duration
+	}
+
+	fmt.Printf("Workflow:     %s\n", stats.Workflow)
+	fmt.Printf("Runs:         %d, from %s to %s\n", stats.Runs, stats.First.Format("2006-01-02 15:04"), stats.Last.Format("2006-01-02 15:04"))
+	fmt.Printf("Success rate: %s (%d success, %d failed", formatRate(stats.SuccessRate()), stats.Succeeded, stats.Failed)
+	if stats.Interrupted > 0 {
+		fmt.Printf(", %d interrupted", stats.Interrupted)
+	}
+	fmt.Println(")")
+	if len(durations) > 0 {
+		fmt.Printf("Duration:     p50 %s  p95 %s  max %s\n",
+			formatSeconds(run.Percentile(durations, 50)),
+			formatSeconds(run.Percentile(durations, 95)),
+			formatSeconds(run.Percentile(durations, 100)),
+		)
+		fmt.Printf("Trend:        %s  (%d runs, oldest first)\n", durationSparkline(durations), len(durations))
+	}
+
+	days := stats.Days
+	if len(days) > statsDays {
+		days = days[len(days)-statsDays:]
+	}
+	fmt.Println("\n--- Success Rate by Day ---")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "DATE\tRUNS\tSUCCESS\tRATE\t\n")
+	fmt.Fprintf(w, "----\t----\t-------\t----\t\n")
+	for _, d := range days {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", d.Date, d.Runs, d.Succeeded, formatRate(d.SuccessRate()), rateBar(d.SuccessRate()))
+	}
+	w.Flush()
+
+	if len(stats.Tasks) > 0 {
+		fmt.Println("\n--- Tasks ---")
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "TASK\tRUNS\tFAILED\tRETRIES\tP50\tP95\tMAX\tTREND\n")
+		fmt.Fprintf(w, "----\t----\t------\t-------\t---\t---\t---\t-----\n")
+		for _, t := range stats.Tasks {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n",
+				t.Name,
+				t.Runs,
+				t.Failed,
+				t.Retries,
+				formatSeconds(t.Percentile(50)),
+				formatSeconds(t.Percentile(95)),
+				formatSeconds(t.Max()),
+				durationSparkline(t.Durations),
+			)
+		}
+		w.Flush()
+	}
+
+	flaky := stats.Flakiest(statsFlakiest)
+	fmt.Println("\n--- Flakiest Tasks ---")
+	if len(flaky) == 0 {
+		fmt.Println("No task needed a retry")
+		return
+	}
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "TASK\tRETRIES\tRETRIED RUNS\tFAILED\n")
+	fmt.Fprintf(w, "----\t-------\t------------\t------\n")
+	for _, t := range flaky {
+		fmt.Fprintf(w, "%s\t%d\t%d/%d\t%d\n", t.Name, t.Retries, t.Retried, t.Runs, t.Failed)
+	}
+	w.Flush()
+}
+
+// statsJSONOutput is the JSON form of the statistics of a workflow.
+type statsJSONOutput struct {
+	Workflow    string          `json:"workflow"`
+	Runs        int             `json:"runs"`
+	Succeeded   int             `json:"succeeded"`
+	Failed      int             `json:"failed"`
+	Interrupted int             `json:"interrupted"`
+	SuccessRate float64         `json:"success_rate"`
+	First       *time.Time      `json:"first_run,omitempty"`
+	Last        *time.Time      `json:"last_run,omitempty"`
+	Days        []dayStatsJSON  `json:"days"`
+	Durations   []runSampleJSON `json:"durations"`
+	Tasks       []taskStatsJSON `json:"tasks"`
+	Flakiest    []string        `json:"flakiest"`
+}
+
+type dayStatsJSON struct {
+	Date        string  `json:"date"`
+	Runs        int     `json:"runs"`
+	Succeeded   int     `json:"succeeded"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+type runSampleJSON struct {
+	RunID     string    `json:"run_id"`
+	StartedAt time.Time `json:"started_at"`
+	Status    string    `json:"status"`
+	Seconds   float64   `json:"duration_seconds"`
+}
+
+type taskStatsJSON struct {
+	Name       string    `json:"name"`
+	Runs       int       `json:"runs"`
+	Failed     int       `json:"failed"`
+	Retries    int       `json:"retries"`
+	Retried    int       `json:"retried_runs"`
+	P50Seconds float64   `json:"p50_seconds"`
+	P95Seconds float64   `json:"p95_seconds"`
+	MaxSeconds float64   `json:"max_seconds"`
+	Seconds    []float64 `json:"duration_seconds"`
+}
+
+// printStatsJSON outputs the statistics of a workflow in JSON format.
+func printStatsJSON(stats *run.WorkflowStats) error {
+	out := statsJSONOutput{
+		Workflow:    stats.Workflow,
+		Runs:        stats.Runs,
+		Succeeded:   stats.Succeeded,
+		Failed:      stats.Failed,
+		Interrupted: stats.Interrupted,
+		SuccessRate: stats.SuccessRate(),
+		Days:        []dayStatsJSON{},
+		Durations:   []runSampleJSON{},
+		Tasks:       []taskStatsJSON{},
+		Flakiest:    []string{},
+	}
+	if stats.Runs > 0 {
+		out.First, out.Last = &stats.First, &stats.Last
+	}
+	for _, d := range stats.Days {
+		out.Days = append(out.Days, dayStatsJSON{Date: d.Date, Runs: d.Runs, Succeeded: d.Succeeded, SuccessRate: d.SuccessRate()})
+	}
+	for _, s := range stats.Durations {
+		out.Durations = append(out.Durations, runSampleJSON{RunID: s.RunID, StartedAt: s.StartedAt, Status: string(s.Status), Seconds: s.Duration.Seconds()})
+	}
+	for _, t := range stats.Tasks {
+		task := taskStatsJSON{
+			Name:       t.Name,
+			Runs:       t.Runs,
+			Failed:     t.Failed,
+			Retries:    t.Retries,
+			Retried:    t.Retried,
+			P50Seconds: t.Percentile(50).Seconds(),
+			P95Seconds: t.Percentile(95).Seconds(),
+			MaxSeconds: t.Max().Seconds(),
+			Seconds:    []float64{},
+		}
+		for _, d := range t.Durations {
+			task.Seconds = append(task.Seconds, d.Seconds())
+		}
+		out.Tasks = append(out.Tasks, task)
+	}
+	for _, t := range stats.Flakiest(statsFlakiest) {
+		out.Flakiest = append(out.Flakiest, t.Name)
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// sparkBlocks are the levels of a sparkline, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// durationSparkline draws durations as a sparkline scaled between the shortest and longest.
+func durationSparkline(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return "-"
+	}
+
+	lo, hi := durations[0], durations[0]
+	for _, d := range durations {
+		lo, hi = min(lo, d), max(hi, d)
+	}
+
+	var b strings.Builder
+	for _, d := range durations {
+		level := 0
+		if hi > lo {
+			level = int(float64(d-lo) / float64(hi-lo) * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// rateBar draws a rate between 0 and 1 as a bar ten characters wide.
+func rateBar(rate float64) string {
+	filled := int(rate*10 + 0.5)
+	return strings.Repeat("█", filled) + strings.Repeat("░", 10-filled)
+}
+
+// formatRate formats a rate between 0 and 1 as a percentage.
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+// formatSeconds formats a duration in seconds, as run durations are shown elsewhere.
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().IntVarP(&statsLast, "last", "n", 50, "Number of most recent runs to analyse")
+	statsCmd.Flags().IntVar(&statsDays, "days", 14, "Number of most recent days shown in the success rate table")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Output in JSON format")
+}
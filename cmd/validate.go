@@ -2,9 +2,9 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
 	"text/tabwriter"
 
 	"github.com/joelfokou/workflow/internal/config"
@@ -19,15 +19,17 @@ var (
 )
 
 // validateResult holds the result of validating a single workflow.
+// Diagnostics lists every error and warning with its position, for editors and CI.
 type validateResult struct {
-	Name  string `json:"name"`
-	Valid bool   `json:"valid"`
-	Error string `json:"error,omitempty"`
+	Name        string           `json:"name"`
+	Valid       bool             `json:"valid"`
+	Error       string           `json:"error,omitempty"`
+	Diagnostics []dag.Diagnostic `json:"diagnostics,omitempty"`
 }
 
 // validateCmd checks the validity of all workflow definitions in the configured workflows directory, logging errors if any are found and confirming success if all workflows are valid.
 var validateCmd = &cobra.Command{
-	Use:   "validate [workflow]",
+	Use:   "validate [workflow | path | -]",
 	Short: "Validate workflow definitions",
 	Long:  "Validate all workflows or a specific workflow in the configured workflows directory",
 	Args:  cobra.MaximumNArgs(1),
@@ -42,7 +44,7 @@ var validateCmd = &cobra.Command{
 
 // validateSingleWorkflow validates a specific workflow.
 func validateSingleWorkflow(workflowName string) error {
-	d, err := dag.Load(workflowName)
+	d, err := dag.Resolve(workflowName)
 	if err != nil {
 		logger.L().Error("workflow validation failed",
 			zap.String("workflow", workflowName),
@@ -50,17 +52,22 @@ func validateSingleWorkflow(workflowName string) error {
 		)
 
 		result := validateResult{
-			Name:  workflowName,
-			Valid: false,
-			Error: err.Error(),
+			Name:        workflowName,
+			Valid:       false,
+			Error:       err.Error(),
+			Diagnostics: diagnosticsOf(err),
 		}
 
 		if validateJSON {
-			return printValidateJSON([]validateResult{result})
+			if err := printValidateJSON([]validateResult{result}); err != nil {
+				return err
+			}
+			return fmt.Errorf("workflow %s is invalid", workflowName)
 		}
 
-		fmt.Printf("✗ %s: %v\n", workflowName, err)
-		return err
+		fmt.Printf("✗ %s: %s\n", workflowName, problemCount(result.Diagnostics))
+		printDiagnostics(result.Diagnostics)
+		return fmt.Errorf("workflow %s is invalid", workflowName)
 	}
 
 	// Additional validation checks
@@ -72,9 +79,10 @@ func validateSingleWorkflow(workflowName string) error {
 		)
 
 		result := validateResult{
-			Name:  workflowName,
-			Valid: false,
-			Error: err.Error(),
+			Name:        workflowName,
+			Valid:       false,
+			Error:       err.Error(),
+			Diagnostics: diagnosticsOf(err),
 		}
 
 		if validateJSON {
@@ -86,8 +94,9 @@ func validateSingleWorkflow(workflowName string) error {
 	}
 
 	result := validateResult{
-		Name:  workflowName,
-		Valid: true,
+		Name:        workflowName,
+		Valid:       true,
+		Diagnostics: d.Warnings,
 	}
 
 	logger.L().Info("workflow validation successful",
@@ -101,12 +110,13 @@ func validateSingleWorkflow(workflowName string) error {
 	}
 
 	fmt.Printf("✓ %s: valid (%d tasks)\n", workflowName, len(d.Tasks))
+	printDiagnostics(d.Warnings)
 	return nil
 }
 
 // validateAllWorkflows validates all workflows in the directory.
 func validateAllWorkflows() error {
-	entries, err := os.ReadDir(config.C.Paths.Workflows)
+	entries, err := dag.Discover()
 	if err != nil {
 		logger.L().Error("failed to read workflows directory",
 			zap.String("directory", config.C.Paths.Workflows),
@@ -119,12 +129,8 @@ func validateAllWorkflows() error {
 	var failedCount int
 
 	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".toml") {
-			continue
-		}
-
-		workflowName := strings.TrimSuffix(entry.Name(), ".toml")
-		d, err := dag.Load(workflowName)
+		workflowName := entry.Name
+		d, err := dag.LoadFile(entry.Path)
 
 		if err != nil {
 			logger.L().Warn("workflow validation failed",
@@ -133,9 +139,10 @@ func validateAllWorkflows() error {
 			)
 
 			results = append(results, validateResult{
-				Name:  workflowName,
-				Valid: false,
-				Error: err.Error(),
+				Name:        workflowName,
+				Valid:       false,
+				Error:       err.Error(),
+				Diagnostics: diagnosticsOf(err),
 			})
 			failedCount++
 			continue
@@ -149,17 +156,19 @@ func validateAllWorkflows() error {
 			)
 
 			results = append(results, validateResult{
-				Name:  workflowName,
-				Valid: false,
-				Error: err.Error(),
+				Name:        workflowName,
+				Valid:       false,
+				Error:       err.Error(),
+				Diagnostics: diagnosticsOf(err),
 			})
 			failedCount++
 			continue
 		}
 
 		results = append(results, validateResult{
-			Name:  workflowName,
-			Valid: true,
+			Name:        workflowName,
+			Valid:       true,
+			Diagnostics: d.Warnings,
 		})
 	}
 
@@ -182,7 +191,17 @@ func printValidateTable(results []validateResult, failedCount int) error {
 
 		if !r.Valid {
 			status = "✗ invalid"
-			errMsg = truncateError(r.Error, 50)
+			switch len(r.Diagnostics) {
+			case 0:
+				errMsg = truncateError(r.Error, 50)
+			case 1:
+				errMsg = truncateError(r.Diagnostics[0].Message, 50)
+			default:
+				errMsg = truncateError(fmt.Sprintf("%s (+%d more)", r.Diagnostics[0].Message, len(r.Diagnostics)-1), 50)
+			}
+		} else if len(r.Diagnostics) > 0 {
+			status = "✓ valid"
+			errMsg = fmt.Sprintf("%d warning(s)", len(r.Diagnostics))
 		}
 
 		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, status, errMsg)
@@ -190,6 +209,15 @@ func printValidateTable(results []validateResult, failedCount int) error {
 
 	w.Flush()
 
+	var diags []dag.Diagnostic
+	for _, r := range results {
+		diags = append(diags, r.Diagnostics...)
+	}
+	if len(diags) > 0 {
+		fmt.Println()
+		printDiagnostics(diags)
+	}
+
 	fmt.Printf("\n%d/%d workflows valid\n", len(results)-failedCount, len(results))
 
 	if failedCount > 0 {
@@ -208,6 +236,37 @@ func printValidateJSON(results []validateResult) error {
 	return encoder.Encode(results)
 }
 
+// diagnosticsOf returns the diagnostics carried by a load error, or the error itself as a
+// single diagnostic when it has no position, such as a missing file.
+func diagnosticsOf(err error) []dag.Diagnostic {
+	var verr *dag.ValidationError
+	if errors.As(err, &verr) {
+		return verr.Diagnostics
+	}
+	return []dag.Diagnostic{{Severity: dag.SeverityError, Message: err.Error()}}
+}
+
+// printDiagnostics prints one diagnostic per line as "file:line:column: message".
+func printDiagnostics(diags []dag.Diagnostic) {
+	for _, d := range diags {
+		fmt.Printf("  %s\n", d)
+	}
+}
+
+// problemCount describes the number of errors in a list of diagnostics.
+func problemCount(diags []dag.Diagnostic) string {
+	n := 0
+	for _, d := range diags {
+		if d.Severity == dag.SeverityError {
+			n++
+		}
+	}
+	if n == 1 {
+		return "1 problem"
+	}
+	return fmt.Sprintf("%d problems", n)
+}
+
 // truncateError truncates error messages to a maximum length.
 func truncateError(msg string, maxLen int) string {
 	if len(msg) > maxLen {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -17,9 +18,37 @@ type Paths struct {
 	LogsFile  string `mapstructure:"logs_file"`
 }
 
+// ProjectConfig controls discovery of project-local workflows.
+type ProjectConfig struct {
+	Discovery     bool `mapstructure:"discovery"`      // Walk up from the working directory to find a project
+	LocalDatabase bool `mapstructure:"local_database"` // Store runs and logs under the project's .workflow/ directory
+}
+
+// RunsConfig controls the bookkeeping of workflow runs.
+type RunsConfig struct {
+	StaleAfter time.Duration `mapstructure:"stale_after"` // A running run without a heartbeat for this long is considered interrupted
+}
+
+// StorageConfig selects how workflow runs are recorded.
+type StorageConfig struct {
+	Backend string `mapstructure:"backend"` // sqlite (the database at paths.database), jsonl (an append-only file next to it) or memory
+}
+
+// RetentionConfig prunes old runs automatically after each wf run. Runs are pruned only
+// if they match every limit set; with neither older_than nor keep_last set, nothing is pruned.
+type RetentionConfig struct {
+	OlderThan string `mapstructure:"older_than"` // Age such as 30d, 2w or 12h
+	KeepLast  int    `mapstructure:"keep_last"`  // Most recent runs of each workflow always kept
+	Status    string `mapstructure:"status"`     // Only prune runs with this status
+}
+
 type Config struct {
-	LogLevel string `mapstructure:"log_level"`
-	Paths    Paths  `mapstructure:"paths"`
+	LogLevel  string          `mapstructure:"log_level"`
+	Paths     Paths           `mapstructure:"paths"`
+	Project   ProjectConfig   `mapstructure:"project"`
+	Runs      RunsConfig      `mapstructure:"runs"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Retention RetentionConfig `mapstructure:"retention"`
 }
 
 var C Config
@@ -56,6 +85,29 @@ paths:
   logs_file: %s
 
 log_level: info
+
+project:
+  # Discover .workflow/ or workflow.toml by walking up to the git repository root
+  discovery: true
+  # Keep the run database and logs inside the project's .workflow/ directory
+  local_database: false
+
+runs:
+  # A running run whose process has sent no heartbeat for this long is considered interrupted
+  stale_after: 1m
+
+storage:
+  # Where runs are recorded: sqlite (the database above), jsonl (an append-only
+  # JSON-lines file next to it, with a .jsonl extension) or memory (not persisted)
+  backend: sqlite
+
+retention:
+  # Prune runs after each 'wf run'; a run is pruned only if it matches every limit set.
+  # Leave older_than empty and keep_last at 0 to keep every run.
+  older_than: ""
+  keep_last: 0
+  # Only prune runs with this status (success, failed, interrupted); empty for any
+  status: ""
 `, filepath.Join(getDefaultDataDir(), "workflows"),
 		filepath.Join(getDefaultDataDir(), "logs"),
 		filepath.Join(getDefaultDataDir(), "workflow.db"),
@@ -74,6 +126,13 @@ func Load(configFilePath ...string) error {
 	viper.SetDefault("paths.logs", filepath.Join(dataDir, "logs"))
 	viper.SetDefault("paths.database", filepath.Join(dataDir, "workflow.db"))
 	viper.SetDefault("paths.logs_file", filepath.Join(dataDir, "logs", "workflow.log"))
+	viper.SetDefault("project.discovery", true)
+	viper.SetDefault("project.local_database", false)
+	viper.SetDefault("runs.stale_after", time.Minute)
+	viper.SetDefault("storage.backend", "sqlite")
+	viper.SetDefault("retention.older_than", "")
+	viper.SetDefault("retention.keep_last", 0)
+	viper.SetDefault("retention.status", "")
 
 	// Environment variables
 	viper.SetEnvPrefix("WF")
@@ -86,13 +145,92 @@ func Load(configFilePath ...string) error {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 
-	// Use optional config file if provided
-	if len(configFilePath) > 0 && configFilePath[0] != "" {
-		viper.SetConfigFile(configFilePath[0])
+	explicitConfig := ""
+	if len(configFilePath) > 0 {
+		explicitConfig = configFilePath[0]
+	}
+
+	// Ignore error if the global config file doesn't exist
+	var explicit *viper.Viper
+	if explicitConfig == "" {
+		_ = viper.ReadInConfig()
+	} else {
+		// Use optional config file if provided; it is merged before project discovery so
+		// that it can disable it.
+		explicit = viper.New()
+		explicit.SetConfigFile(explicitConfig)
+		_ = explicit.ReadInConfig()
+		mergeConfigFile(explicitConfig)
+	}
+
+	// Project config overrides the global config file
+	P = nil
+	if viper.GetBool("project.discovery") {
+		if err := loadProject(); err != nil {
+			return err
+		}
+		// The explicit config file takes precedence over the project config
+		if explicit != nil {
+			mergeConfigFile(explicitConfig)
+		}
+	}
+
+	if err := viper.Unmarshal(&C); err != nil {
+		return err
+	}
+
+	// The project-local database and logs do not override paths set explicitly
+	if P != nil && C.Project.LocalDatabase {
+		if !setExplicitly("paths.database", explicit) {
+			C.Paths.Database = filepath.Join(P.StateDir(), "workflow.db")
+		}
+		if !setExplicitly("paths.logs", explicit) {
+			C.Paths.Logs = filepath.Join(P.StateDir(), "logs")
+		}
+	}
+
+	return nil
+}
+
+// mergeConfigFile merges a config file into the configuration, ignoring a missing or
+// unreadable file.
+func mergeConfigFile(path string) {
+	viper.SetConfigFile(path)
+	_ = viper.MergeInConfig()
+}
+
+// setExplicitly reports whether key was set by its WF_ environment variable or by the
+// config file passed with --config.
+func setExplicitly(key string, explicit *viper.Viper) bool {
+	env := "WF_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if _, ok := os.LookupEnv(env); ok {
+		return true
+	}
+	return explicit != nil && explicit.IsSet(key)
+}
+
+// loadProject discovers the project enclosing the working directory and merges its config file.
+func loadProject() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	project, err := FindProject(wd)
+	if err != nil {
+		return fmt.Errorf("failed to discover project: %w", err)
 	}
+	if project == nil {
+		return nil
+	}
+	P = project
 
-	// Ignore error if config file doesn't exist
-	_ = viper.ReadInConfig()
+	if cfg := project.ConfigFile(); cfg != "" {
+		viper.SetConfigFile(cfg)
+		if err := viper.MergeInConfig(); err != nil {
+			return fmt.Errorf("failed to read project config %s: %w", cfg, err)
+		}
+	}
 
-	return viper.Unmarshal(&C)
+	return nil
 }
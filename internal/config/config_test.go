@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// TestLoadExplicitConfigPrecedence tests that the --config file can disable project
+// discovery and takes precedence over the project config.
+func TestLoadExplicitConfigPrecedence(t *testing.T) {
+	root := setupProject(t, "log_level: debug\nproject:\n  local_database: true\n")
+
+	explicit := filepath.Join(t.TempDir(), "config.yaml")
+	mustWrite(t, explicit, "log_level: warn\n")
+	if err := Load(explicit); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if P == nil || P.Root != root {
+		t.Fatalf("expected the project at %s, got %+v", root, P)
+	}
+	if C.LogLevel != "warn" {
+		t.Errorf("expected the --config file to override the project log level, got %q", C.LogLevel)
+	}
+
+	mustWrite(t, explicit, "project:\n  discovery: false\n")
+	viper.Reset()
+	if err := Load(explicit); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if P != nil {
+		t.Errorf("expected the --config file to disable project discovery, got %+v", P)
+	}
+}
+
+// TestLoadLocalDatabaseKeepsExplicitPaths tests that project-local paths do not override
+// paths set by environment variables or the --config file.
+func TestLoadLocalDatabaseKeepsExplicitPaths(t *testing.T) {
+	root := setupProject(t, "project:\n  local_database: true\n")
+
+	if err := Load(""); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if C.Paths.Database != filepath.Join(root, ProjectDirName, "workflow.db") || C.Paths.Logs != filepath.Join(root, ProjectDirName, "logs") {
+		t.Errorf("expected project-local paths, got %+v", C.Paths)
+	}
+
+	explicit := filepath.Join(t.TempDir(), "config.yaml")
+	mustWrite(t, explicit, "paths:\n  database: /srv/wf/workflow.db\n")
+	t.Setenv("WF_PATHS_LOGS", "/srv/wf/logs")
+	viper.Reset()
+	if err := Load(explicit); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if C.Paths.Database != "/srv/wf/workflow.db" {
+		t.Errorf("expected the --config database path to be kept, got %s", C.Paths.Database)
+	}
+	if C.Paths.Logs != "/srv/wf/logs" {
+		t.Errorf("expected the WF_PATHS_LOGS path to be kept, got %s", C.Paths.Logs)
+	}
+}
+
+// setupProject creates a project with the given config, makes it the working directory
+// and isolates the global config. It returns the project root.
+func setupProject(t *testing.T, projectConfig string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	root := t.TempDir()
+	mustMkdir(t, filepath.Join(root, ".git"))
+	mustMkdir(t, filepath.Join(root, ProjectDirName))
+	mustWrite(t, filepath.Join(root, ProjectDirName, "config.yaml"), projectConfig)
+	t.Chdir(root)
+	return root
+}
+
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ProjectDirName is the project-local directory holding workflows, config and state.
+	ProjectDirName = ".workflow"
+	// ProjectFileName is a single project-local workflow file.
+	ProjectFileName = "workflow.toml"
+)
+
+// Project describes workflow definitions discovered in the current repository.
+type Project struct {
+	Root string // Directory containing .workflow/ or workflow.toml
+	Dir  string // Path to the .workflow/ directory, empty if absent
+	File string // Path to workflow.toml, empty if absent
+}
+
+// P is the project discovered from the working directory, or nil if there is none.
+var P *Project
+
+// FindProject walks up from start looking for a .workflow/ directory or a workflow.toml file.
+// The search stops at the root of the enclosing git repository. When start is not inside a
+// repository, only start itself is checked, so that a stray .workflow/ in a parent or home
+// directory is not picked up. It returns nil when no project is found.
+func FindProject(start string) (*Project, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := repoRoot(dir)
+	if stop == "" {
+		stop = dir
+	}
+
+	for {
+		p := &Project{Root: dir}
+
+		if info, err := os.Stat(filepath.Join(dir, ProjectDirName)); err == nil && info.IsDir() {
+			p.Dir = filepath.Join(dir, ProjectDirName)
+		}
+		if info, err := os.Stat(filepath.Join(dir, ProjectFileName)); err == nil && info.Mode().IsRegular() {
+			p.File = filepath.Join(dir, ProjectFileName)
+		}
+
+		if p.Dir != "" || p.File != "" {
+			return p, nil
+		}
+
+		if dir == stop {
+			return nil, nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// repoRoot returns the root of the git repository containing dir, or an empty string if
+// dir is not inside one. .git is a directory or, for worktrees, a file.
+func repoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ConfigFile returns the path to the project config file, or an empty string if there is none.
+func (p *Project) ConfigFile() string {
+	if p.Dir == "" {
+		return ""
+	}
+
+	path := filepath.Join(p.Dir, "config.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// StateDir returns the directory used for the project-local database and logs.
+func (p *Project) StateDir() string {
+	return filepath.Join(p.Root, ProjectDirName)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFindProjectWalksUp tests that a .workflow directory is found from a nested directory.
+func TestFindProjectWalksUp(t *testing.T) {
+	root := t.TempDir()
+	mustMkdir(t, filepath.Join(root, ".git"))
+	mustMkdir(t, filepath.Join(root, ProjectDirName))
+	nested := filepath.Join(root, "src", "pkg")
+	mustMkdir(t, nested)
+
+	p, err := FindProject(nested)
+	if err != nil {
+		t.Fatalf("FindProject failed: %v", err)
+	}
+	if p == nil {
+		t.Fatal("expected project to be found")
+	}
+	if p.Root != root {
+		t.Errorf("expected root %s, got %s", root, p.Root)
+	}
+	if p.Dir != filepath.Join(root, ProjectDirName) {
+		t.Errorf("expected dir %s, got %s", filepath.Join(root, ProjectDirName), p.Dir)
+	}
+}
+
+// TestFindProjectWorkflowFile tests that a workflow.toml file marks a project.
+func TestFindProjectWorkflowFile(t *testing.T) {
+	root := t.TempDir()
+	mustMkdir(t, filepath.Join(root, ".git"))
+	if err := os.WriteFile(filepath.Join(root, ProjectFileName), []byte(`name = "build"`), 0644); err != nil {
+		t.Fatalf("failed to write workflow file: %v", err)
+	}
+
+	p, err := FindProject(root)
+	if err != nil {
+		t.Fatalf("FindProject failed: %v", err)
+	}
+	if p == nil || p.File != filepath.Join(root, ProjectFileName) {
+		t.Fatalf("expected workflow.toml project, got %+v", p)
+	}
+	if p.Dir != "" {
+		t.Errorf("expected no .workflow directory, got %s", p.Dir)
+	}
+}
+
+// TestFindProjectStopsAtGitRoot tests that discovery does not escape the repository.
+func TestFindProjectStopsAtGitRoot(t *testing.T) {
+	outer := t.TempDir()
+	mustMkdir(t, filepath.Join(outer, ProjectDirName))
+	repo := filepath.Join(outer, "repo")
+	mustMkdir(t, filepath.Join(repo, ".git"))
+
+	p, err := FindProject(repo)
+	if err != nil {
+		t.Fatalf("FindProject failed: %v", err)
+	}
+	if p != nil {
+		t.Errorf("expected no project outside the git root, got %+v", p)
+	}
+}
+
+// TestFindProjectOutsideGitRepo tests that without a git repository only the start
+// directory is checked.
+func TestFindProjectOutsideGitRepo(t *testing.T) {
+	outer := t.TempDir()
+	mustMkdir(t, filepath.Join(outer, ProjectDirName))
+	nested := filepath.Join(outer, "src")
+	mustMkdir(t, nested)
+
+	p, err := FindProject(nested)
+	if err != nil {
+		t.Fatalf("FindProject failed: %v", err)
+	}
+	if p != nil {
+		t.Errorf("expected no project above a directory outside a git repository, got %+v", p)
+	}
+
+	p, err = FindProject(outer)
+	if err != nil {
+		t.Fatalf("FindProject failed: %v", err)
+	}
+	if p == nil || p.Dir != filepath.Join(outer, ProjectDirName) {
+		t.Errorf("expected the project in the start directory, got %+v", p)
+	}
+}
+
+func mustMkdir(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+}
@@ -0,0 +1,415 @@
+package dag
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// TaskOrder selects the order in which Canonicalise writes tasks.
+type TaskOrder string
+
+const (
+	OrderDeclared    TaskOrder = "declared"    // The order tasks are declared in the file
+	OrderTopological TaskOrder = "topological" // The order tasks run in
+)
+
+// ParseTaskOrder converts a user-supplied order name into a TaskOrder.
+func ParseTaskOrder(s string) (TaskOrder, error) {
+	switch TaskOrder(s) {
+	case OrderDeclared, OrderTopological:
+		return TaskOrder(s), nil
+	default:
+		return "", fmt.Errorf("unsupported task order: %s (supported: declared, topological)", s)
+	}
+}
+
+// tomlComments holds the comments of a TOML definition, keyed by the dotted path of the
+// table or key they belong to.
+type tomlComments struct {
+	leading map[string][]string // comment lines directly above a table or key
+	inline  map[string]string   // comment at the end of a table header or key line
+	footer  []string            // comments after the last table or key
+}
+
+// Canonicalise rewrites a TOML workflow definition in the canonical layout:
+//   - name, strict and concurrency first, then one [tasks.<name>] table per task, then one [groups.<name>] table per group
+//   - task keys in a fixed order: cmd, depends_on, retries, tags, priority
+//   - depends_on, tags and group task lists sorted and de-duplicated
+//   - tasks in declared or topological order, groups sorted by name
+//
+// Comments are kept with the table or key they precede or follow. The definition must be
+// valid, and the result is checked to describe exactly the same workflow.
+func Canonicalise(data []byte, order TaskOrder) ([]byte, error) {
+	wf, err := decodeWorkflow(data, FormatTOML)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := parseWorkflow(data, FormatTOML, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	if len(d.Warnings) > 0 {
+		return nil, fmt.Errorf("cannot format a definition with unknown key %s: it would be lost", d.Warnings[0].Key)
+	}
+
+	comments, err := collectComments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := canonicalTaskOrder(d, order)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		b          bytes.Buffer
+		usedLead   = map[string]bool{}
+		usedInline = map[string]bool{}
+	)
+
+	// writeComments writes the leading comments of path
+	writeComments := func(path string) {
+		usedLead[path] = true
+		for _, c := range comments.leading[path] {
+			b.WriteString(c)
+			b.WriteString("\n")
+		}
+	}
+	// writeLine writes a line followed by the inline comment of path
+	writeLine := func(path, line string) {
+		b.WriteString(line)
+		if c, ok := comments.inline[path]; ok {
+			usedInline[path] = true
+			b.WriteString(" ")
+			b.WriteString(c)
+		}
+		b.WriteString("\n")
+	}
+	// writeDetached writes all comments of a path that has no line of its own
+	writeDetached := func(path string) {
+		writeComments(path)
+		if c, ok := comments.inline[path]; ok {
+			usedInline[path] = true
+			b.WriteString(c)
+			b.WriteString("\n")
+		}
+	}
+	writeKey := func(path, key, value string) {
+		writeComments(path)
+		writeLine(path, key+" = "+value)
+	}
+
+	writeKey("name", "name", quoteTOML(wf.Name))
+	if wf.Strict != nil {
+		writeKey("strict", "strict", strconv.FormatBool(*wf.Strict))
+	}
+	if wf.Concurrency != "" {
+		writeKey("concurrency", "concurrency", quoteTOML(wf.Concurrency))
+	}
+
+	// Comments on a bare [tasks] table or a tasks = { ... } key precede the first task
+	writeDetached("tasks")
+	for _, name := range names {
+		t := wf.Tasks[name]
+		path := "tasks." + name
+
+		b.WriteString("\n")
+		writeComments(path)
+		writeLine(path, "["+tomlTableKey("tasks", name)+"]")
+
+		writeKey(path+".cmd", "cmd", quoteTOML(t.Cmd))
+		if deps := normaliseList(t.DependsOn); len(deps) > 0 {
+			writeKey(path+".depends_on", "depends_on", tomlArray(deps))
+		}
+		if t.Retries != 0 {
+			writeKey(path+".retries", "retries", strconv.Itoa(t.Retries))
+		}
+		if tags := normaliseList(t.Tags); len(tags) > 0 {
+			writeKey(path+".tags", "tags", tomlArray(tags))
+		}
+		if t.Priority != 0 {
+			writeKey(path+".priority", "priority", strconv.Itoa(t.Priority))
+		}
+	}
+
+	writeDetached("groups")
+	for _, g := range d.SortedGroups() {
+		raw := wf.Groups[g.Name]
+		path := "groups." + g.Name
+
+		b.WriteString("\n")
+		writeComments(path)
+		writeLine(path, "["+tomlTableKey("groups", g.Name)+"]")
+
+		if raw.Description != "" {
+			writeKey(path+".description", "description", quoteTOML(raw.Description))
+		}
+		writeKey(path+".tasks", "tasks", tomlArray(normaliseList(raw.Tasks)))
+	}
+
+	// Keep comments that belonged to keys with no place in the canonical layout
+	var leftover []string
+	for path := range comments.leading {
+		if !usedLead[path] {
+			leftover = append(leftover, path)
+		}
+	}
+	for path := range comments.inline {
+		if !usedInline[path] && !containsString(leftover, path) {
+			leftover = append(leftover, path)
+		}
+	}
+	sort.Strings(leftover)
+
+	footer := comments.footer
+	for _, path := range leftover {
+		if !usedLead[path] {
+			footer = append(footer, comments.leading[path]...)
+		}
+		if c, ok := comments.inline[path]; ok && !usedInline[path] {
+			footer = append(footer, c)
+		}
+	}
+	if len(footer) > 0 {
+		b.WriteString("\n")
+		for _, c := range footer {
+			b.WriteString(c)
+			b.WriteString("\n")
+		}
+	}
+
+	out := b.Bytes()
+	if err := sameWorkflow(d, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// canonicalTaskOrder returns the task names of d in the requested order.
+func canonicalTaskOrder(d *DAG, order TaskOrder) ([]string, error) {
+	names := d.taskNames()
+
+	switch order {
+	case OrderTopological:
+		tasks, err := d.TopologicalSort()
+		if err != nil {
+			return nil, err
+		}
+		names = names[:0]
+		for _, t := range tasks {
+			names = append(names, t.Name)
+		}
+	default:
+		sort.SliceStable(names, func(i, j int) bool {
+			return d.Tasks[names[i]].Position < d.Tasks[names[j]].Position
+		})
+	}
+
+	return names, nil
+}
+
+// sameWorkflow checks that a formatted definition describes the same workflow as d,
+// ignoring duplicate list entries, which the formatter removes.
+func sameWorkflow(d *DAG, formatted []byte) error {
+	f, err := parseWorkflow(formatted, FormatTOML, "")
+	if err != nil {
+		return fmt.Errorf("formatted definition does not parse: %w", err)
+	}
+
+	want, err := normalisedHash(d)
+	if err != nil {
+		return err
+	}
+	got, err := normalisedHash(f)
+	if err != nil {
+		return err
+	}
+	if got != want || f.Lenient != d.Lenient || f.Concurrency != d.Concurrency {
+		return fmt.Errorf("formatting changed the workflow definition")
+	}
+	return nil
+}
+
+// normalisedHash hashes d with duplicate dependencies, tags and group members removed.
+func normalisedHash(d *DAG) (string, error) {
+	n := *d
+	n.Tasks = make(map[string]*Task, len(d.Tasks))
+	for name, t := range d.Tasks {
+		c := *t
+		c.DependsOn = normaliseList(t.DependsOn)
+		c.Tags = normaliseList(t.Tags)
+		n.Tasks[name] = &c
+	}
+	n.Groups = make(map[string]*Group, len(d.Groups))
+	for name, g := range d.Groups {
+		n.Groups[name] = &Group{Name: g.Name, Description: g.Description, Tasks: normaliseList(g.Tasks)}
+	}
+	return n.ComputeHash()
+}
+
+// collectComments records the comments of a TOML definition. Comments on their own lines
+// belong to the next table or key; a comment after a table header or key on the same line
+// belongs to it. Comments inside multi-line arrays are moved above their key.
+func collectComments(data []byte) (*tomlComments, error) {
+	c := &tomlComments{
+		leading: map[string][]string{},
+		inline:  map[string]string{},
+	}
+
+	p := unstable.Parser{KeepComments: true}
+	p.Reset(data)
+
+	keyParts := func(n *unstable.Node) []string {
+		var parts []string
+		it := n.Key()
+		for it.Next() {
+			parts = append(parts, string(it.Node().Data))
+		}
+		return parts
+	}
+
+	var (
+		pending []string
+		table   []string
+	)
+	for p.NextExpression() {
+		e := p.Expression()
+
+		var path string
+		switch e.Kind {
+		case unstable.Comment:
+			pending = append(pending, commentText(e))
+			continue
+		case unstable.Table, unstable.ArrayTable:
+			table = keyParts(e)
+			path = strings.Join(table, ".")
+		case unstable.KeyValue:
+			path = strings.Join(append(append([]string{}, table...), keyParts(e)...), ".")
+			pending = append(pending, nestedComments(e.Value())...)
+		default:
+			continue
+		}
+
+		if len(pending) > 0 {
+			c.leading[path] = append(c.leading[path], pending...)
+			pending = nil
+		}
+		if next := e.Next(); next != nil && next.Kind == unstable.Comment {
+			c.inline[path] = commentText(next)
+		}
+	}
+	if err := p.Error(); err != nil {
+		return nil, err
+	}
+
+	c.footer = pending
+	return c, nil
+}
+
+// nestedComments returns the comments inside an array or inline table value.
+func nestedComments(v *unstable.Node) []string {
+	var out []string
+
+	it := v.Children()
+	for it.Next() {
+		n := it.Node()
+		switch n.Kind {
+		case unstable.Comment:
+			out = append(out, commentText(n))
+			for more := n.Child(); more != nil; more = more.Next() {
+				out = append(out, commentText(more))
+			}
+		case unstable.Array:
+			out = append(out, nestedComments(n)...)
+		case unstable.KeyValue:
+			out = append(out, nestedComments(n.Value())...)
+		}
+	}
+
+	return out
+}
+
+// commentText returns the text of a comment node, including the leading #.
+func commentText(n *unstable.Node) string {
+	return strings.TrimRight(string(n.Data), " \t\r\n")
+}
+
+// normaliseList sorts a list and removes duplicates.
+func normaliseList(list []string) []string {
+	var out []string
+	for _, v := range list {
+		if !containsString(out, v) {
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// tomlTableKey formats a two-part table key, quoting the name if it is not a bare key.
+func tomlTableKey(table, name string) string {
+	if taskNamePattern.MatchString(name) {
+		return table + "." + name
+	}
+	return table + "." + quoteTOML(name)
+}
+
+// tomlArray formats a list of strings as an inline TOML array.
+func tomlArray(list []string) string {
+	quoted := make([]string, len(list))
+	for i, v := range list {
+		quoted[i] = quoteTOML(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// quoteTOML formats a string as a TOML string. Strings containing quotes or backslashes
+// use a literal string where possible so commands stay readable; strings spanning
+// several lines use a multi-line literal string.
+func quoteTOML(s string) string {
+	printable := !strings.ContainsFunc(s, func(r rune) bool {
+		return (r < 0x20 && r != '\t' && r != '\n') || r == 0x7f
+	})
+
+	switch {
+	case printable && strings.Contains(s, "\n") && !strings.Contains(s, "'''") && !strings.HasSuffix(s, "'"):
+		return "'''\n" + s + "'''"
+	case printable && !strings.Contains(s, "\n") && strings.ContainsAny(s, `"\`) && !strings.Contains(s, "'"):
+		return "'" + s + "'"
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package dag
+
+import "fmt"
+
+// Concurrency controls whether runs of the same workflow may execute at the same time.
+type Concurrency string
+
+const (
+	ConcurrencyAllow  Concurrency = "allow"  // Runs may overlap (the default)
+	ConcurrencyForbid Concurrency = "forbid" // A run fails to start while another run holds the workflow
+	ConcurrencyQueue  Concurrency = "queue"  // A run waits for the run holding the workflow to finish
+)
+
+// concurrencyPolicies lists the accepted values of the concurrency key.
+var concurrencyPolicies = []string{string(ConcurrencyAllow), string(ConcurrencyForbid), string(ConcurrencyQueue)}
+
+// ParseConcurrency converts a concurrency value into a Concurrency; empty means ConcurrencyAllow.
+func ParseConcurrency(s string) (Concurrency, error) {
+	switch Concurrency(s) {
+	case "":
+		return ConcurrencyAllow, nil
+	case ConcurrencyAllow, ConcurrencyForbid, ConcurrencyQueue:
+		return Concurrency(s), nil
+	default:
+		return "", fmt.Errorf("unsupported concurrency: %s (supported: allow, forbid, queue)", s)
+	}
+}
+
+// ConcurrencyPolicy returns the concurrency policy of the workflow, ConcurrencyAllow if unset.
+func (d *DAG) ConcurrencyPolicy() Concurrency {
+	if d.Concurrency == "" {
+		return ConcurrencyAllow
+	}
+	return d.Concurrency
+}
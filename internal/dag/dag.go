@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strings"
 )
@@ -14,51 +15,198 @@ type Task struct {
 	Cmd       string   `json:"cmd"`
 	DependsOn []string `json:"depends_on"`
 	Retries   int      `json:"retries"`
+	Tags      []string `json:"tags,omitempty"`
+	Priority  int      `json:"priority,omitempty"`
+
+	// DependsOnRefs is depends_on as declared when it references groups, such as
+	// "group:ingest"; DependsOn holds the tasks they expand to. Encoding writes the
+	// declared references, so that converted definitions keep following the group.
+	DependsOnRefs []string `json:"-"`
+
+	// Position is the index of the task in declaration order within the workflow file.
+	// Ready tasks with equal priority run in this order.
+	Position int `json:"-"`
 }
 
 type DAG struct {
 	Name  string           `json:"name"`
 	Tasks map[string]*Task `json:"tasks"`
+
+	// Groups bundles tasks by name. Group references in dependencies are expanded when the workflow is loaded.
+	Groups map[string]*Group `json:"groups,omitempty"`
+
+	// Concurrency controls whether runs of the workflow may overlap; empty means ConcurrencyAllow.
+	Concurrency Concurrency `json:"concurrency,omitempty"`
+
+	// Source is the absolute path the definition was loaded from, or "-" for stdin.
+	// It is empty for workflows parsed from a string.
+	Source string `json:"-"`
+
+	// Namespace is the subdirectory the workflow was loaded from, e.g. "etl" for etl/daily.toml.
+	Namespace string `json:"-"`
+	// Ref is the name the workflow was loaded by, derived from its path, e.g. "etl/daily" for
+	// etl/daily.toml. It is empty for workflows loaded from a path, stdin or a string.
+	Ref string `json:"-"`
+
+	// Revision is the git revision the definition was read from, empty for the working tree.
+	Revision string `json:"-"`
+	// Commit is the git commit SHA of the definition: the resolved Revision, or HEAD for working tree definitions.
+	Commit string `json:"-"`
+	// Dirty reports whether the working tree had uncommitted changes when the definition was loaded.
+	Dirty bool `json:"-"`
+
+	// Lenient reports whether the definition set strict = false, so unknown keys are
+	// warnings rather than errors.
+	Lenient bool `json:"-"`
+
+	// Warnings lists problems found while loading the definition that do not stop it from
+	// running, such as unknown keys in a lenient definition.
+	Warnings []Diagnostic `json:"-"`
+
+	// positions locates keys of the definition for diagnostics; nil if it was not parsed from source.
+	positions *sourceMap
+	// parseErrors lists problems found while parsing, reported by Validate with the rest.
+	parseErrors []Diagnostic
 }
 
-// ComputeHash generates a SHA-256 hash representing the current state of the DAG.
-func (d *DAG) ComputeHash() (string, error) {
-	type taskSnapshot struct {
-		Name      string   `json:"name"`
-		Cmd       string   `json:"cmd"`
-		DependsOn []string `json:"depends_on"`
-		Retries   int      `json:"retries"`
+// QualifiedName returns the name identifying the runs of the workflow. For a workflow loaded
+// by name it is the path-derived name shown by wf list, e.g. "etl/daily" for etl/daily.toml,
+// whatever name the file declares; otherwise it is the declared name, prefixed with its namespace.
+func (d *DAG) QualifiedName() string {
+	if d.Ref != "" {
+		return d.Ref
 	}
-
-	type dagSnapshot struct {
-		Name  string         `json:"name"`
-		Tasks []taskSnapshot `json:"tasks"`
+	if d.Namespace == "" {
+		return d.Name
 	}
+	return d.Namespace + "/" + d.Name
+}
 
+// taskSnapshot is the normalised representation of a task used for hashing and run snapshots.
+type taskSnapshot struct {
+	Name      string   `json:"name"`
+	Cmd       string   `json:"cmd"`
+	DependsOn []string `json:"depends_on"`
+	Refs      []string `json:"depends_on_refs,omitempty"`
+	Retries   int      `json:"retries"`
+	Tags      []string `json:"tags,omitempty"`
+	Priority  int      `json:"priority,omitempty"`
+	Position  int      `json:"position,omitempty"`
+}
+
+// dagSnapshot is the normalised representation of a DAG used for hashing and run snapshots.
+type dagSnapshot struct {
+	Name        string         `json:"name"`
+	Concurrency Concurrency    `json:"concurrency,omitempty"`
+	Tasks       []taskSnapshot `json:"tasks"`
+	Groups      []Group        `json:"groups,omitempty"`
+}
+
+// Snapshot serialises the DAG into its normalised JSON form, with tasks and dependencies
+// sorted by name. The declaration position of each task is included so that a DAG rebuilt
+// with FromSnapshot runs its tasks in the same order.
+func (d *DAG) Snapshot() ([]byte, error) {
+	return json.Marshal(d.snapshot(true))
+}
+
+// snapshot builds the normalised representation of the DAG. Positions are left out of
+// the hash, so reordering tasks in a file does not count as a definition change.
+func (d *DAG) snapshot(positions bool) dagSnapshot {
 	// Create sorted task list for consistent hashing
 	var tasks []taskSnapshot
 	for _, t := range d.Tasks {
 		deps := make([]string, len(t.DependsOn))
 		copy(deps, t.DependsOn)
 		sort.Strings(deps)
+		var tags []string
+		if len(t.Tags) > 0 {
+			tags = make([]string, len(t.Tags))
+			copy(tags, t.Tags)
+			sort.Strings(tags)
+		}
+		var refs []string
+		if t.DependsOnRefs != nil {
+			refs = make([]string, len(t.DependsOnRefs))
+			copy(refs, t.DependsOnRefs)
+			sort.Strings(refs)
+		}
 		tasks = append(tasks, taskSnapshot{
 			Name:      t.Name,
 			Cmd:       t.Cmd,
 			DependsOn: deps,
+			Refs:      refs,
 			Retries:   t.Retries,
+			Tags:      tags,
+			Priority:  t.Priority,
 		})
+		if positions {
+			tasks[len(tasks)-1].Position = t.Position
+		}
 	}
 
 	sort.Slice(tasks, func(i, j int) bool {
 		return tasks[i].Name < tasks[j].Name
 	})
 
-	snapshot := dagSnapshot{
-		Name:  d.Name,
-		Tasks: tasks,
+	var groups []Group
+	for _, g := range d.SortedGroups() {
+		members := make([]string, len(g.Tasks))
+		copy(members, g.Tasks)
+		sort.Strings(members)
+		groups = append(groups, Group{Name: g.Name, Description: g.Description, Tasks: members})
 	}
 
-	data, err := json.Marshal(snapshot)
+	return dagSnapshot{
+		Name:        d.Name,
+		Concurrency: d.Concurrency,
+		Tasks:       tasks,
+		Groups:      groups,
+	}
+}
+
+// FromSnapshot rebuilds a DAG from a normalised snapshot produced by Snapshot.
+func FromSnapshot(data []byte) (*DAG, error) {
+	var snapshot dagSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode workflow snapshot: %w", err)
+	}
+
+	d := &DAG{
+		Name:        snapshot.Name,
+		Concurrency: snapshot.Concurrency,
+		Tasks:       make(map[string]*Task, len(snapshot.Tasks)),
+	}
+	for _, t := range snapshot.Tasks {
+		d.Tasks[t.Name] = &Task{
+			Name:      t.Name,
+			Cmd:       t.Cmd,
+			DependsOn: t.DependsOn,
+			Retries:   t.Retries,
+			Tags:      t.Tags,
+			Priority:  t.Priority,
+			Position:  t.Position,
+
+			DependsOnRefs: t.Refs,
+		}
+	}
+	for _, g := range snapshot.Groups {
+		if d.Groups == nil {
+			d.Groups = make(map[string]*Group, len(snapshot.Groups))
+		}
+		group := g
+		d.Groups[g.Name] = &group
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid workflow snapshot: %w", err)
+	}
+
+	return d, nil
+}
+
+// ComputeHash generates a SHA-256 hash representing the current state of the DAG.
+func (d *DAG) ComputeHash() (string, error) {
+	data, err := json.Marshal(d.snapshot(false))
 	if err != nil {
 		return "", err
 	}
@@ -69,8 +217,15 @@ func (d *DAG) ComputeHash() (string, error) {
 
 // Graph generates a simple textual representation of the DAG structure.
 func (d *DAG) Graph() string {
+	names := make([]string, 0, len(d.Tasks))
+	for name := range d.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	out := ""
-	for _, t := range d.Tasks {
+	for _, name := range names {
+		t := d.Tasks[name]
 		if len(t.DependsOn) == 0 {
 			out += t.Name + "\n"
 			continue
@@ -82,7 +237,7 @@ func (d *DAG) Graph() string {
 	return out
 }
 
-// Roots returns all tasks with no dependencies.
+// Roots returns all tasks with no dependencies, in the order they would run.
 func (d *DAG) Roots() []*Task {
 	var roots []*Task
 	for _, t := range d.Tasks {
@@ -90,11 +245,20 @@ func (d *DAG) Roots() []*Task {
 			roots = append(roots, t)
 		}
 	}
+	sort.Slice(roots, func(i, j int) bool {
+		return runsBefore(roots[i], roots[j])
+	})
 	return roots
 }
 
 // RenderASCII generates an ASCII representation of the DAG structure.
 func (d *DAG) RenderASCII() string {
+	return d.RenderASCIIWith(func(name string) string { return name })
+}
+
+// RenderASCIIWith generates an ASCII representation of the DAG structure, printing each
+// task as label returns it, e.g. to annotate tasks with the status of a run.
+func (d *DAG) RenderASCIIWith(label func(name string) string) string {
 	var b strings.Builder
 
 	children := map[string][]string{}
@@ -104,21 +268,18 @@ func (d *DAG) RenderASCII() string {
 		}
 	}
 
-	var render func(node string, prefix string)
-	render = func(node string, prefix string) {
-		b.WriteString(prefix + node + "\n")
+	// render writes node after edge, and its children indented by prefix.
+	var render func(node, edge, prefix string)
+	render = func(node, edge, prefix string) {
+		b.WriteString(edge + label(node) + "\n")
 		kids := children[node]
 		sort.Strings(kids)
 		for i, k := range kids {
-			last := i == len(kids)-1
-			edge := "├── "
-			next := prefix + "│   "
-			if last {
-				edge = "└── "
-				next = prefix + "    "
+			if i == len(kids)-1 {
+				render(k, prefix+"└── ", prefix+"    ")
+			} else {
+				render(k, prefix+"├── ", prefix+"│   ")
 			}
-			b.WriteString(prefix + edge)
-			render(k, next)
 		}
 	}
 
@@ -128,7 +289,7 @@ func (d *DAG) RenderASCII() string {
 	})
 
 	for _, root := range roots {
-		render(root.Name, "")
+		render(root.Name, "", "")
 	}
 
 	return b.String()
@@ -1,12 +1,18 @@
 package dag
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/pelletier/go-toml/v2"
 )
 
 func init() {
@@ -119,6 +125,43 @@ func TestDAGValidateMissingDependency(t *testing.T) {
 	}
 }
 
+// TestDAGValidateMissingDependencyAndCycle tests that a missing dependency does not hide
+// a cycle between other tasks, nor cause one to be reported where there is none.
+func TestDAGValidateMissingDependencyAndCycle(t *testing.T) {
+	d := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a": {Name: "a", Cmd: "echo a", DependsOn: []string{"b"}},
+			"b": {Name: "b", Cmd: "echo b", DependsOn: []string{"a"}},
+			"c": {Name: "c", Cmd: "echo c", DependsOn: []string{"nonexistent"}},
+		},
+	}
+
+	var verr *ValidationError
+	if err := d.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	var missing, cycle bool
+	for _, diag := range verr.Diagnostics {
+		missing = missing || strings.Contains(diag.Message, "missing task nonexistent")
+		cycle = cycle || strings.Contains(diag.Message, "cycle detected")
+	}
+	if !missing || !cycle {
+		t.Errorf("expected both the missing dependency and the cycle, got %v", verr.Diagnostics)
+	}
+
+	delete(d.Tasks, "a")
+	d.Tasks["b"].DependsOn = nil
+	if err := d.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, diag := range verr.Diagnostics {
+		if strings.Contains(diag.Message, "cycle detected") {
+			t.Errorf("expected no cycle without one, got %v", verr.Diagnostics)
+		}
+	}
+}
+
 // TestDAGValidateEmptyName tests validation catches empty workflow name.
 func TestDAGValidateEmptyName(t *testing.T) {
 	d := &DAG{
@@ -315,3 +358,1180 @@ func TestDAGRoots(t *testing.T) {
 		t.Fatalf("expected 2 root tasks, got %d", len(roots))
 	}
 }
+
+// TestDAGRenderASCIIWith tests that task labels replace task names in the rendered tree.
+func TestDAGRenderASCIIWith(t *testing.T) {
+	d := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a": {Name: "a", Cmd: "echo a"},
+			"b": {Name: "b", Cmd: "echo b", DependsOn: []string{"a"}},
+			"c": {Name: "c", Cmd: "echo c", DependsOn: []string{"a"}},
+			"d": {Name: "d", Cmd: "echo d", DependsOn: []string{"b"}},
+		},
+	}
+
+	if got, want := d.RenderASCII(), "a\n├── b\n│   └── d\n└── c\n"; got != want {
+		t.Errorf("RenderASCII() = %q, want %q", got, want)
+	}
+
+	got := d.RenderASCIIWith(func(name string) string { return "[" + name + "]" })
+	if want := "[a]\n├── [b]\n│   └── [d]\n└── [c]\n"; got != want {
+		t.Errorf("RenderASCIIWith() = %q, want %q", got, want)
+	}
+}
+
+// TestDAGLoadFile tests loading a workflow from an arbitrary path records its absolute source.
+func TestDAGLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	workflowPath := filepath.Join(dir, "ci", "build.toml")
+	if err := os.MkdirAll(filepath.Dir(workflowPath), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	workflowContent := `
+name = "build"
+
+[tasks.compile]
+cmd = "echo compile"
+`
+	if err := os.WriteFile(workflowPath, []byte(workflowContent), 0644); err != nil {
+		t.Fatalf("failed to write workflow file: %v", err)
+	}
+
+	d, err := LoadFile(workflowPath)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	if d.Name != "build" {
+		t.Errorf("expected name 'build', got: %s", d.Name)
+	}
+	if d.Source != workflowPath {
+		t.Errorf("expected source %s, got: %s", workflowPath, d.Source)
+	}
+}
+
+// TestDAGLoadReader tests loading a workflow from a reader such as stdin.
+func TestDAGLoadReader(t *testing.T) {
+	workflowContent := `
+name = "piped"
+
+[tasks.task1]
+cmd = "echo piped"
+`
+
+	d, err := LoadReader(strings.NewReader(workflowContent), StdinSource)
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+
+	if d.Name != "piped" {
+		t.Errorf("expected name 'piped', got: %s", d.Name)
+	}
+	if d.Source != StdinSource {
+		t.Errorf("expected source %q, got: %q", StdinSource, d.Source)
+	}
+}
+
+// TestDAGIsPath tests distinguishing file paths from workflow names.
+func TestDAGIsPath(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "wf.toml")
+	if err := os.WriteFile(existing, []byte(""), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	cases := map[string]bool{
+		"etl":              false,
+		"etl.toml":         false,
+		"./ci/build.toml":  true,
+		"../build.toml":    true,
+		existing:           true,
+		"missing/wf.toml":  false,
+		"nested/workflows": false,
+	}
+
+	for ref, want := range cases {
+		if got := IsPath(ref); got != want {
+			t.Errorf("IsPath(%q): want %v got %v", ref, want, got)
+		}
+	}
+}
+
+// TestDAGDiscoverProjectScope tests that project workflows shadow global workflows.
+func TestDAGDiscoverProjectScope(t *testing.T) {
+	globalDir := t.TempDir()
+	projectRoot := t.TempDir()
+	projectDir := filepath.Join(projectRoot, config.ProjectDirName)
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("failed to create project dir: %v", err)
+	}
+
+	write := func(path, name string) {
+		content := "name = \"" + name + "\"\n\n[tasks.a]\ncmd = \"echo a\"\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write(filepath.Join(globalDir, "shared.toml"), "shared")
+	write(filepath.Join(globalDir, "global-only.toml"), "global-only")
+	write(filepath.Join(projectDir, "shared.toml"), "shared")
+
+	config.C.Paths.Workflows = globalDir
+	config.P = &config.Project{Root: projectRoot, Dir: projectDir}
+	defer func() { config.P = nil }()
+
+	entries, err := Discover()
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	scopes := map[string]string{}
+	for _, e := range entries {
+		scopes[e.Name] = e.Scope
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 workflows, got %d", len(entries))
+	}
+	if scopes["shared"] != ScopeProject {
+		t.Errorf("expected 'shared' from project scope, got %q", scopes["shared"])
+	}
+	if scopes["global-only"] != ScopeGlobal {
+		t.Errorf("expected 'global-only' from global scope, got %q", scopes["global-only"])
+	}
+
+	path, scope := Locate("shared")
+	if scope != ScopeProject || path != filepath.Join(projectDir, "shared.toml") {
+		t.Errorf("expected project path for 'shared', got %s (%s)", path, scope)
+	}
+}
+
+// TestDAGDiscoverNamespaces tests recursive discovery of namespaced workflows.
+func TestDAGDiscoverNamespaces(t *testing.T) {
+	workflowDir := t.TempDir()
+	config.C.Paths.Workflows = workflowDir
+
+	files := map[string]string{
+		"top.toml":          "top",
+		"etl/daily.toml":    "nightly",
+		"etl/raw/dump.toml": "dump",
+		".hidden/skip.toml": "skip",
+	}
+	for rel, name := range files {
+		path := filepath.Join(workflowDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		content := "name = \"" + name + "\"\n\n[tasks.a]\ncmd = \"echo a\"\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	entries, err := Discover()
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+
+	want := []string{"etl/daily", "etl/raw/dump", "top"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+
+	d, err := Load("etl/daily")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Runs are recorded under the name wf list shows, not the name the file declares
+	if d.Name != "nightly" || d.QualifiedName() != "etl/daily" {
+		t.Errorf("expected workflow nightly with qualified name 'etl/daily', got %s and %s", d.Name, d.QualifiedName())
+	}
+
+	if !InNamespace("etl/raw/dump", "etl") || InNamespace("top", "etl") || InNamespace("etlx/a", "etl") {
+		t.Error("InNamespace returned unexpected result")
+	}
+}
+
+// TestDAGLoadYAMLAndJSON tests that YAML and JSON definitions load with the same schema as TOML.
+func TestDAGLoadYAMLAndJSON(t *testing.T) {
+	dir := t.TempDir()
+
+	definitions := map[string]string{
+		"etl.yaml": `
+name: etl
+tasks:
+  extract:
+    cmd: echo extract
+  load:
+    cmd: echo load
+    depends_on: [extract]
+    retries: 2
+`,
+		"etl.yml": `
+name: etl
+tasks:
+  extract: {cmd: echo extract}
+  load: {cmd: echo load, depends_on: [extract], retries: 2}
+`,
+		"etl.json": `{
+  "name": "etl",
+  "tasks": {
+    "extract": {"cmd": "echo extract"},
+    "load": {"cmd": "echo load", "depends_on": ["extract"], "retries": 2}
+  }
+}`,
+	}
+
+	for file, content := range definitions {
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+
+		d, err := LoadFile(path)
+		if err != nil {
+			t.Fatalf("LoadFile(%s) failed: %v", file, err)
+		}
+
+		if d.Name != "etl" || len(d.Tasks) != 2 {
+			t.Errorf("%s: unexpected workflow %s with %d tasks", file, d.Name, len(d.Tasks))
+		}
+		if d.Tasks["load"].Retries != 2 || len(d.Tasks["load"].DependsOn) != 1 {
+			t.Errorf("%s: unexpected load task %+v", file, d.Tasks["load"])
+		}
+	}
+}
+
+// TestDAGEncodeRoundTrip tests that converting between formats preserves the DAG.
+func TestDAGEncodeRoundTrip(t *testing.T) {
+	d := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a": {Name: "a", Cmd: "echo a"},
+			"b": {Name: "b", Cmd: "echo b", DependsOn: []string{"a"}, Retries: 3},
+		},
+	}
+
+	want, err := d.ComputeHash()
+	if err != nil {
+		t.Fatalf("ComputeHash failed: %v", err)
+	}
+
+	for _, format := range []Format{FormatTOML, FormatYAML, FormatJSON} {
+		data, err := d.Encode(format)
+		if err != nil {
+			t.Fatalf("Encode(%s) failed: %v", format, err)
+		}
+
+		decoded, err := LoadReader(strings.NewReader(string(data)), StdinSource)
+		if err != nil {
+			t.Fatalf("LoadReader(%s) failed: %v\n%s", format, err, data)
+		}
+
+		got, err := decoded.ComputeHash()
+		if err != nil {
+			t.Fatalf("ComputeHash failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("%s round trip changed the workflow:\n%s", format, data)
+		}
+	}
+}
+
+// TestDAGEncodeKeepsOrder tests that converting a workflow keeps the declaration order
+// of its tasks, which breaks ties between ready tasks.
+func TestDAGEncodeKeepsOrder(t *testing.T) {
+	src := "name = \"order\"\n\n[tasks.zeta]\ncmd = \"echo zeta\"\n\n[tasks.mid]\ncmd = \"echo mid\"\n\n[tasks.alpha]\ncmd = \"echo alpha\"\n"
+	d, err := LoadBytes([]byte(src), FormatTOML, StdinSource)
+	if err != nil {
+		t.Fatalf("LoadBytes failed: %v", err)
+	}
+
+	want := []string{"zeta", "mid", "alpha"}
+	for _, format := range []Format{FormatTOML, FormatYAML, FormatJSON} {
+		data, err := d.Encode(format)
+		if err != nil {
+			t.Fatalf("Encode(%s) failed: %v", format, err)
+		}
+
+		decoded, err := LoadBytes(data, format, StdinSource)
+		if err != nil {
+			t.Fatalf("LoadBytes(%s) failed: %v\n%s", format, err, data)
+		}
+
+		order, err := canonicalTaskOrder(decoded, OrderDeclared)
+		if err != nil {
+			t.Fatalf("canonicalTaskOrder failed: %v", err)
+		}
+		if strings.Join(order, ",") != strings.Join(want, ",") {
+			t.Errorf("%s round trip changed the task order to %v:\n%s", format, order, data)
+		}
+	}
+}
+
+// TestDAGSnapshotRoundTrip tests that a DAG rebuilt from its snapshot has the same hash.
+func TestDAGSnapshotRoundTrip(t *testing.T) {
+	d := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a": {Name: "a", Cmd: "echo a"},
+			"b": {Name: "b", Cmd: "echo b", DependsOn: []string{"a"}, Retries: 1},
+		},
+	}
+
+	data, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	restored, err := FromSnapshot(data)
+	if err != nil {
+		t.Fatalf("FromSnapshot failed: %v", err)
+	}
+
+	want, _ := d.ComputeHash()
+	got, _ := restored.ComputeHash()
+	if got != want {
+		t.Errorf("expected restored hash %s, got %s", want, got)
+	}
+}
+
+// TestDAGCompare tests task-by-task comparison of two definitions.
+func TestDAGCompare(t *testing.T) {
+	from := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a":   {Name: "a", Cmd: "echo a"},
+			"b":   {Name: "b", Cmd: "echo b", DependsOn: []string{"a"}},
+			"old": {Name: "old", Cmd: "echo old"},
+		},
+	}
+	to := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a":   {Name: "a", Cmd: "echo A"},
+			"b":   {Name: "b", Cmd: "echo b", DependsOn: []string{"new"}, Retries: 2},
+			"new": {Name: "new", Cmd: "echo new"},
+		},
+	}
+
+	diff, err := Compare(from, to)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+
+	if diff.Empty() {
+		t.Fatal("expected differences")
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "new" {
+		t.Errorf("expected added [new], got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != "old" {
+		t.Errorf("expected removed [old], got %v", diff.Removed)
+	}
+	if len(diff.Changed) != 2 {
+		t.Fatalf("expected 2 changed tasks, got %d", len(diff.Changed))
+	}
+
+	a, b := diff.Changed[0], diff.Changed[1]
+	if a.Task != "a" || a.Cmd == nil || a.Cmd.New != "echo A" {
+		t.Errorf("expected cmd change on a, got %+v", a)
+	}
+	if b.Task != "b" || b.Retries == nil || b.Cmd != nil {
+		t.Errorf("expected retries change on b, got %+v", b)
+	}
+	if len(b.AddedDeps) != 1 || b.AddedDeps[0] != "new" || len(b.RemovedDeps) != 1 || b.RemovedDeps[0] != "a" {
+		t.Errorf("expected edge change +new -a on b, got %+v", b)
+	}
+
+	same, err := Compare(from, from)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !same.Empty() {
+		t.Errorf("expected no differences, got %+v", same)
+	}
+}
+
+// TestDAGAncestorsDescendants tests walking the transitive dependencies and dependents of a task.
+func TestDAGAncestorsDescendants(t *testing.T) {
+	d := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a": {Name: "a", Cmd: "echo a"},
+			"b": {Name: "b", Cmd: "echo b", DependsOn: []string{"a"}},
+			"c": {Name: "c", Cmd: "echo c", DependsOn: []string{"a"}},
+			"d": {Name: "d", Cmd: "echo d", DependsOn: []string{"b", "c"}},
+		},
+	}
+
+	ancestors, err := d.Ancestors("d")
+	if err != nil {
+		t.Fatalf("Ancestors failed: %v", err)
+	}
+	if strings.Join(ancestors, ",") != "a,b,c" {
+		t.Errorf("expected ancestors a,b,c, got %v", ancestors)
+	}
+
+	descendants, err := d.Descendants("b")
+	if err != nil {
+		t.Fatalf("Descendants failed: %v", err)
+	}
+	if strings.Join(descendants, ",") != "d" {
+		t.Errorf("expected descendants d, got %v", descendants)
+	}
+
+	if _, err := d.Ancestors("missing"); err == nil {
+		t.Error("expected error for missing task")
+	}
+}
+
+// TestDAGSelect tests narrowing a DAG to a sub-graph with --only, --from, --until, --skip and tags.
+func TestDAGSelect(t *testing.T) {
+	d := &DAG{
+		Name: "etl",
+		Tasks: map[string]*Task{
+			"extract":   {Name: "extract", Cmd: "echo e"},
+			"transform": {Name: "transform", Cmd: "echo t", DependsOn: []string{"extract"}},
+			"load":      {Name: "load", Cmd: "echo l", DependsOn: []string{"transform"}, Tags: []string{"db"}},
+			"report":    {Name: "report", Cmd: "echo r", DependsOn: []string{"load"}, Tags: []string{"slow"}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		sel  Selection
+		want string
+	}{
+		{"only", Selection{Only: []string{"transform", "load"}}, "load,transform"},
+		{"from", Selection{From: []string{"transform"}}, "load,report,transform"},
+		{"until", Selection{Until: []string{"load"}}, "extract,load,transform"},
+		{"from and until", Selection{From: []string{"transform"}, Until: []string{"load"}}, "load,transform"},
+		{"skip", Selection{Skip: []string{"report"}}, "extract,load,transform"},
+		{"tags", Selection{Tags: []string{"db", "slow"}}, "load,report"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := d.Select(tt.sel)
+			if err != nil {
+				t.Fatalf("Select failed: %v", err)
+			}
+
+			var names []string
+			for name := range sub.Tasks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != tt.want {
+				t.Errorf("expected tasks %s, got %v", tt.want, names)
+			}
+
+			if err := sub.Validate(); err != nil {
+				t.Errorf("selected subgraph is invalid: %v", err)
+			}
+		})
+	}
+
+	sub, err := d.Select(Selection{From: []string{"transform"}})
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(sub.Tasks["transform"].DependsOn) != 0 {
+		t.Errorf("expected dependency on unselected task to be dropped, got %v", sub.Tasks["transform"].DependsOn)
+	}
+	if len(d.Tasks["transform"].DependsOn) != 1 {
+		t.Error("Select must not modify the original DAG")
+	}
+
+	if _, err := d.Select(Selection{Only: []string{"missing"}}); err == nil {
+		t.Error("expected error for unknown task")
+	}
+	if _, err := d.Select(Selection{Only: []string{"extract"}, Skip: []string{"extract"}}); err == nil {
+		t.Error("expected error for empty selection")
+	}
+}
+
+// TestDAGTopologicalSortDeterministic tests that the execution order is stable across
+// repeated loads and sorts, following priority, then declaration order, then name.
+func TestDAGTopologicalSortDeterministic(t *testing.T) {
+	definitions := map[Format]string{
+		FormatTOML: `
+name = "ordered"
+
+[tasks.zeta]
+cmd = "echo zeta"
+
+[tasks.alpha]
+cmd = "echo alpha"
+
+[tasks.mid]
+cmd = "echo mid"
+depends_on = ["zeta", "alpha"]
+
+[tasks.urgent]
+cmd = "echo urgent"
+priority = 10
+
+[tasks.beta]
+cmd = "echo beta"
+`,
+		FormatYAML: `
+name: ordered
+tasks:
+  zeta:
+    cmd: echo zeta
+  alpha:
+    cmd: echo alpha
+  mid:
+    cmd: echo mid
+    depends_on: [zeta, alpha]
+  urgent:
+    cmd: echo urgent
+    priority: 10
+  beta:
+    cmd: echo beta
+`,
+		FormatJSON: `{
+  "name": "ordered",
+  "tasks": {
+    "zeta": {"cmd": "echo zeta"},
+    "alpha": {"cmd": "echo alpha"},
+    "mid": {"cmd": "echo mid", "depends_on": ["zeta", "alpha"]},
+    "urgent": {"cmd": "echo urgent", "priority": 10},
+    "beta": {"cmd": "echo beta"}
+  }
+}`,
+	}
+
+	want := "urgent,zeta,alpha,mid,beta"
+
+	for format, data := range definitions {
+		t.Run(string(format), func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				d, err := LoadBytes([]byte(data), format, "")
+				if err != nil {
+					t.Fatalf("LoadBytes failed: %v", err)
+				}
+
+				for j := 0; j < 5; j++ {
+					order, err := d.TopologicalSort()
+					if err != nil {
+						t.Fatalf("TopologicalSort failed: %v", err)
+					}
+
+					var names []string
+					for _, task := range order {
+						names = append(names, task.Name)
+					}
+					if got := strings.Join(names, ","); got != want {
+						t.Fatalf("iteration %d: expected order %s, got %s", i, want, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestDAGSnapshotPreservesOrder tests that a DAG rebuilt from its snapshot runs in the same order,
+// and that reordering tasks does not change the hash.
+func TestDAGSnapshotPreservesOrder(t *testing.T) {
+	d, err := LoadFromString(`
+name = "ordered"
+
+[tasks.b]
+cmd = "echo b"
+
+[tasks.a]
+cmd = "echo a"
+`)
+	if err != nil {
+		t.Fatalf("LoadFromString failed: %v", err)
+	}
+
+	data, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored, err := FromSnapshot(data)
+	if err != nil {
+		t.Fatalf("FromSnapshot failed: %v", err)
+	}
+
+	order, _ := restored.TopologicalSort()
+	if order[0].Name != "b" || order[1].Name != "a" {
+		t.Errorf("expected restored order b,a, got %s,%s", order[0].Name, order[1].Name)
+	}
+
+	reordered, err := LoadFromString(`
+name = "ordered"
+
+[tasks.a]
+cmd = "echo a"
+
+[tasks.b]
+cmd = "echo b"
+`)
+	if err != nil {
+		t.Fatalf("LoadFromString failed: %v", err)
+	}
+
+	hash1, _ := d.ComputeHash()
+	hash2, _ := reordered.ComputeHash()
+	if hash1 != hash2 {
+		t.Error("expected reordering tasks not to change the hash")
+	}
+}
+
+// TestDAGGroups tests loading task groups and expanding group dependencies.
+func TestDAGGroups(t *testing.T) {
+	d, err := LoadFromString(`
+name = "etl"
+
+[groups.ingest]
+description = "Pull raw data"
+tasks = ["fetch_a", "fetch_b"]
+
+[tasks.fetch_a]
+cmd = "echo a"
+tags = ["network"]
+
+[tasks.fetch_b]
+cmd = "echo b"
+tags = ["network", "slow"]
+
+[tasks.merge]
+cmd = "echo merge"
+depends_on = ["group:ingest"]
+`)
+	if err != nil {
+		t.Fatalf("LoadFromString failed: %v", err)
+	}
+
+	if g := d.Groups["ingest"]; g == nil || g.Description != "Pull raw data" || len(g.Tasks) != 2 {
+		t.Fatalf("expected ingest group with 2 tasks, got %+v", g)
+	}
+	if got := strings.Join(d.Tasks["merge"].DependsOn, ","); got != "fetch_a,fetch_b" {
+		t.Errorf("expected group dependency to expand to fetch_a,fetch_b, got %s", got)
+	}
+	if groups := d.GroupsOf("fetch_a"); len(groups) != 1 || groups[0] != "ingest" {
+		t.Errorf("expected fetch_a to be in group ingest, got %v", groups)
+	}
+
+	sub, err := d.Select(Selection{Tags: []string{"!slow"}})
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if _, ok := sub.Tasks["fetch_b"]; ok || len(sub.Tasks) != 2 {
+		t.Errorf("expected !slow to exclude fetch_b, got %d tasks", len(sub.Tasks))
+	}
+	if g := sub.Groups["ingest"]; g == nil || len(g.Tasks) != 1 {
+		t.Errorf("expected ingest group to be narrowed to the selected task, got %+v", g)
+	}
+
+	sub, err = d.Select(Selection{Only: []string{"group:ingest"}})
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(sub.Tasks) != 2 {
+		t.Errorf("expected group selection to match 2 tasks, got %d", len(sub.Tasks))
+	}
+
+	data, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored, err := FromSnapshot(data)
+	if err != nil {
+		t.Fatalf("FromSnapshot failed: %v", err)
+	}
+	if restored.Groups["ingest"] == nil {
+		t.Error("expected groups to survive a snapshot round trip")
+	}
+}
+
+// TestDAGEncodeGroupDeps tests that converting and snapshotting a workflow keep group
+// references in depends_on rather than the tasks they expand to.
+func TestDAGEncodeGroupDeps(t *testing.T) {
+	d, err := LoadFromString(`
+name = "etl"
+
+[groups.ingest]
+tasks = ["fetch_a", "fetch_b"]
+
+[tasks.fetch_a]
+cmd = "echo a"
+
+[tasks.fetch_b]
+cmd = "echo b"
+
+[tasks.merge]
+cmd = "echo merge"
+depends_on = ["group:ingest"]
+`)
+	if err != nil {
+		t.Fatalf("LoadFromString failed: %v", err)
+	}
+
+	want, err := d.ComputeHash()
+	if err != nil {
+		t.Fatalf("ComputeHash failed: %v", err)
+	}
+
+	data, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored, err := FromSnapshot(data)
+	if err != nil {
+		t.Fatalf("FromSnapshot failed: %v", err)
+	}
+
+	for _, format := range []Format{FormatTOML, FormatYAML, FormatJSON} {
+		for _, src := range []*DAG{d, restored} {
+			data, err := src.Encode(format)
+			if err != nil {
+				t.Fatalf("Encode(%s) failed: %v", format, err)
+			}
+			decoded, err := LoadBytes(data, format, StdinSource)
+			if err != nil {
+				t.Fatalf("LoadBytes(%s) failed: %v\n%s", format, err, data)
+			}
+			if got := strings.Join(decoded.Tasks["merge"].DependsOnRefs, ","); got != "group:ingest" {
+				t.Errorf("%s: expected depends_on to keep the group reference, got %q:\n%s", format, got, data)
+			}
+			if got := strings.Join(decoded.Tasks["merge"].DependsOn, ","); got != "fetch_a,fetch_b" {
+				t.Errorf("%s: expected group dependency to expand to fetch_a,fetch_b, got %s", format, got)
+			}
+			if got, _ := decoded.ComputeHash(); got != want {
+				t.Errorf("%s round trip changed the workflow:\n%s", format, data)
+			}
+		}
+	}
+}
+
+// TestDAGGroupErrors tests invalid group definitions and references.
+func TestDAGGroupErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		toml string
+	}{
+		{"unknown group", `
+name = "bad"
+[tasks.a]
+cmd = "echo a"
+depends_on = ["group:missing"]
+`},
+		{"missing member", `
+name = "bad"
+[groups.g]
+tasks = ["a", "ghost"]
+[tasks.a]
+cmd = "echo a"
+`},
+		{"own group", `
+name = "bad"
+[groups.g]
+tasks = ["a", "b"]
+[tasks.a]
+cmd = "echo a"
+[tasks.b]
+cmd = "echo b"
+depends_on = ["group:g"]
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadFromString(tt.toml); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestDAGValidateDiagnostics tests that validation reports every problem with its position and suggestions.
+func TestDAGValidateDiagnostics(t *testing.T) {
+	_, err := LoadBytes([]byte(`name = "diag"
+
+[tasks.extract]
+cmd = "echo e"
+retry = 3
+
+[tasks.load]
+cmd = "echo l"
+depends_on = ["extract", "trnsform"]
+
+[tasks.transform]
+cmd = ""
+`), FormatTOML, "/work/diag.toml")
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %T: %v", err, err)
+	}
+
+	want := []Diagnostic{
+		{Severity: SeverityError, Line: 5, Column: 1, Key: "tasks.extract.retry", Suggestion: "retries"},
+		{Severity: SeverityError, Line: 9, Column: 26, Key: "tasks.load.depends_on", Suggestion: "transform"},
+		{Severity: SeverityError, Line: 12, Column: 1, Key: "tasks.transform.cmd"},
+	}
+	if len(verr.Diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(want), len(verr.Diagnostics), err)
+	}
+	for i, w := range want {
+		got := verr.Diagnostics[i]
+		if got.Severity != w.Severity || got.Line != w.Line || got.Column != w.Column || got.Key != w.Key || got.Suggestion != w.Suggestion {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, w, got)
+		}
+		if got.File != "/work/diag.toml" {
+			t.Errorf("diagnostic %d: expected file /work/diag.toml, got %q", i, got.File)
+		}
+	}
+
+	if !strings.Contains(err.Error(), `/work/diag.toml:9:26: task load depends on missing task trnsform (did you mean "transform"?)`) {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
+
+// TestDAGValidateDiagnosticFormats tests positions in YAML and JSON definitions and decoding errors.
+func TestDAGValidateDiagnosticFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+		line   int
+		column int
+	}{
+		{"yaml missing dependency", "name: y\ntasks:\n  a:\n    cmd: echo\n    depends_on: [b]\n", FormatYAML, 5, 18},
+		{"json missing dependency", "{\n  \"name\": \"j\",\n  \"tasks\": {\n    \"a\": {\"cmd\": \"echo\", \"depends_on\": [\"b\"]}\n  }\n}\n", FormatJSON, 4, 41},
+		{"toml type error", "name = \"t\"\n[tasks.a]\ncmd = 3\n", FormatTOML, 3, 7},
+		{"json syntax error", "{\"name\": \"j\",\n\"tasks\": }", FormatJSON, 2, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBytes([]byte(tt.data), tt.format, "/work/wf")
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected *ValidationError, got %T: %v", err, err)
+			}
+
+			got := verr.Diagnostics[0]
+			if got.Line != tt.line || got.Column != tt.column {
+				t.Errorf("expected position %d:%d, got %d:%d (%s)", tt.line, tt.column, got.Line, got.Column, got.Message)
+			}
+		})
+	}
+}
+
+// TestDAGUnknownKeyWarnings tests that with strict = false unknown keys are reported as warnings
+// without failing the load.
+func TestDAGUnknownKeyWarnings(t *testing.T) {
+	d, err := LoadFromString(`
+name = "warn"
+strict = false
+descripton = "typo"
+
+[tasks.a]
+cmd = "echo a"
+depend_on = ["b"]
+
+[tasks.b]
+cmd = "echo b"
+`)
+	if err != nil {
+		t.Fatalf("LoadFromString failed: %v", err)
+	}
+
+	if len(d.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", d.Warnings)
+	}
+	if d.Warnings[0].Key != "descripton" || d.Warnings[0].Line != 4 {
+		t.Errorf("unexpected first warning: %+v", d.Warnings[0])
+	}
+	if d.Warnings[1].Key != "tasks.a.depend_on" || d.Warnings[1].Suggestion != "depends_on" {
+		t.Errorf("unexpected second warning: %+v", d.Warnings[1])
+	}
+}
+
+// TestSuggest tests "did you mean" suggestions for misspelled names.
+func TestSuggest(t *testing.T) {
+	candidates := []string{"extract", "transform", "load", "retries", "depends_on"}
+
+	tests := map[string]string{
+		"extrct":    "extract",
+		"lod":       "load",
+		"retry":     "retries",
+		"depend_on": "depends_on",
+		"deploy":    "",
+		"x":         "",
+	}
+	for name, want := range tests {
+		if got := suggest(name, candidates); got != want {
+			t.Errorf("suggest(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestDAGStrictUnknownKeys tests that unknown keys fail the load in every format unless strict = false.
+func TestDAGStrictUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+		key    string
+	}{
+		{"toml", "name = \"s\"\n[tasks.a]\ncmd = \"echo\"\nretry = 3\n", FormatTOML, "tasks.a.retry"},
+		{"yaml", "name: s\ntasks:\n  a:\n    cmd: echo\n    depend_on: [b]\n", FormatYAML, "tasks.a.depend_on"},
+		{"json", `{"name": "s", "tasks": {"a": {"cmd": "echo"}}, "group": {}}`, FormatJSON, "group"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBytes([]byte(tt.data), tt.format, StdinSource)
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected *ValidationError, got %T: %v", err, err)
+			}
+			if len(verr.Diagnostics) != 1 || verr.Diagnostics[0].Key != tt.key || verr.Diagnostics[0].Severity != SeverityError {
+				t.Errorf("expected one error for %s, got %v", tt.key, verr.Diagnostics)
+			}
+		})
+	}
+
+	d, err := LoadBytes([]byte("name: s\nstrict: false\ntasks:\n  a:\n    cmd: echo\n    timeout: 5\n"), FormatYAML, StdinSource)
+	if err != nil {
+		t.Fatalf("expected lenient workflow to load, got: %v", err)
+	}
+	if !d.Lenient || len(d.Warnings) != 1 {
+		t.Errorf("expected lenient workflow with one warning, got lenient=%v warnings=%v", d.Lenient, d.Warnings)
+	}
+
+	data, err := d.Encode(FormatTOML)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !strings.Contains(string(data), "strict = false") {
+		t.Errorf("expected encoded workflow to keep strict = false:\n%s", data)
+	}
+}
+
+// TestSchemaUpToDate tests that the published schema matches the one generated from the loader types.
+func TestSchemaUpToDate(t *testing.T) {
+	want, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode schema: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join("..", "..", "schema", "workflow.schema.json"))
+	if err != nil {
+		t.Fatalf("failed to read published schema: %v", err)
+	}
+
+	if strings.TrimSpace(string(got)) != string(want) {
+		t.Error("schema/workflow.schema.json is out of date; regenerate it with: wf schema -o schema/workflow.schema.json")
+	}
+
+	var schema struct {
+		Required []string                   `json:"required"`
+		Defs     map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(got, &schema); err != nil {
+		t.Fatalf("published schema is not valid JSON: %v", err)
+	}
+	if strings.Join(schema.Required, ",") != "name,tasks" {
+		t.Errorf("expected name and tasks to be required, got %v", schema.Required)
+	}
+	if _, ok := schema.Defs["task"]; !ok {
+		t.Error("expected a task definition in $defs")
+	}
+}
+
+// TestCanonicalise tests formatting a TOML definition into the canonical layout.
+func TestCanonicalise(t *testing.T) {
+	input := `# Nightly ETL
+name = "etl"
+
+# loads the warehouse
+[tasks.load]
+depends_on = ["transform", "extract", "transform"] # order does not matter
+cmd = "psql -c \"copy x\""
+retries = 1
+
+[groups.ingest]
+tasks = ["extract"]
+
+[tasks.extract]
+tags = ["raw", "daily"]
+cmd = "echo extract"
+
+[tasks.transform]
+cmd = 'python t.py'
+depends_on = [
+  "group:ingest", # expands to extract
+]
+priority = 2
+# end of file
+`
+
+	want := `# Nightly ETL
+name = "etl"
+
+# loads the warehouse
+[tasks.load]
+cmd = 'psql -c "copy x"'
+depends_on = ["extract", "transform"] # order does not matter
+retries = 1
+
+[tasks.extract]
+cmd = "echo extract"
+tags = ["daily", "raw"]
+
+[tasks.transform]
+cmd = "python t.py"
+# expands to extract
+depends_on = ["group:ingest"]
+priority = 2
+
+[groups.ingest]
+tasks = ["extract"]
+
+# end of file
+`
+
+	got, err := Canonicalise([]byte(input), OrderDeclared)
+	if err != nil {
+		t.Fatalf("Canonicalise failed: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+
+	again, err := Canonicalise(got, OrderDeclared)
+	if err != nil {
+		t.Fatalf("Canonicalise of formatted output failed: %v", err)
+	}
+	if !bytes.Equal(again, got) {
+		t.Errorf("expected formatting to be idempotent, got:\n%s", again)
+	}
+
+	topo, err := Canonicalise([]byte(input), OrderTopological)
+	if err != nil {
+		t.Fatalf("Canonicalise failed: %v", err)
+	}
+	extract := strings.Index(string(topo), "[tasks.extract]")
+	transform := strings.Index(string(topo), "[tasks.transform]")
+	load := strings.Index(string(topo), "[tasks.load]")
+	if !(extract < transform && transform < load) {
+		t.Errorf("expected tasks in topological order:\n%s", topo)
+	}
+}
+
+// TestCanonicaliseErrors tests that invalid or lossy definitions are not formatted.
+func TestCanonicaliseErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid":     "name = \"x\"\n[tasks.a]\ncmd = \"echo\"\ndepends_on = [\"missing\"]\n",
+		"unknown key": "name = \"x\"\nstrict = false\n[tasks.a]\ncmd = \"echo\"\ntimeout = 5\n",
+		"syntax":      "name = \"x\"\n[tasks.a\n",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Canonicalise([]byte(input), OrderDeclared); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestQuoteTOML tests that formatted strings decode to the original value.
+func TestQuoteTOML(t *testing.T) {
+	for _, s := range []string{
+		"plain",
+		`say "hi"`,
+		`C:\path`,
+		`it's "quoted" \ both`,
+		"line one\nline two\n",
+		"ends with quote'",
+		"tab\tand\x01control",
+	} {
+		var v struct{ S string }
+		if err := toml.Unmarshal([]byte("S = "+quoteTOML(s)), &v); err != nil {
+			t.Errorf("quoteTOML(%q) = %s does not parse: %v", s, quoteTOML(s), err)
+			continue
+		}
+		if v.S != s {
+			t.Errorf("quoteTOML(%q) round-tripped to %q", s, v.S)
+		}
+	}
+}
+
+// TestDAGConcurrency tests loading, validating and preserving the concurrency policy.
+func TestDAGConcurrency(t *testing.T) {
+	d, err := LoadFromString(`
+name = "nightly"
+concurrency = "queue"
+
+[tasks.a]
+cmd = "echo a"
+`)
+	if err != nil {
+		t.Fatalf("LoadFromString failed: %v", err)
+	}
+	if d.ConcurrencyPolicy() != ConcurrencyQueue {
+		t.Errorf("expected concurrency queue, got %s", d.ConcurrencyPolicy())
+	}
+
+	data, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored, err := FromSnapshot(data)
+	if err != nil {
+		t.Fatalf("FromSnapshot failed: %v", err)
+	}
+	if restored.Concurrency != ConcurrencyQueue {
+		t.Errorf("expected snapshot to keep concurrency queue, got %q", restored.Concurrency)
+	}
+
+	out, err := Canonicalise([]byte("name = \"nightly\"\n[tasks.a]\ncmd = \"echo a\"\n\nconcurrency = \"forbid\"\n"), OrderDeclared)
+	if err == nil {
+		t.Fatal("expected concurrency under a task table to be an unknown key")
+	}
+	out, err = Canonicalise([]byte("concurrency = \"forbid\"\nname = \"nightly\"\n[tasks.a]\ncmd = \"echo a\"\n"), OrderDeclared)
+	if err != nil {
+		t.Fatalf("Canonicalise failed: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "name = \"nightly\"\nconcurrency = \"forbid\"\n") {
+		t.Errorf("expected concurrency after name, got:\n%s", out)
+	}
+
+	unset, err := LoadFromString("name = \"x\"\n[tasks.a]\ncmd = \"echo\"\n")
+	if err != nil {
+		t.Fatalf("LoadFromString failed: %v", err)
+	}
+	if unset.ConcurrencyPolicy() != ConcurrencyAllow {
+		t.Errorf("expected default concurrency allow, got %s", unset.ConcurrencyPolicy())
+	}
+
+	_, err = LoadFromString("name = \"x\"\nconcurrency = \"forbidd\"\n[tasks.a]\ncmd = \"echo\"\n")
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	diag := verr.Diagnostics[0]
+	if diag.Key != "concurrency" || diag.Suggestion != "forbid" || diag.Line != 2 {
+		t.Errorf("expected concurrency diagnostic on line 2 suggesting forbid, got %+v", diag)
+	}
+}
@@ -0,0 +1,181 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity ranks how serious a diagnostic is. Errors prevent a workflow from loading;
+// warnings are reported but do not.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Position is a location in a workflow definition. Lines and columns start at 1.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Diagnostic describes a single problem in a workflow definition.
+type Diagnostic struct {
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+	File       string   `json:"file,omitempty"`
+	Line       int      `json:"line,omitempty"`
+	Column     int      `json:"column,omitempty"`
+	Key        string   `json:"key,omitempty"`        // Dotted path of the offending key, e.g. tasks.load.depends_on
+	Suggestion string   `json:"suggestion,omitempty"` // Likely intended value for a misspelled name
+
+	value string // Offending list element, used to locate it within Key
+}
+
+// String formats the diagnostic as "file:line:column: message", the form understood by
+// most editors and CI annotations. Parts of the location that are unknown are omitted.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+
+	if d.File != "" {
+		b.WriteString(d.File)
+		b.WriteString(":")
+	}
+	if d.Line > 0 {
+		fmt.Fprintf(&b, "%d:", d.Line)
+		if d.Column > 0 {
+			fmt.Fprintf(&b, "%d:", d.Column)
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+
+	if d.Severity == SeverityWarning {
+		b.WriteString("warning: ")
+	}
+	b.WriteString(d.Message)
+	if d.Suggestion != "" {
+		fmt.Fprintf(&b, " (did you mean %q?)", d.Suggestion)
+	}
+
+	return b.String()
+}
+
+// ValidationError collects every problem found while loading or validating a workflow.
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+// Error lists the diagnostics, one per line when there are several.
+func (e *ValidationError) Error() string {
+	if len(e.Diagnostics) == 1 {
+		return e.Diagnostics[0].String()
+	}
+
+	lines := make([]string, 0, len(e.Diagnostics)+1)
+	lines = append(lines, fmt.Sprintf("%d problems:", len(e.Diagnostics)))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, "  "+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// locate fills in the file and position of diagnostics from the definition the DAG was parsed from.
+func (d *DAG) locate(diags []Diagnostic) {
+	for i := range diags {
+		if d.Source != "" && d.Source != StdinSource {
+			diags[i].File = d.Source
+		}
+		if pos, ok := d.positions.lookup(diags[i].Key, diags[i].value); ok {
+			diags[i].Line, diags[i].Column = pos.Line, pos.Column
+		}
+	}
+}
+
+// errorAt returns an error diagnostic for the given key.
+func errorAt(key, format string, args ...any) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+		Key:      key,
+	}
+}
+
+// sortDiagnostics orders diagnostics by position; diagnostics without one keep their order at the end.
+func sortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return a.Line != 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// suggest returns the candidate closest to name, or "" if none is close enough to be a
+// plausible misspelling.
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", -1
+
+	sorted := append([]string{}, candidates...)
+	sort.Strings(sorted)
+
+	for _, c := range sorted {
+		if c == name {
+			continue
+		}
+		dist := editDistance(name, c)
+		if !closeEnough(name, c, dist) {
+			continue
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+
+	return best
+}
+
+// closeEnough reports whether a candidate at the given edit distance from name is a
+// plausible misspelling: a small typo relative to the length, or a shared prefix such
+// as "retry" for "retries".
+func closeEnough(name, candidate string, dist int) bool {
+	if dist <= max(1, len(name)/3) {
+		return true
+	}
+
+	prefix := 0
+	for prefix < len(name) && prefix < len(candidate) && name[prefix] == candidate[prefix] {
+		prefix++
+	}
+	return prefix >= 4 && dist <= len(name)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,211 @@
+package dag
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// ValueChange records the old and new value of a changed field.
+type ValueChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// TaskDiff describes the changes to a task present in both definitions.
+type TaskDiff struct {
+	Task          string       `json:"task"`
+	Cmd           *ValueChange `json:"cmd,omitempty"`
+	Retries       *ValueChange `json:"retries,omitempty"`
+	Priority      *ValueChange `json:"priority,omitempty"`
+	AddedDeps     []string     `json:"added_depends_on,omitempty"`
+	RemovedDeps   []string     `json:"removed_depends_on,omitempty"`
+	AddedTags     []string     `json:"added_tags,omitempty"`
+	RemovedTags   []string     `json:"removed_tags,omitempty"`
+	AddedGroups   []string     `json:"added_groups,omitempty"`
+	RemovedGroups []string     `json:"removed_groups,omitempty"`
+}
+
+// empty reports whether the task is unchanged.
+func (td *TaskDiff) empty() bool {
+	return td.Cmd == nil && td.Retries == nil && td.Priority == nil &&
+		len(td.AddedDeps) == 0 && len(td.RemovedDeps) == 0 &&
+		len(td.AddedTags) == 0 && len(td.RemovedTags) == 0 &&
+		len(td.AddedGroups) == 0 && len(td.RemovedGroups) == 0
+}
+
+// GroupDiff describes the changes to a group present in both definitions.
+type GroupDiff struct {
+	Group        string       `json:"group"`
+	Description  *ValueChange `json:"description,omitempty"`
+	AddedTasks   []string     `json:"added_tasks,omitempty"`
+	RemovedTasks []string     `json:"removed_tasks,omitempty"`
+}
+
+// Diff describes the differences between two workflow definitions.
+type Diff struct {
+	Name        *ValueChange `json:"name,omitempty"`
+	Concurrency *ValueChange `json:"concurrency,omitempty"`
+	Added       []string     `json:"added_tasks,omitempty"`
+	Removed     []string     `json:"removed_tasks,omitempty"`
+	Changed     []TaskDiff   `json:"changed_tasks,omitempty"`
+
+	AddedGroups   []string    `json:"added_groups,omitempty"`
+	RemovedGroups []string    `json:"removed_groups,omitempty"`
+	ChangedGroups []GroupDiff `json:"changed_groups,omitempty"`
+}
+
+// Empty reports whether the two definitions are identical.
+func (d *Diff) Empty() bool {
+	return d.Name == nil && d.Concurrency == nil && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		len(d.AddedGroups) == 0 && len(d.RemovedGroups) == 0 && len(d.ChangedGroups) == 0
+}
+
+// Compare computes the task-by-task differences from a to b using their normalised snapshots.
+func Compare(a, b *DAG) (*Diff, error) {
+	from, err := normalise(a)
+	if err != nil {
+		return nil, err
+	}
+	to, err := normalise(b)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &Diff{}
+	if from.Name != to.Name {
+		diff.Name = &ValueChange{Old: from.Name, New: to.Name}
+	}
+	if from.Concurrency != to.Concurrency {
+		diff.Concurrency = &ValueChange{Old: string(from.Concurrency), New: string(to.Concurrency)}
+	}
+
+	fromTasks := indexTasks(from.Tasks)
+	toTasks := indexTasks(to.Tasks)
+	fromMembership := membership(from.Groups)
+	toMembership := membership(to.Groups)
+
+	for _, t := range from.Tasks {
+		if _, ok := toTasks[t.Name]; !ok {
+			diff.Removed = append(diff.Removed, t.Name)
+		}
+	}
+
+	for _, t := range to.Tasks {
+		old, ok := fromTasks[t.Name]
+		if !ok {
+			diff.Added = append(diff.Added, t.Name)
+			continue
+		}
+
+		td := TaskDiff{Task: t.Name}
+		if old.Cmd != t.Cmd {
+			td.Cmd = &ValueChange{Old: old.Cmd, New: t.Cmd}
+		}
+		if old.Retries != t.Retries {
+			td.Retries = &ValueChange{Old: old.Retries, New: t.Retries}
+		}
+		if old.Priority != t.Priority {
+			td.Priority = &ValueChange{Old: old.Priority, New: t.Priority}
+		}
+		td.AddedDeps = difference(t.DependsOn, old.DependsOn)
+		td.RemovedDeps = difference(old.DependsOn, t.DependsOn)
+		td.AddedTags = difference(t.Tags, old.Tags)
+		td.RemovedTags = difference(old.Tags, t.Tags)
+		td.AddedGroups = difference(toMembership[t.Name], fromMembership[t.Name])
+		td.RemovedGroups = difference(fromMembership[t.Name], toMembership[t.Name])
+
+		if !td.empty() {
+			diff.Changed = append(diff.Changed, td)
+		}
+	}
+
+	fromGroups := indexGroups(from.Groups)
+	toGroups := indexGroups(to.Groups)
+
+	for _, g := range from.Groups {
+		if _, ok := toGroups[g.Name]; !ok {
+			diff.RemovedGroups = append(diff.RemovedGroups, g.Name)
+		}
+	}
+
+	for _, g := range to.Groups {
+		old, ok := fromGroups[g.Name]
+		if !ok {
+			diff.AddedGroups = append(diff.AddedGroups, g.Name)
+			continue
+		}
+
+		gd := GroupDiff{Group: g.Name}
+		if old.Description != g.Description {
+			gd.Description = &ValueChange{Old: old.Description, New: g.Description}
+		}
+		gd.AddedTasks = difference(g.Tasks, old.Tasks)
+		gd.RemovedTasks = difference(old.Tasks, g.Tasks)
+
+		if gd.Description != nil || len(gd.AddedTasks) > 0 || len(gd.RemovedTasks) > 0 {
+			diff.ChangedGroups = append(diff.ChangedGroups, gd)
+		}
+	}
+
+	return diff, nil
+}
+
+// normalise converts a DAG into its snapshot form, with tasks and dependencies sorted.
+func normalise(d *DAG) (*dagSnapshot, error) {
+	data, err := d.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot dagSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// indexTasks maps snapshot tasks by name.
+func indexTasks(tasks []taskSnapshot) map[string]taskSnapshot {
+	m := make(map[string]taskSnapshot, len(tasks))
+	for _, t := range tasks {
+		m[t.Name] = t
+	}
+	return m
+}
+
+// indexGroups maps snapshot groups by name.
+func indexGroups(groups []Group) map[string]Group {
+	m := make(map[string]Group, len(groups))
+	for _, g := range groups {
+		m[g.Name] = g
+	}
+	return m
+}
+
+// membership maps each task to the names of the groups containing it.
+func membership(groups []Group) map[string][]string {
+	m := map[string][]string{}
+	for _, g := range groups {
+		for _, task := range g.Tasks {
+			m[task] = append(m[task], g.Name)
+		}
+	}
+	return m
+}
+
+// difference returns the sorted elements of a that are not in b.
+func difference(a, b []string) []string {
+	in := make(map[string]struct{}, len(b))
+	for _, s := range b {
+		in[s] = struct{}{}
+	}
+
+	var out []string
+	for _, s := range a {
+		if _, ok := in[s]; !ok {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,131 @@
+package dag
+
+import "testing"
+
+// TestCompareTags tests that changes to the tags of a task are reported.
+func TestCompareTags(t *testing.T) {
+	from := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a": {Name: "a", Cmd: "echo a", Tags: []string{"nightly", "slow"}},
+			"b": {Name: "b", Cmd: "echo b", Tags: []string{"fast"}},
+		},
+	}
+	to := &DAG{
+		Name: "test",
+		Tasks: map[string]*Task{
+			"a": {Name: "a", Cmd: "echo a", Tags: []string{"slow", "etl"}},
+			"b": {Name: "b", Cmd: "echo b", Tags: []string{"fast"}},
+		},
+	}
+
+	diff, err := Compare(from, to)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("expected 1 changed task, got %+v", diff.Changed)
+	}
+	a := diff.Changed[0]
+	if a.Task != "a" || len(a.AddedTags) != 1 || a.AddedTags[0] != "etl" || len(a.RemovedTags) != 1 || a.RemovedTags[0] != "nightly" {
+		t.Errorf("expected tag change +etl -nightly on a, got %+v", a)
+	}
+	if a.Cmd != nil || len(a.AddedDeps) != 0 {
+		t.Errorf("expected only the tags of a to change, got %+v", a)
+	}
+}
+
+// TestCompareConcurrency tests that a change of the concurrency policy is reported.
+func TestCompareConcurrency(t *testing.T) {
+	tasks := map[string]*Task{"a": {Name: "a", Cmd: "echo a"}}
+	from := &DAG{Name: "test", Tasks: tasks}
+	to := &DAG{Name: "test", Concurrency: ConcurrencyQueue, Tasks: tasks}
+
+	diff, err := Compare(from, to)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if diff.Empty() {
+		t.Fatal("expected a concurrency change to make the diff non-empty")
+	}
+	if diff.Concurrency == nil || diff.Concurrency.Old != "" || diff.Concurrency.New != "queue" {
+		t.Errorf("expected concurrency change \"\" -> \"queue\", got %+v", diff.Concurrency)
+	}
+	if len(diff.Changed) != 0 || len(diff.Added) != 0 || len(diff.Removed) != 0 {
+		t.Errorf("expected no task changes, got %+v", diff)
+	}
+
+	diff, err = Compare(to, to)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !diff.Empty() {
+		t.Errorf("expected no differences, got %+v", diff)
+	}
+}
+
+// TestCompareGroups tests that added, removed and changed groups are reported, along with
+// the changes to the groups of each task.
+func TestCompareGroups(t *testing.T) {
+	tasks := map[string]*Task{
+		"a": {Name: "a", Cmd: "echo a"},
+		"b": {Name: "b", Cmd: "echo b"},
+		"c": {Name: "c", Cmd: "echo c"},
+	}
+	from := &DAG{
+		Name:  "test",
+		Tasks: tasks,
+		Groups: map[string]*Group{
+			"ingest": {Name: "ingest", Description: "Load data", Tasks: []string{"a", "b"}},
+			"old":    {Name: "old", Tasks: []string{"c"}},
+		},
+	}
+	to := &DAG{
+		Name:  "test",
+		Tasks: tasks,
+		Groups: map[string]*Group{
+			"ingest": {Name: "ingest", Description: "Load raw data", Tasks: []string{"a", "c"}},
+			"report": {Name: "report", Tasks: []string{"c"}},
+		},
+	}
+
+	diff, err := Compare(from, to)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+
+	if len(diff.AddedGroups) != 1 || diff.AddedGroups[0] != "report" {
+		t.Errorf("expected group report added, got %v", diff.AddedGroups)
+	}
+	if len(diff.RemovedGroups) != 1 || diff.RemovedGroups[0] != "old" {
+		t.Errorf("expected group old removed, got %v", diff.RemovedGroups)
+	}
+	if len(diff.ChangedGroups) != 1 {
+		t.Fatalf("expected 1 changed group, got %+v", diff.ChangedGroups)
+	}
+	g := diff.ChangedGroups[0]
+	if g.Group != "ingest" || g.Description == nil || g.Description.New != "Load raw data" {
+		t.Errorf("expected the description of ingest to change, got %+v", g)
+	}
+	if len(g.AddedTasks) != 1 || g.AddedTasks[0] != "c" || len(g.RemovedTasks) != 1 || g.RemovedTasks[0] != "b" {
+		t.Errorf("expected ingest tasks +c -b, got %+v", g)
+	}
+
+	changed := map[string]TaskDiff{}
+	for _, td := range diff.Changed {
+		changed[td.Task] = td
+	}
+	if _, ok := changed["a"]; ok {
+		t.Errorf("expected task a unchanged, got %+v", changed["a"])
+	}
+	if b := changed["b"]; len(b.AddedGroups) != 0 || len(b.RemovedGroups) != 1 || b.RemovedGroups[0] != "ingest" {
+		t.Errorf("expected task b to leave ingest, got %+v", b)
+	}
+	c := changed["c"]
+	if len(c.AddedGroups) != 2 || c.AddedGroups[0] != "ingest" || c.AddedGroups[1] != "report" {
+		t.Errorf("expected task c to join ingest and report, got %+v", c)
+	}
+	if len(c.RemovedGroups) != 1 || c.RemovedGroups[0] != "old" {
+		t.Errorf("expected task c to leave old, got %+v", c)
+	}
+}
@@ -0,0 +1,191 @@
+package dag
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joelfokou/workflow/internal/config"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/pelletier/go-toml/v2"
+	"go.uber.org/zap"
+)
+
+// Workflow scopes reported by Discover.
+const (
+	ScopeProject = "project"
+	ScopeGlobal  = "global"
+)
+
+// Entry describes a workflow definition found during discovery.
+type Entry struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Scope string `json:"scope"`
+}
+
+// Discover lists the workflows visible from the current project and the global workflows directory.
+// Project workflows shadow global workflows with the same name. Entries are sorted by name.
+func Discover() ([]Entry, error) {
+	var entries []Entry
+	seen := map[string]struct{}{}
+
+	add := func(e Entry) {
+		if _, ok := seen[e.Name]; ok {
+			return
+		}
+		seen[e.Name] = struct{}{}
+		entries = append(entries, e)
+	}
+
+	if p := config.P; p != nil {
+		if p.Dir != "" {
+			projectEntries, err := scanDir(p.Dir, ScopeProject)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range projectEntries {
+				add(e)
+			}
+		}
+		if p.File != "" {
+			add(Entry{Name: fileWorkflowName(p.File), Path: p.File, Scope: ScopeProject})
+		}
+	}
+
+	globalEntries, err := scanDir(config.C.Paths.Workflows, ScopeGlobal)
+	if err != nil {
+		// A missing global directory is fine when the project provides workflows
+		if !(os.IsNotExist(err) && len(entries) > 0) {
+			return nil, err
+		}
+	}
+	for _, e := range globalEntries {
+		add(e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+// Locate returns the path and scope of the named workflow, checking the project before
+// the global workflows directory. If the workflow does not exist, the global path is returned.
+func Locate(name string) (string, string) {
+	name = trimWorkflowExt(name)
+
+	if p := config.P; p != nil {
+		if p.Dir != "" {
+			if path, ok := findWorkflowFile(p.Dir, name); ok {
+				return path, ScopeProject
+			}
+		}
+		if p.File != "" && fileWorkflowName(p.File) == name {
+			return p.File, ScopeProject
+		}
+	}
+
+	if path, ok := findWorkflowFile(config.C.Paths.Workflows, name); ok {
+		return path, ScopeGlobal
+	}
+
+	return filepath.Join(config.C.Paths.Workflows, filepath.FromSlash(name)+".toml"), ScopeGlobal
+}
+
+// findWorkflowFile looks for the named workflow in dir, trying each supported extension.
+func findWorkflowFile(dir, name string) (string, bool) {
+	base := filepath.Join(dir, filepath.FromSlash(name))
+	for _, e := range workflowExts {
+		if _, err := os.Stat(base + e.ext); err == nil {
+			return base + e.ext, true
+		}
+	}
+	return "", false
+}
+
+// scanDir recursively lists the workflow files in dir. Workflows in subdirectories
+// are namespaced by their relative path, so etl/daily.toml is named "etl/daily".
+// Hidden directories are skipped.
+func scanDir(dir, scope string) ([]Entry, error) {
+	if _, err := os.Stat(dir); err != nil {
+		logger.L().Debug("failed to read workflows directory", zap.String("dir", dir), zap.Error(err))
+		return nil, err
+	}
+
+	var entries []Entry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := FormatFromPath(d.Name()); !ok {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{
+			Name:  trimWorkflowExt(filepath.ToSlash(rel)),
+			Path:  path,
+			Scope: scope,
+		})
+		return nil
+	})
+	if err != nil {
+		logger.L().Debug("failed to scan workflows directory", zap.String("dir", dir), zap.Error(err))
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Namespace returns the namespace part of a workflow name, or an empty string
+// for workflows at the top level of a workflows directory.
+func Namespace(name string) string {
+	ns := path.Dir(filepath.ToSlash(name))
+	if ns == "." {
+		return ""
+	}
+	return ns
+}
+
+// InNamespace reports whether the workflow name belongs to namespace ns or one of its children.
+func InNamespace(name, ns string) bool {
+	ns = strings.Trim(ns, "/")
+	if ns == "" {
+		return true
+	}
+	return strings.HasPrefix(name, ns+"/")
+}
+
+// fileWorkflowName returns the name declared in a workflow file. A project workflow.toml
+// is addressed by its declared name, falling back to the name of its directory.
+func fileWorkflowName(path string) string {
+	fallback := filepath.Base(filepath.Dir(path))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fallback
+	}
+
+	var header struct {
+		Name string `toml:"name"`
+	}
+	if err := toml.Unmarshal(data, &header); err != nil || header.Name == "" {
+		return fallback
+	}
+
+	return header.Name
+}
@@ -0,0 +1,322 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// Format identifies the file format of a workflow definition.
+type Format string
+
+const (
+	FormatTOML Format = "toml"
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// workflowExts maps recognised workflow file extensions to their format, in lookup order.
+var workflowExts = []struct {
+	ext    string
+	format Format
+}{
+	{".toml", FormatTOML},
+	{".yaml", FormatYAML},
+	{".yml", FormatYAML},
+	{".json", FormatJSON},
+}
+
+// ParseFormat converts a user-supplied format name into a Format.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "toml":
+		return FormatTOML, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s (supported: toml, yaml, json)", s)
+	}
+}
+
+// FormatFromPath detects the workflow format from a file extension.
+func FormatFromPath(path string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range workflowExts {
+		if e.ext == ext {
+			return e.format, true
+		}
+	}
+	return "", false
+}
+
+// Ext returns the canonical file extension for the format.
+func (f Format) Ext() string {
+	if f == FormatYAML {
+		return ".yaml"
+	}
+	return "." + string(f)
+}
+
+// trimWorkflowExt removes a recognised workflow extension from a name or path.
+func trimWorkflowExt(name string) string {
+	if _, ok := FormatFromPath(name); ok {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// detectFormat guesses the format of a definition read without a file name, such as from stdin.
+// JSON is recognised by its leading brace; otherwise TOML is tried before YAML.
+func detectFormat(data []byte) Format {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+
+	var wf rawWorkflow
+	if err := toml.Unmarshal(data, &wf); err == nil {
+		return FormatTOML
+	}
+	if err := yaml.Unmarshal(data, &wf); err == nil && wf.Name != "" {
+		return FormatYAML
+	}
+
+	return FormatTOML
+}
+
+// decodeWorkflow unmarshals a definition in the given format into its raw representation.
+func decodeWorkflow(data []byte, format Format) (*rawWorkflow, error) {
+	var wf rawWorkflow
+
+	switch format {
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &wf); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal TOML: %w", err)
+		}
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &wf); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+		}
+	case FormatJSON:
+		if err := json.Unmarshal(data, &wf); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	return &wf, nil
+}
+
+// yamlErrorLine matches the line number in YAML decoding errors.
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// decodePosition extracts the position of a decoding error, if the decoder reports one.
+func decodePosition(err error, data []byte) (Position, bool) {
+	var tomlErr *toml.DecodeError
+	if errors.As(err, &tomlErr) {
+		line, column := tomlErr.Position()
+		return Position{Line: line, Column: column}, true
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Offset counts the offending byte, so step back onto it
+		return offsetPosition(data, max(0, int(syntaxErr.Offset)-1)), true
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return offsetPosition(data, int(typeErr.Offset)), true
+	}
+
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return Position{Line: line}, true
+	}
+
+	return Position{}, false
+}
+
+// Encode serialises the DAG as a workflow definition in the given format. Tasks are
+// written in declaration order, so that the definition loads back with the same
+// positions, which break ties between ready tasks.
+func (d *DAG) Encode(format Format) ([]byte, error) {
+	wf := encodedWorkflow{
+		Name:        d.Name,
+		Concurrency: string(d.Concurrency),
+	}
+	if d.Lenient {
+		strict := false
+		wf.Strict = &strict
+	}
+
+	names, err := canonicalTaskOrder(d, OrderDeclared)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		t := d.Tasks[name]
+		declared := t.DependsOn
+		if t.DependsOnRefs != nil {
+			declared = t.DependsOnRefs
+		}
+		deps := make([]string, len(declared))
+		copy(deps, declared)
+		sort.Strings(deps)
+
+		wf.Tasks = append(wf.Tasks, namedTask{name: name, task: rawTask{
+			Cmd:       t.Cmd,
+			Retries:   t.Retries,
+			DependsOn: deps,
+			Tags:      t.Tags,
+			Priority:  t.Priority,
+		}})
+	}
+
+	for name, g := range d.Groups {
+		if wf.Groups == nil {
+			wf.Groups = make(map[string]rawGroup, len(d.Groups))
+		}
+		wf.Groups[name] = rawGroup{Description: g.Description, Tasks: g.Tasks}
+	}
+
+	switch format {
+	case FormatTOML:
+		return wf.encodeTOML(), nil
+	case FormatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(wf); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatJSON:
+		data, err := json.MarshalIndent(wf, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// encodedWorkflow is the form of rawWorkflow written by Encode, with its tasks in
+// declaration order rather than in a map, which encoders sort by key.
+type encodedWorkflow struct {
+	Name        string              `yaml:"name" json:"name"`
+	Strict      *bool               `yaml:"strict,omitempty" json:"strict,omitempty"`
+	Concurrency string              `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+	Tasks       orderedTasks        `yaml:"tasks" json:"tasks"`
+	Groups      map[string]rawGroup `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// namedTask is a task with its name, as an entry of orderedTasks.
+type namedTask struct {
+	name string
+	task rawTask
+}
+
+// orderedTasks encodes as a YAML mapping or JSON object that keeps the order of its tasks.
+type orderedTasks []namedTask
+
+// MarshalJSON writes the tasks as a JSON object in order.
+func (tasks orderedTasks) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, t := range tasks {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(t.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(t.task)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML returns the tasks as a YAML mapping node in order.
+func (tasks orderedTasks) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, t := range tasks {
+		var value yaml.Node
+		if err := value.Encode(t.task); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t.name}, &value)
+	}
+	return node, nil
+}
+
+// encodeTOML writes the workflow in the layout of Canonicalise, without comments.
+func (wf encodedWorkflow) encodeTOML() []byte {
+	var b bytes.Buffer
+	writeKey := func(key, value string) {
+		b.WriteString(key + " = " + value + "\n")
+	}
+
+	writeKey("name", quoteTOML(wf.Name))
+	if wf.Strict != nil {
+		writeKey("strict", strconv.FormatBool(*wf.Strict))
+	}
+	if wf.Concurrency != "" {
+		writeKey("concurrency", quoteTOML(wf.Concurrency))
+	}
+
+	for _, t := range wf.Tasks {
+		b.WriteString("\n[" + tomlTableKey("tasks", t.name) + "]\n")
+		writeKey("cmd", quoteTOML(t.task.Cmd))
+		if len(t.task.DependsOn) > 0 {
+			writeKey("depends_on", tomlArray(t.task.DependsOn))
+		}
+		if t.task.Retries != 0 {
+			writeKey("retries", strconv.Itoa(t.task.Retries))
+		}
+		if len(t.task.Tags) > 0 {
+			writeKey("tags", tomlArray(t.task.Tags))
+		}
+		if t.task.Priority != 0 {
+			writeKey("priority", strconv.Itoa(t.task.Priority))
+		}
+	}
+
+	groups := make([]string, 0, len(wf.Groups))
+	for name := range wf.Groups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	for _, name := range groups {
+		g := wf.Groups[name]
+		b.WriteString("\n[" + tomlTableKey("groups", name) + "]\n")
+		if g.Description != "" {
+			writeKey("description", quoteTOML(g.Description))
+		}
+		writeKey("tasks", tomlArray(g.Tasks))
+	}
+
+	return b.Bytes()
+}
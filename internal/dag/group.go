@@ -0,0 +1,180 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GroupPrefix marks a reference to a task group, e.g. depends_on = ["group:ingest"].
+const GroupPrefix = "group:"
+
+// Group bundles tasks under a name for selection, dependencies and visualisation.
+type Group struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Tasks       []string `json:"tasks"`
+}
+
+// rawGroup is the file representation of a task group.
+type rawGroup struct {
+	Description string   `toml:"description,omitempty" yaml:"description,omitempty" json:"description,omitempty" desc:"What the group is for"`
+	Tasks       []string `toml:"tasks" yaml:"tasks" json:"tasks" desc:"Names of the tasks in the group"`
+}
+
+// IsGroupRef reports whether a task reference names a group.
+func IsGroupRef(ref string) bool {
+	return strings.HasPrefix(ref, GroupPrefix)
+}
+
+// SortedGroups returns the groups of the DAG sorted by name.
+func (d *DAG) SortedGroups() []*Group {
+	groups := make([]*Group, 0, len(d.Groups))
+	for _, g := range d.Groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// GroupsOf returns the sorted names of the groups containing the named task.
+func (d *DAG) GroupsOf(task string) []string {
+	var names []string
+	for _, g := range d.Groups {
+		for _, member := range g.Tasks {
+			if member == task {
+				names = append(names, g.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveRefs expands group references in a list of task references into their member
+// tasks. Plain task names are returned unchanged; duplicates are removed.
+func (d *DAG) resolveRefs(refs []string) ([]string, error) {
+	var (
+		names []string
+		seen  = map[string]bool{}
+	)
+
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, ref := range refs {
+		if !IsGroupRef(ref) {
+			add(ref)
+			continue
+		}
+
+		g, ok := d.Groups[strings.TrimPrefix(ref, GroupPrefix)]
+		if !ok {
+			return nil, fmt.Errorf("unknown group %s", strings.TrimPrefix(ref, GroupPrefix))
+		}
+		for _, member := range g.Tasks {
+			add(member)
+		}
+	}
+
+	return names, nil
+}
+
+// expandGroupDeps replaces group references in task dependencies with the group members,
+// keeping the declared list in DependsOnRefs. References to unknown groups, or to a group
+// containing the task itself, are left in place for Validate to report.
+func (d *DAG) expandGroupDeps() {
+	for _, t := range d.Tasks {
+		if !hasGroupRef(t.DependsOn) {
+			continue
+		}
+
+		var deps []string
+		add := func(name string) {
+			if !containsString(deps, name) {
+				deps = append(deps, name)
+			}
+		}
+
+		for _, dep := range t.DependsOn {
+			name := strings.TrimPrefix(dep, GroupPrefix)
+			g, ok := d.Groups[name]
+			if !IsGroupRef(dep) || !ok || containsString(d.GroupsOf(t.Name), name) {
+				add(dep)
+				continue
+			}
+			for _, member := range g.Tasks {
+				add(member)
+			}
+		}
+		t.DependsOnRefs = t.DependsOn
+		t.DependsOn = deps
+	}
+}
+
+// groupRefProblem describes why a group reference left in the dependencies of a task
+// could not be expanded.
+func (d *DAG) groupRefProblem(task, ref string) Diagnostic {
+	name := strings.TrimPrefix(ref, GroupPrefix)
+
+	if _, ok := d.Groups[name]; ok {
+		diag := errorAt("tasks."+task+".depends_on", "task %s depends on its own group %s", task, name)
+		diag.value = ref
+		return diag
+	}
+
+	diag := errorAt("tasks."+task+".depends_on", "task %s depends on unknown group %s", task, name)
+	diag.value = ref
+
+	groups := make([]string, 0, len(d.Groups))
+	for g := range d.Groups {
+		groups = append(groups, g)
+	}
+	if s := suggest(name, groups); s != "" {
+		diag.Suggestion = GroupPrefix + s
+	}
+	return diag
+}
+
+// hasGroupRef reports whether any reference in refs names a group.
+func hasGroupRef(refs []string) bool {
+	for _, ref := range refs {
+		if IsGroupRef(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGroups checks that every group has a valid name and only references existing tasks.
+func (d *DAG) validateGroups() []Diagnostic {
+	var diags []Diagnostic
+
+	for _, g := range d.SortedGroups() {
+		key := "groups." + g.Name
+		if !taskNamePattern.MatchString(g.Name) {
+			diags = append(diags, errorAt(key, "invalid group name %q (allowed: letters, digits, _, -)", g.Name))
+		}
+		if len(g.Tasks) == 0 {
+			diags = append(diags, errorAt(key+".tasks", "group %s has no tasks", g.Name))
+			continue
+		}
+		for _, member := range g.Tasks {
+			if _, ok := d.Tasks[member]; !ok {
+				diag := errorAt(key+".tasks", "group %s contains missing task %s", g.Name, member)
+				diag.value = member
+				diag.Suggestion = suggest(member, d.taskNames())
+				diags = append(diags, diag)
+			}
+		}
+	}
+
+	return diags
+}
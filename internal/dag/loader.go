@@ -2,40 +2,98 @@ package dag
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 
-	"github.com/joelfokou/workflow/internal/config"
 	"github.com/joelfokou/workflow/internal/logger"
-	"github.com/pelletier/go-toml/v2"
 	"go.uber.org/zap"
 )
 
-// rawWorkflow is an internal representation of the workflow structure in TOML format.
+// rawWorkflow is an internal representation of the workflow structure shared by all file formats.
+// The desc tags document each key in the JSON Schema generated by Schema.
 type rawWorkflow struct {
-	Name  string `toml:"name"`
-	Tasks map[string]struct {
-		Cmd       string   `toml:"cmd"`
-		Retries   int      `toml:"retries"`
-		DependsOn []string `toml:"depends_on"`
-	} `toml:"tasks"`
+	Name        string              `toml:"name" yaml:"name" json:"name" desc:"Name of the workflow"`
+	Strict      *bool               `toml:"strict,omitempty" yaml:"strict,omitempty" json:"strict,omitempty" desc:"Reject unknown keys (default true); set to false to only warn about them, e.g. for keys from newer versions of wf"`
+	Concurrency string              `toml:"concurrency,omitempty" yaml:"concurrency,omitempty" json:"concurrency,omitempty" enum:"allow,forbid,queue" desc:"Whether runs of this workflow may overlap: allow (default), forbid a second run, or queue it until the first finishes"`
+	Tasks       map[string]rawTask  `toml:"tasks" yaml:"tasks" json:"tasks" desc:"Tasks of the workflow, by name"`
+	Groups      map[string]rawGroup `toml:"groups,omitempty" yaml:"groups,omitempty" json:"groups,omitempty" desc:"Named groups of tasks for selection, dependencies and visualisation"`
 }
 
-// Load reads a workflow from a TOML file located in the configured workflows directory.
+// rawTask is the file representation of a single task.
+type rawTask struct {
+	Cmd       string   `toml:"cmd" yaml:"cmd" json:"cmd" desc:"Shell command to execute"`
+	DependsOn []string `toml:"depends_on,omitempty" yaml:"depends_on,omitempty" json:"depends_on,omitempty" desc:"Tasks, or groups as group:name, that must succeed first"`
+	Retries   int      `toml:"retries,omitempty" yaml:"retries,omitempty" json:"retries,omitempty" desc:"Number of retry attempts on failure"`
+	Tags      []string `toml:"tags,omitempty" yaml:"tags,omitempty" json:"tags,omitempty" desc:"Labels for selecting tasks with wf run --tags"`
+	Priority  int      `toml:"priority,omitempty" yaml:"priority,omitempty" json:"priority,omitempty" desc:"Ready tasks with a higher priority run first"`
+}
+
+// StdinSource is the workflow reference used to read a definition from standard input.
+const StdinSource = "-"
+
+// Load reads a workflow by name, looking in the current project before the configured workflows directory.
+// Names may include a namespace, so "etl/daily" loads etl/daily.toml.
 func Load(path string) (*DAG, error) {
-	path = strings.TrimSuffix(path, ".toml")
+	filePath, _ := Locate(path)
+	dag, err := LoadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	dag.Ref = trimWorkflowExt(path)
+	dag.Namespace = Namespace(dag.Ref)
 
-	filePath := filepath.Join(config.C.Paths.Workflows, path+".toml")
-	data, err := os.ReadFile(filePath)
+	return dag, nil
+}
+
+// LoadFile reads a workflow file at an arbitrary path. The format is detected from the
+// file extension (.toml, .yaml, .yml or .json), defaulting to TOML.
+// The absolute path of the file is recorded as the source of the DAG.
+func LoadFile(path string) (*DAG, error) {
+	absPath, err := filepath.Abs(path)
 	if err != nil {
-		logger.L().Error("failed to read workflow file", zap.String("path", filePath), zap.Error(err))
-		return nil, fmt.Errorf("failed to read workflow file %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to resolve workflow path %s: %w", path, err)
 	}
 
-	dag, err := parseWorkflow(data)
+	data, err := os.ReadFile(absPath)
 	if err != nil {
-		logger.L().Error("failed to parse workflow", zap.String("path", filePath), zap.Error(err))
+		logger.L().Error("failed to read workflow file", zap.String("path", absPath), zap.Error(err))
+		return nil, fmt.Errorf("failed to read workflow file %s: %w", absPath, err)
+	}
+
+	format, ok := FormatFromPath(absPath)
+	if !ok {
+		format = FormatTOML
+	}
+
+	return LoadBytes(data, format, absPath)
+}
+
+// LoadReader reads a workflow from r, recording source as its origin.
+// The format is detected from the content: JSON, TOML or YAML.
+func LoadReader(r io.Reader, source string) (*DAG, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logger.L().Error("failed to read workflow", zap.String("source", source), zap.Error(err))
+		return nil, fmt.Errorf("failed to read workflow from %s: %w", source, err)
+	}
+
+	return LoadBytes(data, "", source)
+}
+
+// LoadBytes parses and validates a workflow definition, recording source as its origin.
+// An empty format is detected from the content.
+func LoadBytes(data []byte, format Format, source string) (*DAG, error) {
+	if format == "" {
+		format = detectFormat(data)
+	}
+
+	dag, err := parseWorkflow(data, format, source)
+	if err != nil {
+		logger.L().Error("failed to parse workflow", zap.String("source", source), zap.Error(err))
 		return nil, err
 	}
 
@@ -44,13 +102,54 @@ func Load(path string) (*DAG, error) {
 		return nil, fmt.Errorf("workflow validation failed: %w", err)
 	}
 
+	for _, w := range dag.Warnings {
+		logger.L().Warn("workflow warning", zap.String("workflow", dag.Name), zap.String("warning", w.String()))
+	}
+
 	logger.L().Info("workflow loaded successfully", zap.String("workflow", dag.Name), zap.Int("tasks", len(dag.Tasks)))
 	return dag, nil
 }
 
+// Resolve loads a workflow from a reference given on the command line.
+// The reference may be "-" for standard input, a path to a workflow file,
+// or the name of a workflow in the configured workflows directory.
+func Resolve(ref string) (*DAG, error) {
+	if ref == StdinSource {
+		return LoadReader(os.Stdin, StdinSource)
+	}
+
+	if IsPath(ref) {
+		return LoadFile(ref)
+	}
+
+	return Load(ref)
+}
+
+// IsPath reports whether ref should be treated as a file path rather than a workflow name.
+// Absolute paths and paths starting with "./" or "../" always refer to files; other references
+// are treated as files only if they carry a workflow file extension and exist on disk.
+func IsPath(ref string) bool {
+	if filepath.IsAbs(ref) {
+		return true
+	}
+
+	slashed := filepath.ToSlash(ref)
+	if strings.HasPrefix(slashed, "./") || strings.HasPrefix(slashed, "../") {
+		return true
+	}
+
+	if _, ok := FormatFromPath(ref); ok {
+		if info, err := os.Stat(ref); err == nil && info.Mode().IsRegular() {
+			return true
+		}
+	}
+
+	return false
+}
+
 // LoadFromString reads a workflow from a TOML-formatted string.
 func LoadFromString(data string) (*DAG, error) {
-	dag, err := parseWorkflow([]byte(data))
+	dag, err := parseWorkflow([]byte(data), FormatTOML, "")
 	if err != nil {
 		logger.L().Error("failed to parse workflow from string", zap.Error(err))
 		return nil, err
@@ -65,20 +164,30 @@ func LoadFromString(data string) (*DAG, error) {
 	return dag, nil
 }
 
-// parseWorkflow converts raw TOML bytes into a DAG structure.
-func parseWorkflow(data []byte) (*DAG, error) {
-	var wf rawWorkflow
-	if err := toml.Unmarshal(data, &wf); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal TOML: %w", err)
-	}
-
-	if wf.Name == "" {
-		return nil, fmt.Errorf("workflow name is required")
+// parseWorkflow converts raw bytes in the given format into a DAG structure, recording
+// source as its origin. Decoding errors are returned as a *ValidationError carrying the
+// position reported by the decoder. Unknown keys are recorded as warnings.
+func parseWorkflow(data []byte, format Format, source string) (*DAG, error) {
+	wf, err := decodeWorkflow(data, format)
+	if err != nil {
+		diag := Diagnostic{Severity: SeverityError, Message: err.Error()}
+		if source != "" && source != StdinSource {
+			diag.File = source
+		}
+		if pos, ok := decodePosition(err, data); ok {
+			diag.Line, diag.Column = pos.Line, pos.Column
+		}
+		return nil, &ValidationError{Diagnostics: []Diagnostic{diag}}
 	}
 
+	positions := mapSource(data, format)
 	dag := &DAG{
-		Name:  wf.Name,
-		Tasks: make(map[string]*Task, len(wf.Tasks)),
+		Name:        wf.Name,
+		Tasks:       make(map[string]*Task, len(wf.Tasks)),
+		Lenient:     wf.Strict != nil && !*wf.Strict,
+		Concurrency: Concurrency(wf.Concurrency),
+		Source:      source,
+		positions:   positions,
 	}
 
 	for name, t := range wf.Tasks {
@@ -87,15 +196,133 @@ func parseWorkflow(data []byte) (*DAG, error) {
 			Cmd:       t.Cmd,
 			Retries:   t.Retries,
 			DependsOn: t.DependsOn,
+			Tags:      t.Tags,
+			Priority:  t.Priority,
 		}
 	}
 
+	for name, g := range wf.Groups {
+		if dag.Groups == nil {
+			dag.Groups = make(map[string]*Group, len(wf.Groups))
+		}
+		dag.Groups[name] = &Group{
+			Name:        name,
+			Description: g.Description,
+			Tasks:       g.Tasks,
+		}
+	}
+
+	dag.expandGroupDeps()
+	setPositions(dag, positions.taskOrder())
+
+	// Unknown keys are errors unless the definition opts out with strict = false
+	if dag.Lenient {
+		dag.Warnings = unknownKeys(positions, SeverityWarning)
+		dag.locate(dag.Warnings)
+	} else {
+		dag.parseErrors = unknownKeys(positions, SeverityError)
+		dag.locate(dag.parseErrors)
+	}
+
 	return dag, nil
 }
 
-// ValidateAll checks all workflow files in the specified directory for validity.
+// unknownKeys reports keys that do not correspond to a field of the workflow, a task or
+// a group. The decoders ignore them, so a misspelled key would otherwise have no effect.
+func unknownKeys(m *sourceMap, severity Severity) []Diagnostic {
+	var (
+		workflowKeys = fieldKeys(reflect.TypeOf(rawWorkflow{}))
+		taskKeys     = fieldKeys(reflect.TypeOf(rawTask{}))
+		groupKeys    = fieldKeys(reflect.TypeOf(rawGroup{}))
+		diags        []Diagnostic
+	)
+
+	for _, k := range m.keys {
+		var (
+			known []string
+			where string
+		)
+		switch {
+		case len(k.path) == 1:
+			known, where = workflowKeys, "workflow"
+		case len(k.path) == 3 && k.path[0] == "tasks":
+			known, where = taskKeys, "task "+k.path[1]
+		case len(k.path) == 3 && k.path[0] == "groups":
+			known, where = groupKeys, "group "+k.path[1]
+		default:
+			continue
+		}
+
+		name := k.path[len(k.path)-1]
+		if containsString(known, name) {
+			continue
+		}
+		message := fmt.Sprintf("unknown key %q in %s", name, where)
+		if severity == SeverityWarning {
+			message += " is ignored"
+		}
+		diags = append(diags, Diagnostic{
+			Severity:   severity,
+			Message:    message,
+			Line:       k.pos.Line,
+			Column:     k.pos.Column,
+			Key:        strings.Join(k.path, "."),
+			Suggestion: suggest(name, known),
+		})
+	}
+
+	return diags
+}
+
+// fieldKeys returns the TOML key names of the fields of a struct type.
+func fieldKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// setPositions numbers the tasks of d in declaration order. Tasks missing from order
+// are placed after the declared ones, sorted by name.
+func setPositions(d *DAG, order []string) {
+	next := 0
+	for _, name := range order {
+		if t, ok := d.Tasks[name]; ok {
+			t.Position = next
+			next++
+		}
+	}
+
+	var rest []string
+	for name := range d.Tasks {
+		if !containsString(order, name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		d.Tasks[name].Position = next
+		next++
+	}
+}
+
+// containsString reports whether s is in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAll checks all workflow files in the specified directory and its subdirectories for validity.
 func ValidateAll(dir string) error {
-	entries, err := os.ReadDir(dir)
+	entries, err := scanDir(dir, ScopeGlobal)
 	if err != nil {
 		logger.L().Error("failed to read workflows directory", zap.String("dir", dir), zap.Error(err))
 		return fmt.Errorf("failed to read directory %s: %w", dir, err)
@@ -103,14 +330,9 @@ func ValidateAll(dir string) error {
 
 	var validationErrors []error
 	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".toml") {
-			continue
-		}
-
-		workflowName := entry.Name()[:len(entry.Name())-5] // remove .toml
-		_, err := Load(workflowName)
+		_, err := LoadFile(entry.Path)
 		if err != nil {
-			logger.L().Error("invalid workflow", zap.String("workflow", workflowName), zap.Error(err))
+			logger.L().Error("invalid workflow", zap.String("workflow", entry.Name), zap.Error(err))
 			validationErrors = append(validationErrors, err)
 		}
 	}
@@ -0,0 +1,97 @@
+package dag
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/joelfokou/workflow/internal/git"
+	"github.com/joelfokou/workflow/internal/logger"
+	"go.uber.org/zap"
+)
+
+// LoadRevision reads a workflow as it existed at a git revision. The reference is resolved
+// like Resolve, either as a path or as a workflow name, but the file is read from the
+// repository at rev rather than from the working tree.
+func LoadRevision(ref, rev string) (*DAG, error) {
+	if ref == StdinSource {
+		return nil, fmt.Errorf("a workflow read from stdin cannot be loaded at a git revision")
+	}
+
+	path, name := ref, ""
+	if !IsPath(ref) {
+		path, _ = Locate(ref)
+		name = trimWorkflowExt(ref)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workflow path %s: %w", path, err)
+	}
+
+	commit, err := git.RevParse(absPath, rev)
+	if err != nil {
+		logger.L().Error("failed to resolve git revision", zap.String("path", absPath), zap.String("rev", rev), zap.Error(err))
+		return nil, fmt.Errorf("failed to resolve revision %s: %w", rev, err)
+	}
+
+	d, err := loadCommit(absPath, commit)
+	if err != nil {
+		return nil, err
+	}
+	d.Ref = name
+	d.Namespace = Namespace(name)
+	d.Revision = rev
+
+	return d, nil
+}
+
+// LoadCommit reads the workflow file at path as it existed at a resolved commit.
+func LoadCommit(path, commit string) (*DAG, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workflow path %s: %w", path, err)
+	}
+
+	return loadCommit(absPath, commit)
+}
+
+// loadCommit reads and parses an absolute workflow path at a commit.
+func loadCommit(absPath, commit string) (*DAG, error) {
+	data, err := git.ShowFile(absPath, commit)
+	if err != nil {
+		logger.L().Error("failed to read workflow at revision", zap.String("path", absPath), zap.String("commit", commit), zap.Error(err))
+		return nil, fmt.Errorf("failed to read %s at %s: %w", absPath, commit, err)
+	}
+
+	format, ok := FormatFromPath(absPath)
+	if !ok {
+		format = FormatTOML
+	}
+
+	d, err := LoadBytes(data, format, absPath)
+	if err != nil {
+		return nil, err
+	}
+	d.Commit = commit
+
+	return d, nil
+}
+
+// DetectGitState records the git commit and dirty state of the repository holding the
+// definition. Definitions outside a git repository, or read from stdin, are left unchanged.
+func (d *DAG) DetectGitState() {
+	if d.Source == "" || d.Source == StdinSource {
+		return
+	}
+
+	commit, dirty, err := git.State(d.Source)
+	if err != nil {
+		logger.L().Debug("workflow is not in a git repository", zap.String("path", d.Source), zap.Error(err))
+		return
+	}
+
+	if d.Commit == "" {
+		d.Commit = commit
+	}
+	d.Dirty = dirty
+}
@@ -0,0 +1,98 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaID identifies the JSON Schema for workflow definitions.
+const SchemaID = "https://github.com/joelfokou/workflow/schema/workflow.schema.json"
+
+// Schema returns a JSON Schema (draft 2020-12) describing workflow definitions. It is
+// generated from the types the loader decodes into, so it always matches the accepted
+// keys. The schema describes strict definitions: unknown keys are not allowed.
+func Schema() map[string]any {
+	schema := objectSchema(reflect.TypeOf(rawWorkflow{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = SchemaID
+	schema["title"] = "wf workflow"
+	schema["$defs"] = map[string]any{
+		"task":  objectSchema(reflect.TypeOf(rawTask{})),
+		"group": objectSchema(reflect.TypeOf(rawGroup{})),
+	}
+	return schema
+}
+
+// defNames maps the types referenced from the top level to their $defs entries.
+var defNames = map[reflect.Type]string{
+	reflect.TypeOf(rawTask{}):  "task",
+	reflect.TypeOf(rawGroup{}): "group",
+}
+
+// objectSchema describes a struct as an object with one property per field.
+// Fields without omitempty are required.
+func objectSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("toml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		prop := typeSchema(f.Type)
+		if enum := f.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		if desc := f.Tag.Get("desc"); desc != "" {
+			prop["description"] = desc
+		}
+		properties[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// typeSchema describes a field type.
+func typeSchema(t reflect.Type) map[string]any {
+	if def, ok := defNames[t]; ok {
+		return map[string]any{"$ref": "#/$defs/" + def}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"propertyNames":        map[string]any{"pattern": taskNamePattern.String()},
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		return objectSchema(t)
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,239 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Selection restricts a workflow run to a subgraph of its tasks.
+// Each non-empty criterion narrows the selection; Skip removes tasks last.
+// Task names may be given as group references such as "group:ingest".
+type Selection struct {
+	Only  []string `json:"only,omitempty"`  // Run exactly these tasks
+	From  []string `json:"from,omitempty"`  // Run these tasks and everything downstream
+	Until []string `json:"until,omitempty"` // Run these tasks and everything upstream
+	Skip  []string `json:"skip,omitempty"`  // Never run these tasks
+	Tags  []string `json:"tags,omitempty"`  // Run tasks carrying any of these tags; "!tag" excludes tasks carrying tag
+}
+
+// Empty reports whether the selection includes every task.
+func (s Selection) Empty() bool {
+	return len(s.Only) == 0 && len(s.From) == 0 && len(s.Until) == 0 && len(s.Skip) == 0 && len(s.Tags) == 0
+}
+
+// String formats the selection as the command line flags that produce it.
+func (s Selection) String() string {
+	var parts []string
+	for _, c := range []struct {
+		flag  string
+		names []string
+	}{
+		{"--only", s.Only},
+		{"--from", s.From},
+		{"--until", s.Until},
+		{"--skip", s.Skip},
+		{"--tags", s.Tags},
+	} {
+		if len(c.names) > 0 {
+			parts = append(parts, c.flag+" "+strings.Join(c.names, ","))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Ancestors returns the sorted names of all tasks the named task transitively depends on.
+func (d *DAG) Ancestors(name string) ([]string, error) {
+	if _, ok := d.Tasks[name]; !ok {
+		return nil, fmt.Errorf("task %s not found in workflow %s", name, d.Name)
+	}
+
+	seen := map[string]bool{}
+	var visit func(n string)
+	visit = func(n string) {
+		for _, dep := range d.Tasks[n].DependsOn {
+			if !seen[dep] {
+				seen[dep] = true
+				visit(dep)
+			}
+		}
+	}
+	visit(name)
+
+	return sortedKeys(seen), nil
+}
+
+// Descendants returns the sorted names of all tasks that transitively depend on the named task.
+func (d *DAG) Descendants(name string) ([]string, error) {
+	if _, ok := d.Tasks[name]; !ok {
+		return nil, fmt.Errorf("task %s not found in workflow %s", name, d.Name)
+	}
+
+	children := map[string][]string{}
+	for _, t := range d.Tasks {
+		for _, dep := range t.DependsOn {
+			children[dep] = append(children[dep], t.Name)
+		}
+	}
+
+	seen := map[string]bool{}
+	var visit func(n string)
+	visit = func(n string) {
+		for _, child := range children[n] {
+			if !seen[child] {
+				seen[child] = true
+				visit(child)
+			}
+		}
+	}
+	visit(name)
+
+	return sortedKeys(seen), nil
+}
+
+// Select returns a new DAG containing only the tasks matched by sel. Dependencies on
+// tasks outside the selection are dropped, so the selected tasks run as if those
+// dependencies had already succeeded. The workflow metadata is copied unchanged.
+func (d *DAG) Select(sel Selection) (*DAG, error) {
+	selected := map[string]bool{}
+	for name := range d.Tasks {
+		selected[name] = true
+	}
+
+	narrow := func(refs []string, expand func(string) ([]string, error)) error {
+		if len(refs) == 0 {
+			return nil
+		}
+
+		names, err := d.resolveRefs(refs)
+		if err != nil {
+			return err
+		}
+
+		keep := map[string]bool{}
+		for _, name := range names {
+			if _, ok := d.Tasks[name]; !ok {
+				return fmt.Errorf("task %s not found in workflow %s", name, d.Name)
+			}
+			keep[name] = true
+
+			if expand == nil {
+				continue
+			}
+			related, err := expand(name)
+			if err != nil {
+				return err
+			}
+			for _, r := range related {
+				keep[r] = true
+			}
+		}
+
+		for name := range selected {
+			if !keep[name] {
+				delete(selected, name)
+			}
+		}
+		return nil
+	}
+
+	if err := narrow(sel.Only, nil); err != nil {
+		return nil, err
+	}
+	if err := narrow(sel.From, d.Descendants); err != nil {
+		return nil, err
+	}
+	if err := narrow(sel.Until, d.Ancestors); err != nil {
+		return nil, err
+	}
+
+	include, exclude := splitTags(sel.Tags)
+	for name := range selected {
+		t := d.Tasks[name]
+		if (len(include) > 0 && !t.HasAnyTag(include)) || t.HasAnyTag(exclude) {
+			delete(selected, name)
+		}
+	}
+
+	skip, err := d.resolveRefs(sel.Skip)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range skip {
+		if _, ok := d.Tasks[name]; !ok {
+			return nil, fmt.Errorf("task %s not found in workflow %s", name, d.Name)
+		}
+		delete(selected, name)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("selection %q matches no tasks in workflow %s", sel.String(), d.Name)
+	}
+
+	sub := *d
+	sub.Tasks = make(map[string]*Task, len(selected))
+	for name := range selected {
+		t := *d.Tasks[name]
+		t.DependsOn = nil
+		t.DependsOnRefs = nil
+		for _, dep := range d.Tasks[name].DependsOn {
+			if selected[dep] {
+				t.DependsOn = append(t.DependsOn, dep)
+			}
+		}
+		sub.Tasks[name] = &t
+	}
+
+	sub.Groups = nil
+	for _, g := range d.Groups {
+		var members []string
+		for _, member := range g.Tasks {
+			if selected[member] {
+				members = append(members, member)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		if sub.Groups == nil {
+			sub.Groups = make(map[string]*Group)
+		}
+		sub.Groups[g.Name] = &Group{Name: g.Name, Description: g.Description, Tasks: members}
+	}
+
+	return &sub, nil
+}
+
+// splitTags separates tag selectors into tags to include and tags to exclude ("!tag").
+func splitTags(tags []string) (include, exclude []string) {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "!") {
+			exclude = append(exclude, strings.TrimPrefix(tag, "!"))
+		} else {
+			include = append(include, tag)
+		}
+	}
+	return include, exclude
+}
+
+// HasAnyTag reports whether the task carries at least one of the given tags.
+func (t *Task) HasAnyTag(tags []string) bool {
+	for _, want := range tags {
+		for _, tag := range t.Tags {
+			if tag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a set in sorted order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
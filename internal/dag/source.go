@@ -0,0 +1,262 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"go.yaml.in/yaml/v3"
+)
+
+// sourceMap records where keys and list elements appear in a workflow definition.
+// Decoding into rawWorkflow loses both positions and declaration order, so the
+// definition is scanned again with a parser that preserves them. Parts of the
+// definition that cannot be scanned are simply missing from the map.
+type sourceMap struct {
+	keys      []sourceKey                    // every key, in document order
+	positions map[string]Position            // key path -> position of the key
+	elements  map[string]map[string]Position // key path -> list element -> position
+}
+
+// sourceKey is a key of the definition and where it is first declared.
+type sourceKey struct {
+	path []string
+	pos  Position
+}
+
+// mapSource scans a definition in the given format.
+func mapSource(data []byte, format Format) *sourceMap {
+	m := &sourceMap{
+		positions: map[string]Position{},
+		elements:  map[string]map[string]Position{},
+	}
+
+	switch format {
+	case FormatTOML:
+		mapTOML(m, data)
+	case FormatYAML:
+		mapYAML(m, data)
+	case FormatJSON:
+		mapJSON(m, data)
+	}
+
+	return m
+}
+
+// addKey records the first declaration of a key.
+func (m *sourceMap) addKey(path []string, pos Position) {
+	key := strings.Join(path, ".")
+	if _, ok := m.positions[key]; ok {
+		return
+	}
+	m.positions[key] = pos
+	m.keys = append(m.keys, sourceKey{path: path, pos: pos})
+}
+
+// addElement records the position of a string element of the list at path.
+func (m *sourceMap) addElement(path []string, value string, pos Position) {
+	key := strings.Join(path, ".")
+	if m.elements[key] == nil {
+		m.elements[key] = map[string]Position{}
+	}
+	if _, ok := m.elements[key][value]; !ok {
+		m.elements[key][value] = pos
+	}
+}
+
+// lookup returns the position of a list element, falling back to its key and then to
+// the closest enclosing key that was declared.
+func (m *sourceMap) lookup(key, value string) (Position, bool) {
+	if m == nil {
+		return Position{}, false
+	}
+
+	if value != "" {
+		if pos, ok := m.elements[key][value]; ok {
+			return pos, true
+		}
+	}
+
+	for key != "" {
+		if pos, ok := m.positions[key]; ok {
+			return pos, true
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+
+	return Position{}, false
+}
+
+// taskOrder returns the task names in the order they are declared.
+func (m *sourceMap) taskOrder() []string {
+	var (
+		order []string
+		seen  = map[string]bool{}
+	)
+	for _, k := range m.keys {
+		if len(k.path) >= 2 && k.path[0] == "tasks" && !seen[k.path[1]] {
+			seen[k.path[1]] = true
+			order = append(order, k.path[1])
+		}
+	}
+	return order
+}
+
+// mapTOML records the keys of [table] headers, dotted key assignments and inline tables.
+func mapTOML(m *sourceMap, data []byte) {
+	p := unstable.Parser{}
+	p.Reset(data)
+
+	position := func(n *unstable.Node) Position {
+		shape := p.Shape(n.Raw)
+		return Position{Line: shape.Start.Line, Column: shape.Start.Column}
+	}
+
+	// addKeys records each prefix of a dotted key below base and returns the full path
+	addKeys := func(base []string, n *unstable.Node) []string {
+		path := append([]string{}, base...)
+		it := n.Key()
+		for it.Next() {
+			path = append(path, string(it.Node().Data))
+			m.addKey(append([]string{}, path...), position(it.Node()))
+		}
+		return path
+	}
+
+	var walkValue func(path []string, v *unstable.Node)
+	walkValue = func(path []string, v *unstable.Node) {
+		switch v.Kind {
+		case unstable.Array:
+			it := v.Children()
+			for it.Next() {
+				if el := it.Node(); el.Kind == unstable.String {
+					m.addElement(path, string(el.Data), position(el))
+				}
+			}
+		case unstable.InlineTable:
+			it := v.Children()
+			for it.Next() {
+				kv := it.Node()
+				walkValue(addKeys(path, kv), kv.Value())
+			}
+		}
+	}
+
+	var table []string
+	for p.NextExpression() {
+		e := p.Expression()
+
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			table = addKeys(nil, e)
+		case unstable.KeyValue:
+			walkValue(addKeys(table, e), e.Value())
+		}
+	}
+}
+
+// mapYAML records the keys of every mapping and the scalar elements of every sequence.
+func mapYAML(m *sourceMap, data []byte) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return
+	}
+
+	var walk func(path []string, n *yaml.Node)
+	walk = func(path []string, n *yaml.Node) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := n.Content[i]
+				key := append(append([]string{}, path...), k.Value)
+				m.addKey(key, Position{Line: k.Line, Column: k.Column})
+				walk(key, n.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for _, el := range n.Content {
+				if el.Kind == yaml.ScalarNode {
+					m.addElement(path, el.Value, Position{Line: el.Line, Column: el.Column})
+				}
+			}
+		}
+	}
+
+	walk(nil, doc.Content[0])
+}
+
+// mapJSON records the keys of every object and the string elements of every array.
+func mapJSON(m *sourceMap, data []byte) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// next returns the next token and the position it starts at
+	next := func() (json.Token, Position, error) {
+		offset := int(dec.InputOffset())
+		for offset < len(data) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+			offset++
+		}
+		tok, err := dec.Token()
+		return tok, offsetPosition(data, offset), err
+	}
+
+	var walk func(path []string) bool
+	walk = func(path []string) bool {
+		tok, _, err := next()
+		if err != nil {
+			return false
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				k, kpos, err := next()
+				name, ok := k.(string)
+				if err != nil || !ok {
+					return false
+				}
+				key := append(append([]string{}, path...), name)
+				m.addKey(key, kpos)
+				if !walk(key) {
+					return false
+				}
+			}
+		case json.Delim('['):
+			for dec.More() {
+				offset := int(dec.InputOffset())
+				var el json.RawMessage
+				if err := dec.Decode(&el); err != nil {
+					return false
+				}
+				var s string
+				if json.Unmarshal(el, &s) == nil {
+					start := offset + bytes.IndexByte(data[offset:], '"')
+					m.addElement(path, s, offsetPosition(data, start))
+				}
+			}
+		default:
+			return true
+		}
+
+		// Consume the closing delimiter
+		_, err = dec.Token()
+		return err == nil
+	}
+
+	walk(nil)
+}
+
+// offsetPosition converts a byte offset into a line and column.
+func offsetPosition(data []byte, offset int) Position {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	lead := data[:offset]
+	return Position{
+		Line:   bytes.Count(lead, []byte{'\n'}) + 1,
+		Column: offset - bytes.LastIndexByte(lead, '\n'),
+	}
+}
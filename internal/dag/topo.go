@@ -8,7 +8,9 @@ import (
 	"go.uber.org/zap"
 )
 
-// TopologicalSort returns tasks in correct execution order.
+// TopologicalSort returns tasks in execution order. The order is deterministic: whenever
+// several tasks are ready to run, the one with the highest priority runs first, then the
+// one declared first in the workflow file, then the one whose name sorts first.
 func (d *DAG) TopologicalSort() ([]*Task, error) {
 	neighborList := make(map[string][]string)
 	inDegree := make(map[string]int)
@@ -23,29 +25,28 @@ func (d *DAG) TopologicalSort() ([]*Task, error) {
 		}
 	}
 
-	for dep := range neighborList {
-		sort.Strings(neighborList[dep])
-	}
-
-	// Initialise queue with tasks having in-degree of 0
-	queue := []string{}
+	// Initialise the ready set with tasks having in-degree of 0
+	ready := []*Task{}
 	for name, deg := range inDegree {
 		if deg == 0 {
-			queue = append(queue, name)
+			ready = append(ready, d.Tasks[name])
 		}
 	}
 
 	result := []*Task{}
 
-	for len(queue) > 0 {
-		n := queue[0]
-		queue = queue[1:]
-		result = append(result, d.Tasks[n])
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			return runsBefore(ready[i], ready[j])
+		})
+		t := ready[0]
+		ready = ready[1:]
+		result = append(result, t)
 
-		for _, neighbor := range neighborList[n] {
+		for _, neighbor := range neighborList[t.Name] {
 			inDegree[neighbor]--
 			if inDegree[neighbor] == 0 {
-				queue = append(queue, neighbor)
+				ready = append(ready, d.Tasks[neighbor])
 			}
 		}
 	}
@@ -58,3 +59,15 @@ func (d *DAG) TopologicalSort() ([]*Task, error) {
 
 	return result, nil
 }
+
+// runsBefore reports whether task a should run before task b when both are ready:
+// higher priority first, then declaration order, then name.
+func runsBefore(a, b *Task) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if a.Position != b.Position {
+		return a.Position < b.Position
+	}
+	return a.Name < b.Name
+}
@@ -1,8 +1,8 @@
 package dag
 
 import (
-	"fmt"
 	"regexp"
+	"sort"
 
 	"github.com/joelfokou/workflow/internal/logger"
 	"go.uber.org/zap"
@@ -13,56 +13,141 @@ var taskNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
 // Validate checks the DAG for common issues:
 // - Valid workflow name
+// - Known concurrency policy
 // - Tasks exist
 // - No cycles
 // - No duplicate task names
 // - Valid characters in task names
 // - Tasks have commands
 // - All dependencies reference existing tasks
+// - Groups have valid names and reference existing tasks
+// - No unknown keys, unless the definition sets strict = false
+//
+// Every problem is collected in a single pass and returned as a *ValidationError, together
+// with any warnings found while parsing.
+// For a DAG parsed from a definition, each problem carries its position in the file
+// and, for misspelled names, a suggestion.
 func (d *DAG) Validate() error {
+	diags := append([]Diagnostic{}, d.parseErrors...)
+
 	// Check workflow name
 	if d.Name == "" {
-		return fmt.Errorf("workflow name is required")
+		diags = append(diags, errorAt("name", "workflow name is required"))
+	}
+
+	// Check concurrency policy
+	if _, err := ParseConcurrency(string(d.Concurrency)); err != nil {
+		diag := errorAt("concurrency", "%s", err.Error())
+		diag.Suggestion = suggest(string(d.Concurrency), concurrencyPolicies)
+		diags = append(diags, diag)
 	}
 
 	// Check tasks exist
 	if len(d.Tasks) == 0 {
-		return fmt.Errorf("no tasks defined")
+		diags = append(diags, errorAt("tasks", "no tasks defined"))
 	}
 
 	// Check for duplicate task names and invalid characters
+	names := d.taskNames()
 	seen := make(map[string]struct{}, len(d.Tasks))
-	for name, t := range d.Tasks {
+	brokenDeps := false
+	for _, name := range names {
+		t := d.Tasks[name]
+		key := "tasks." + name
+
 		// Check for duplicate task names
 		if _, ok := seen[name]; ok {
-			return fmt.Errorf("duplicate task name: %s", name)
+			diags = append(diags, errorAt(key, "duplicate task name: %s", name))
 		}
 		seen[name] = struct{}{}
 
 		// Validate task name format
 		if !taskNamePattern.MatchString(name) {
-			return fmt.Errorf("invalid task name %q (allowed: letters, digits, _, -)", name)
+			diags = append(diags, errorAt(key, "invalid task name %q (allowed: letters, digits, _, -)", name))
 		}
 
 		// Check task has a command
 		if t.Cmd == "" {
 			logger.L().Error("task missing command", zap.String("task", name))
-			return fmt.Errorf("task %s has no command defined", name)
+			diags = append(diags, errorAt(key+".cmd", "task %s has no command defined", name))
 		}
 
 		// Check dependencies exist
 		for _, dep := range t.DependsOn {
+			if IsGroupRef(dep) {
+				brokenDeps = true
+				diags = append(diags, d.groupRefProblem(name, dep))
+				continue
+			}
 			if _, ok := d.Tasks[dep]; !ok {
 				logger.L().Error("missing dependency", zap.String("task", name), zap.String("dependency", dep))
-				return fmt.Errorf("task %s depends on missing task %s", name, dep)
+				brokenDeps = true
+
+				diag := errorAt(key+".depends_on", "task %s depends on missing task %s", name, dep)
+				diag.value = dep
+				diag.Suggestion = suggest(dep, without(names, name))
+				diags = append(diags, diag)
 			}
 		}
 	}
 
-	// Check for cycles
-	if _, err := d.TopologicalSort(); err != nil {
-		return err
+	diags = append(diags, d.validateGroups()...)
+
+	// Check for cycles among the dependencies that resolve, so that a missing task does
+	// not hide a cycle elsewhere
+	resolved := d
+	if brokenDeps {
+		resolved = d.withResolvedDeps()
+	}
+	if _, err := resolved.TopologicalSort(); err != nil {
+		diags = append(diags, errorAt("", "%s", err))
+	}
+
+	if len(diags) == 0 {
+		return nil
 	}
 
-	return nil
+	// Report warnings alongside the errors, as a misspelled key is often their cause
+	d.locate(diags[len(d.parseErrors):])
+	diags = append(diags, d.Warnings...)
+	sortDiagnostics(diags)
+	return &ValidationError{Diagnostics: diags}
+}
+
+// taskNames returns the names of all tasks, sorted.
+func (d *DAG) taskNames() []string {
+	names := make([]string, 0, len(d.Tasks))
+	for name := range d.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withResolvedDeps returns a copy of d in which each task keeps only the dependencies
+// naming an existing task.
+func (d *DAG) withResolvedDeps() *DAG {
+	resolved := &DAG{Name: d.Name, Tasks: make(map[string]*Task, len(d.Tasks))}
+	for name, t := range d.Tasks {
+		task := *t
+		task.DependsOn = nil
+		for _, dep := range t.DependsOn {
+			if _, ok := d.Tasks[dep]; ok {
+				task.DependsOn = append(task.DependsOn, dep)
+			}
+		}
+		resolved.Tasks[name] = &task
+	}
+	return resolved
+}
+
+// without returns a copy of list with every occurrence of s removed.
+func without(list []string, s string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
 }
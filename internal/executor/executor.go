@@ -4,10 +4,13 @@ package executor
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/joelfokou/workflow/internal/config"
@@ -17,14 +20,41 @@ import (
 	"go.uber.org/zap"
 )
 
+// ResumePolicy controls how Resume handles a workflow definition that changed since the run started.
+type ResumePolicy int
+
+const (
+	// ResumeRefuseChanges refuses to resume when the definition no longer matches the run.
+	ResumeRefuseChanges ResumePolicy = iota
+	// ResumeFromSnapshot resumes against the definition snapshot stored with the run.
+	ResumeFromSnapshot
+	// ResumeAcceptChanges resumes against the current definition and records it on the run.
+	ResumeAcceptChanges
+)
+
+// DefinitionChangedError is returned by Resume when the workflow definition changed since the run started.
+type DefinitionChangedError struct {
+	RunID       string
+	RunHash     string
+	CurrentHash string
+}
+
+func (e *DefinitionChangedError) Error() string {
+	return fmt.Sprintf("workflow definition changed since run %s started (run hash %.12s, current hash %.12s)", e.RunID, e.RunHash, e.CurrentHash)
+}
+
 // Executor is responsible for executing workflows defined as DAGs.
 type Executor struct {
-	RunStore           *run.Store
+	RunStore           run.Repository
 	DefaultTaskTimeout time.Duration // Optional global timeout per task (0 = none)
+	ResumePolicy       ResumePolicy  // How Resume handles a changed definition
+	Selection          dag.Selection // Optional subgraph of tasks for Run (empty = all tasks)
+	HeartbeatInterval  time.Duration // How often a running run records that it is alive (0 = run.HeartbeatInterval)
+	LockPollInterval   time.Duration // How often a queued run retries the workflow lock (0 = 1s)
 }
 
 // NewExecutor is a creates a new Executor with the given RunStore.
-func NewExecutor(store *run.Store) *Executor {
+func NewExecutor(store run.Repository) *Executor {
 	return &Executor{
 		RunStore:           store,
 		DefaultTaskTimeout: 0,
@@ -41,10 +71,56 @@ func (e *Executor) Run(ctx context.Context, d *dag.DAG) error {
 		return err
 	}
 
-	wr, err := e.RunStore.NewWorkflowRun(d.Name, dagHash)
+	snapshot, err := d.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	wr := &run.WorkflowRun{
+		Workflow:     d.QualifiedName(),
+		WorkflowHash: dagHash,
+		Source:       sql.NullString{String: d.Source, Valid: d.Source != ""},
+		Definition:   sql.NullString{String: string(snapshot), Valid: true},
+	}
+
+	// The hash and snapshot cover the full definition; only the selected subgraph is executed
+	if !e.Selection.Empty() {
+		selection, err := json.Marshal(e.Selection)
+		if err != nil {
+			return err
+		}
+		sub, err := d.Select(e.Selection)
+		if err != nil {
+			logger.L().Error("invalid task selection", zap.String("workflow", d.Name), zap.Error(err))
+			return err
+		}
+		d = sub
+		wr.Selection = sql.NullString{String: string(selection), Valid: true}
+		logger.L().Info("running selected tasks", zap.String("selection", e.Selection.String()), zap.Int("tasks", len(d.Tasks)))
+	}
+
+	if d.Commit != "" {
+		wr.GitCommit = sql.NullString{String: d.Commit, Valid: true}
+		wr.GitDirty = sql.NullBool{Bool: d.Dirty, Valid: true}
+	}
+	if d.Revision != "" {
+		wr.GitRev = sql.NullString{String: d.Revision, Valid: true}
+	}
+
+	// Take the locks before recording the run, so a forbidden run leaves no trace
+	wr.ID = run.NewRunID()
+	locks, err := e.acquireLocks(ctx, wr.Workflow, d.ConcurrencyPolicy(), wr.ID)
 	if err != nil {
 		return err
 	}
+	defer e.releaseLocks(locks, wr.ID)
+
+	if err := e.RunStore.CreateWorkflowRun(wr); err != nil {
+		return err
+	}
+
+	ctx, stopHeartbeat := e.startHeartbeat(ctx, wr.ID, locks)
+	defer stopHeartbeat()
 
 	order, err := d.TopologicalSort()
 	if err != nil {
@@ -66,8 +142,8 @@ func (e *Executor) Run(ctx context.Context, d *dag.DAG) error {
 			wr.Status = run.StatusFailed
 			wr.EndedAt = sql.NullTime{Time: now, Valid: true}
 			_ = e.RunStore.Update(wr)
-			logger.L().Error("workflow cancelled", zap.String("workflow", d.Name), zap.Error(ctx.Err()))
-			return fmt.Errorf("workflow cancelled: %w", ctx.Err())
+			logger.L().Error("workflow cancelled", zap.String("workflow", d.Name), zap.Error(context.Cause(ctx)))
+			return fmt.Errorf("workflow cancelled: %w", context.Cause(ctx))
 		default:
 		}
 
@@ -107,43 +183,49 @@ func (e *Executor) Run(ctx context.Context, d *dag.DAG) error {
 				code := int64(exitErr.ExitCode())
 				tr.ExitCode = sql.NullInt64{Int64: code, Valid: true}
 				tr.LastError = exitErr.Error()
-				_ = e.RunStore.UpdateTaskRun(tr)
 			} else if err != nil {
 				// Command execution error (not an exit code error)
 				tr.LastError = err.Error()
 				tr.ExitCode = sql.NullInt64{Int64: 1, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
 			} else {
 				// Success
 				tr.ExitCode = sql.NullInt64{Int64: 0, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
 			}
 
 			if err == nil {
 				now := time.Now()
 				tr.Status = run.TaskSuccess
 				tr.EndedAt = sql.NullTime{Time: now, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
+				e.saveTaskRun(tr)
 
 				logger.L().Info("task completed", zap.String("task", t.Name))
 				fmt.Println("Task completed:", t.Name)
 				break
 			}
 
-			if attempt == t.Retries+1 {
+			// A cancelled run is not retried; its error says why it was cancelled
+			if ctx.Err() != nil {
+				err = fmt.Errorf("%w: %w", err, context.Cause(ctx))
+			}
+
+			if attempt == t.Retries+1 || ctx.Err() != nil {
 				now := time.Now()
 				tr.Status = run.TaskFailed
 				tr.EndedAt = sql.NullTime{Time: now, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
-
 				wr.Status = run.StatusFailed
 				wr.EndedAt = sql.NullTime{Time: now, Valid: true}
-				_ = e.RunStore.Update(wr)
+
+				// The task and the run fail together, so a crash cannot leave a failed task in a running run
+				if err := e.RunStore.FinishTask(tr, wr); err != nil {
+					logger.L().Error("failed to record task failure", zap.String("task", t.Name), zap.Error(err))
+				}
 
 				logger.L().Error("task failed => workflow failed", zap.String("task", t.Name), zap.String("workflow", d.Name), zap.Error(err))
 				return fmt.Errorf("task %s failed => workflow %s failed: %w", t.Name, d.Name, err)
 			}
 
+			e.saveTaskRun(tr)
+
 			logger.L().Debug("retrying task",
 				zap.String("workflow", d.Name),
 				zap.String("task", t.Name),
@@ -163,16 +245,280 @@ func (e *Executor) Run(ctx context.Context, d *dag.DAG) error {
 	return nil
 }
 
+// loadRunDefinition reloads the workflow definition from the source recorded on the run.
+// Runs started from a git revision are reloaded from the recorded commit. Runs recorded
+// before sources were tracked fall back to the workflows directory.
+// It returns nil without an error for runs read from stdin, which cannot be reloaded.
+func loadRunDefinition(wr *run.WorkflowRun) (*dag.DAG, error) {
+	if !wr.Source.Valid || wr.Source.String == "" {
+		return dag.Load(wr.Workflow)
+	}
+
+	if wr.Source.String == dag.StdinSource {
+		return nil, nil
+	}
+
+	if wr.GitRev.Valid && wr.GitCommit.Valid {
+		return dag.LoadCommit(wr.Source.String, wr.GitCommit.String)
+	}
+
+	return dag.LoadFile(wr.Source.String)
+}
+
+// resumeDefinition selects the DAG to resume a run against, comparing the current
+// definition with the hash recorded on the run and applying the executor's ResumePolicy.
+func (e *Executor) resumeDefinition(wr *run.WorkflowRun) (*dag.DAG, error) {
+	var snapshot *dag.DAG
+	if wr.Definition.Valid {
+		d, err := dag.FromSnapshot([]byte(wr.Definition.String))
+		if err != nil {
+			return nil, err
+		}
+		snapshot = d
+	}
+
+	current, err := loadRunDefinition(wr)
+	if err != nil {
+		if snapshot == nil || e.ResumePolicy != ResumeFromSnapshot {
+			return nil, err
+		}
+		logger.L().Warn("failed to reload workflow, using snapshot", zap.String("run_id", wr.ID), zap.Error(err))
+	}
+
+	if current == nil {
+		if snapshot == nil {
+			return nil, fmt.Errorf("run %s was read from stdin and has no stored definition", wr.ID)
+		}
+		return snapshot, nil
+	}
+
+	currentHash, err := current.ComputeHash()
+	if err != nil {
+		return nil, err
+	}
+
+	if currentHash == wr.WorkflowHash {
+		return current, nil
+	}
+
+	switch e.ResumePolicy {
+	case ResumeFromSnapshot:
+		if snapshot == nil {
+			return nil, fmt.Errorf("run %s has no stored definition snapshot", wr.ID)
+		}
+		logger.L().Info("resuming against stored definition", zap.String("run_id", wr.ID))
+		fmt.Println("Workflow definition changed; resuming against the stored snapshot")
+		return snapshot, nil
+
+	case ResumeAcceptChanges:
+		data, err := current.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		wr.WorkflowHash = currentHash
+		wr.Definition = sql.NullString{String: string(data), Valid: true}
+		if err := e.RunStore.Update(wr); err != nil {
+			return nil, err
+		}
+		logger.L().Info("resuming against changed definition", zap.String("run_id", wr.ID), zap.String("workflow_hash", currentHash))
+		fmt.Println("Workflow definition changed; resuming against the new definition")
+		return current, nil
+
+	default:
+		return nil, &DefinitionChangedError{RunID: wr.ID, RunHash: wr.WorkflowHash, CurrentHash: currentHash}
+	}
+}
+
+// selectRunTasks restricts d to the task selection recorded on the run, if any.
+func selectRunTasks(d *dag.DAG, wr *run.WorkflowRun) (*dag.DAG, error) {
+	if !wr.Selection.Valid || wr.Selection.String == "" {
+		return d, nil
+	}
+
+	var sel dag.Selection
+	if err := json.Unmarshal([]byte(wr.Selection.String), &sel); err != nil {
+		return nil, fmt.Errorf("failed to decode task selection of run %s: %w", wr.ID, err)
+	}
+
+	sub, err := d.Select(sel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply task selection of run %s: %w", wr.ID, err)
+	}
+	return sub, nil
+}
+
+// Resume continues a failed workflow run, skipping tasks that already succeeded.
 func (e *Executor) Resume(ctx context.Context, wr *run.WorkflowRun) error {
 	fmt.Printf("Resuming workflow run: %s\n", wr.ID)
 	logger.L().Info("resuming workflow", zap.String("workflow", wr.Workflow), zap.String("run_id", wr.ID))
 
-	d, err := dag.Load(wr.Workflow)
+	d, err := e.resumeDefinition(wr)
 	if err != nil {
 		logger.L().Error("failed to load workflow", zap.String("workflow", wr.Workflow), zap.Error(err))
 		return fmt.Errorf("failed to load workflow '%s': %w", wr.Workflow, err)
 	}
 
+	if d, err = selectRunTasks(d, wr); err != nil {
+		return err
+	}
+
+	locks, err := e.acquireLocks(ctx, wr.Workflow, d.ConcurrencyPolicy(), wr.ID)
+	if err != nil {
+		return err
+	}
+	defer e.releaseLocks(locks, wr.ID)
+
+	if err := e.RunStore.Reopen(wr); err != nil {
+		return err
+	}
+
+	ctx, stopHeartbeat := e.startHeartbeat(ctx, wr.ID, locks)
+	defer stopHeartbeat()
+
+	if err := e.continueRun(ctx, wr, d, d); err != nil {
+		return err
+	}
+
+	logger.L().Info("workflow resumed and completed", zap.String("workflow", d.Name))
+	fmt.Println("Workflow resumed and completed:", d.Name)
+
+	return nil
+}
+
+// Rerun executes a task of an existing run again, against the definition snapshot
+// stored with the run. With downstream set, every task that depends on it is rerun
+// as well. The previous task runs are archived rather than deleted.
+func (e *Executor) Rerun(ctx context.Context, wr *run.WorkflowRun, task string, downstream bool) error {
+	if !wr.Definition.Valid {
+		return fmt.Errorf("run %s has no stored definition snapshot (recorded by an earlier version)", wr.ID)
+	}
+
+	d, err := dag.FromSnapshot([]byte(wr.Definition.String))
+	if err != nil {
+		return err
+	}
+
+	if d, err = selectRunTasks(d, wr); err != nil {
+		return err
+	}
+
+	// The run succeeds only if every task of its selection succeeds, not just those rerun
+	scope := d
+
+	tasks := []string{task}
+	if downstream {
+		descendants, err := d.Descendants(task)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, descendants...)
+	}
+
+	d, err = d.Select(dag.Selection{Only: tasks})
+	if err != nil {
+		return err
+	}
+
+	locks, err := e.acquireLocks(ctx, wr.Workflow, d.ConcurrencyPolicy(), wr.ID)
+	if err != nil {
+		return err
+	}
+	defer e.releaseLocks(locks, wr.ID)
+
+	fmt.Printf("Rerunning %d task(s) of workflow run: %s\n", len(d.Tasks), wr.ID)
+	logger.L().Info("rerunning tasks", zap.String("run_id", wr.ID), zap.Strings("tasks", tasks))
+
+	if err := e.RunStore.ResetTaskRuns(wr.ID, tasks); err != nil {
+		logger.L().Error("failed to reset task runs", zap.String("run_id", wr.ID), zap.Error(err))
+		return fmt.Errorf("failed to reset task runs: %w", err)
+	}
+
+	if err := e.RunStore.Reopen(wr); err != nil {
+		return err
+	}
+
+	ctx, stopHeartbeat := e.startHeartbeat(ctx, wr.ID, locks)
+	defer stopHeartbeat()
+
+	if err := e.continueRun(ctx, wr, d, scope); err != nil {
+		return err
+	}
+
+	logger.L().Info("rerun completed", zap.String("workflow", d.Name), zap.String("run_id", wr.ID), zap.String("status", string(wr.Status)))
+	fmt.Println("Rerun completed:", d.Name)
+	if wr.Status != run.StatusSuccess {
+		fmt.Printf("Run %s is still %s: other tasks have not succeeded; resume it with 'wf resume'\n", wr.ID, wr.Status)
+	}
+
+	return nil
+}
+
+// startHeartbeat records a heartbeat for the run periodically, so a run left behind by a
+// crash or reboot can be told apart from one still executing, and renews the leases of the
+// locks it holds. The returned context is cancelled if a lock is lost, so that the run
+// stops rather than execute alongside the run that took the lock over. Call the returned
+// function to stop the heartbeat.
+func (e *Executor) startHeartbeat(ctx context.Context, runID string, locks []string) (context.Context, func()) {
+	interval := e.heartbeatInterval()
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := e.RunStore.Heartbeat(runID, now); err != nil {
+					logger.L().Warn("failed to record heartbeat", zap.String("run_id", runID), zap.Error(err))
+				}
+				for _, name := range locks {
+					err := e.RunStore.RenewLock(name, runID, e.lockLease())
+					if errors.Is(err, run.ErrLockLost) {
+						logger.L().Error("lock lost, stopping run", zap.String("lock", name), zap.String("run_id", runID))
+						cancel(err)
+						return
+					}
+					if err != nil {
+						logger.L().Warn("failed to renew lock", zap.String("lock", name), zap.String("run_id", runID), zap.Error(err))
+					}
+				}
+			}
+		}
+	}()
+
+	return ctx, func() {
+		close(done)
+		wg.Wait()
+		cancel(nil)
+	}
+}
+
+// saveTaskRun persists the state of a task run, logging rather than failing the run if it cannot.
+func (e *Executor) saveTaskRun(tr *run.TaskRun) {
+	if err := e.RunStore.UpdateTaskRun(tr); err != nil {
+		logger.L().Warn("failed to save task run", zap.String("task", tr.Name), zap.String("run_id", tr.RunID), zap.Error(err))
+	}
+}
+
+// heartbeatInterval returns how often a running run records that it is alive.
+func (e *Executor) heartbeatInterval() time.Duration {
+	if e.HeartbeatInterval > 0 {
+		return e.HeartbeatInterval
+	}
+	return run.HeartbeatInterval
+}
+
+// continueRun executes the tasks of d that have not yet succeeded within an existing run.
+// scope holds every task of the run, of which d may be a subset: once d completes, the run
+// succeeds only if every task in scope has succeeded.
+func (e *Executor) continueRun(ctx context.Context, wr *run.WorkflowRun, d, scope *dag.DAG) error {
 	order, err := d.TopologicalSort()
 	if err != nil {
 		now := time.Now()
@@ -204,8 +550,8 @@ func (e *Executor) Resume(ctx context.Context, wr *run.WorkflowRun) error {
 			wr.Status = run.StatusFailed
 			wr.EndedAt = sql.NullTime{Time: now, Valid: true}
 			_ = e.RunStore.Update(wr)
-			logger.L().Error("workflow cancelled", zap.String("workflow", d.Name), zap.Error(ctx.Err()))
-			return fmt.Errorf("workflow cancelled: %w", ctx.Err())
+			logger.L().Error("workflow cancelled", zap.String("workflow", d.Name), zap.Error(context.Cause(ctx)))
+			return fmt.Errorf("workflow cancelled: %w", context.Cause(ctx))
 		default:
 		}
 
@@ -222,10 +568,18 @@ func (e *Executor) Resume(ctx context.Context, wr *run.WorkflowRun) error {
 				logger.L().Error("failed to save task run", zap.String("task", t.Name), zap.Error(err))
 				return err
 			}
+		} else {
+			// A new execution of the task: attempts continue from earlier executions so
+			// their logs are kept, while its start and retries are its own
+			tr.Status = run.TaskRunning
+			tr.StartedAt = time.Now()
+			tr.EndedAt = sql.NullTime{}
+			tr.PriorAttempts = tr.Attempts
+			_ = e.RunStore.UpdateTaskRun(tr)
 		}
 
 		for attempt := 1; attempt <= t.Retries+1; attempt++ {
-			tr.Attempts = attempt
+			tr.Attempts = tr.PriorAttempts + attempt
 
 			cmd := exec.CommandContext(ctx, "bash", "-c", t.Cmd)
 			setCmdProcessAttrs(cmd)
@@ -238,7 +592,7 @@ func (e *Executor) Resume(ctx context.Context, wr *run.WorkflowRun) error {
 			if err := os.MkdirAll(dir, 0755); err != nil {
 				return err
 			}
-			logPath := filepath.Join(dir, fmt.Sprintf("%s_%d.log", t.Name, attempt))
+			logPath := filepath.Join(dir, fmt.Sprintf("%s_%d.log", t.Name, tr.Attempts))
 			tr.LogPath = logPath
 			os.WriteFile(logPath, out, 0644)
 
@@ -247,43 +601,49 @@ func (e *Executor) Resume(ctx context.Context, wr *run.WorkflowRun) error {
 				code := int64(exitErr.ExitCode())
 				tr.ExitCode = sql.NullInt64{Int64: code, Valid: true}
 				tr.LastError = exitErr.Error()
-				_ = e.RunStore.UpdateTaskRun(tr)
 			} else if err != nil {
 				// Command execution error (not an exit code error)
 				tr.LastError = err.Error()
 				tr.ExitCode = sql.NullInt64{Int64: 1, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
 			} else {
 				// Success
 				tr.ExitCode = sql.NullInt64{Int64: 0, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
 			}
 
 			if err == nil {
 				now := time.Now()
 				tr.Status = run.TaskSuccess
 				tr.EndedAt = sql.NullTime{Time: now, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
+				e.saveTaskRun(tr)
 
 				logger.L().Info("task completed", zap.String("task", t.Name))
 				fmt.Println("Task completed:", t.Name)
 				break
 			}
 
-			if attempt == t.Retries+1 {
+			// A cancelled run is not retried; its error says why it was cancelled
+			if ctx.Err() != nil {
+				err = fmt.Errorf("%w: %w", err, context.Cause(ctx))
+			}
+
+			if attempt == t.Retries+1 || ctx.Err() != nil {
 				now := time.Now()
 				tr.Status = run.TaskFailed
 				tr.EndedAt = sql.NullTime{Time: now, Valid: true}
-				_ = e.RunStore.UpdateTaskRun(tr)
-
 				wr.Status = run.StatusFailed
 				wr.EndedAt = sql.NullTime{Time: now, Valid: true}
-				_ = e.RunStore.Update(wr)
+
+				// The task and the run fail together, so a crash cannot leave a failed task in a running run
+				if err := e.RunStore.FinishTask(tr, wr); err != nil {
+					logger.L().Error("failed to record task failure", zap.String("task", t.Name), zap.Error(err))
+				}
 
 				logger.L().Error("task failed => workflow failed", zap.String("task", t.Name), zap.String("workflow", d.Name), zap.Error(err))
 				return fmt.Errorf("task %s failed => workflow %s failed: %w", t.Name, d.Name, err)
 			}
 
+			e.saveTaskRun(tr)
+
 			logger.L().Debug("retrying task",
 				zap.String("workflow", d.Name),
 				zap.String("task", t.Name),
@@ -293,13 +653,38 @@ func (e *Executor) Resume(ctx context.Context, wr *run.WorkflowRun) error {
 		}
 	}
 
+	status, err := e.runStatus(wr.ID, scope)
+	if err != nil {
+		logger.L().Error("failed to load task runs", zap.String("run_id", wr.ID), zap.Error(err))
+		status = run.StatusFailed
+	}
+
 	now := time.Now()
-	wr.Status = run.StatusSuccess
+	wr.Status = status
 	wr.EndedAt = sql.NullTime{Time: now, Valid: true}
 	e.RunStore.Update(wr)
 
-	logger.L().Info("workflow resumed and completed", zap.String("workflow", d.Name))
-	fmt.Println("Workflow resumed and completed:", d.Name)
-
 	return nil
 }
+
+// runStatus derives the status of a run from the current task runs of every task in scope:
+// success if all of them succeeded, failed otherwise.
+func (e *Executor) runStatus(runID string, scope *dag.DAG) (run.WorkflowStatus, error) {
+	tasks, err := e.RunStore.LoadTaskRuns(runID)
+	if err != nil {
+		return run.StatusFailed, err
+	}
+
+	succeeded := map[string]bool{}
+	for _, tr := range tasks {
+		if tr.Status == run.TaskSuccess {
+			succeeded[tr.Name] = true
+		}
+	}
+	for name := range scope.Tasks {
+		if !succeeded[name] {
+			return run.StatusFailed, nil
+		}
+	}
+	return run.StatusSuccess, nil
+}
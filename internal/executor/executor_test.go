@@ -207,3 +207,55 @@ func TestExecutorTaskRetry(t *testing.T) {
 		t.Errorf("expected error after retries exhausted")
 	}
 }
+
+// TestExecutorMemoryRepository tests running a workflow and rerunning a failed task
+// against the in-memory repository.
+func TestExecutorMemoryRepository(t *testing.T) {
+	config.C.Paths.Logs = t.TempDir()
+
+	store := run.NewMemoryRepository()
+	executor := NewExecutor(store)
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	d := &dag.DAG{
+		Name: "memory-workflow",
+		Tasks: map[string]*dag.Task{
+			"first":  {Name: "first", Cmd: "echo first"},
+			"second": {Name: "second", Cmd: "test -f " + marker, DependsOn: []string{"first"}},
+		},
+	}
+
+	if err := executor.Run(context.Background(), d); err == nil {
+		t.Fatal("expected the run to fail before the marker exists")
+	}
+
+	runs, err := store.ListRuns(d.Name, "", 10, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one recorded run, got %d (%v)", len(runs), err)
+	}
+	if runs[0].Status != run.StatusFailed {
+		t.Errorf("expected failed run, got %s", runs[0].Status)
+	}
+
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := executor.Rerun(context.Background(), runs[0], "second", false); err != nil {
+		t.Fatalf("Rerun failed: %v", err)
+	}
+
+	wr, err := store.Load(runs[0].ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if wr.Status != run.StatusSuccess {
+		t.Errorf("expected rerun to succeed, got %s", wr.Status)
+	}
+	history, err := store.LoadTaskRunHistory(wr.ID)
+	if err != nil || len(history) != 3 {
+		t.Errorf("expected the failed attempt kept in the history, got %d task runs (%v)", len(history), err)
+	}
+	if lock, _ := store.GetLock(run.RunLockName(wr.ID)); lock != nil {
+		t.Errorf("expected locks released after the run, got %+v", lock)
+	}
+}
@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/logger"
+	"github.com/joelfokou/workflow/internal/run"
+	"go.uber.org/zap"
+)
+
+// defaultLockPollInterval is how often a queued run checks whether the workflow lock is free.
+const defaultLockPollInterval = time.Second
+
+// acquireLocks takes the locks a run holds while it executes: its own run lock, so it
+// cannot be resumed or rerun elsewhere at the same time, and the workflow lock unless the
+// workflow allows concurrent runs. With ConcurrencyQueue it waits for the workflow lock
+// until ctx is cancelled. It returns the names of the locks taken.
+func (e *Executor) acquireLocks(ctx context.Context, workflow string, policy dag.Concurrency, runID string) ([]string, error) {
+	runLock := run.RunLockName(runID)
+	if _, err := e.RunStore.AcquireLock(runLock, runID, e.lockLease()); err != nil {
+		return nil, err
+	}
+	locks := []string{runLock}
+
+	if policy == dag.ConcurrencyAllow {
+		return locks, nil
+	}
+
+	name := run.WorkflowLockName(workflow)
+	waiting := false
+	for {
+		_, err := e.RunStore.AcquireLock(name, runID, e.lockLease())
+		if err == nil {
+			break
+		}
+
+		var held *run.LockHeldError
+		if !errors.As(err, &held) {
+			e.releaseLocks(locks, runID)
+			return nil, err
+		}
+		if policy != dag.ConcurrencyQueue {
+			e.releaseLocks(locks, runID)
+			logger.L().Warn("workflow is already running", zap.String("workflow", workflow), zap.String("holder", held.Lock.RunID))
+			return nil, fmt.Errorf("workflow %s does not allow concurrent runs: %w", workflow, err)
+		}
+
+		if !waiting {
+			waiting = true
+			logger.L().Info("waiting for workflow lock", zap.String("workflow", workflow), zap.String("holder", held.Lock.RunID))
+			fmt.Printf("Waiting for run %s of workflow %s to finish...\n", held.Lock.RunID, workflow)
+		}
+
+		select {
+		case <-ctx.Done():
+			e.releaseLocks(locks, runID)
+			return nil, fmt.Errorf("cancelled while waiting for workflow %s: %w", workflow, ctx.Err())
+		case <-time.After(e.lockPollInterval()):
+		}
+	}
+
+	return append(locks, name), nil
+}
+
+// releaseLocks releases locks taken by acquireLocks.
+func (e *Executor) releaseLocks(locks []string, runID string) {
+	for _, name := range locks {
+		if err := e.RunStore.ReleaseLock(name, runID); err != nil {
+			logger.L().Warn("failed to release lock", zap.String("lock", name), zap.String("run_id", runID), zap.Error(err))
+		}
+	}
+}
+
+// lockLease returns the lease of the locks held by a run, long enough to survive a few
+// missed heartbeats.
+func (e *Executor) lockLease() time.Duration {
+	return max(run.LockLease, 3*e.heartbeatInterval())
+}
+
+// lockPollInterval returns how often a queued run retries the workflow lock.
+func (e *Executor) lockPollInterval() time.Duration {
+	if e.LockPollInterval > 0 {
+		return e.LockPollInterval
+	}
+	return defaultLockPollInterval
+}
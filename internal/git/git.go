@@ -0,0 +1,103 @@
+// Package git reads workflow files and repository state through the local git binary.
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// output executes git with args in dir and returns its raw standard output.
+func output(dir string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// ShowFile returns the contents of the file at path as it existed at revision rev.
+// The path may be absolute or relative to the working directory; it must be inside a git repository.
+func ShowFile(path, rev string) ([]byte, error) {
+	dir, err := repoDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkRev(rev); err != nil {
+		return nil, err
+	}
+	return output(dir, "show", rev+":./"+filepath.Base(path))
+}
+
+// checkRev rejects revisions git would parse as options, such as --output=<file>.
+func checkRev(rev string) error {
+	if strings.HasPrefix(rev, "-") {
+		return fmt.Errorf("invalid revision %q", rev)
+	}
+	return nil
+}
+
+// run executes git with args in dir and returns its standard output without the trailing newline.
+func run(dir string, args ...string) (string, error) {
+	out, err := output(dir, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// RevParse resolves rev to a full commit SHA in the repository containing path.
+func RevParse(path, rev string) (string, error) {
+	dir, err := repoDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkRev(rev); err != nil {
+		return "", err
+	}
+	return run(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+}
+
+// State returns the HEAD commit of the repository containing path and whether its working tree has uncommitted changes.
+func State(path string) (string, bool, error) {
+	dir, err := repoDir(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	commit, err := run(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+
+	status, err := run(dir, "status", "--porcelain")
+	if err != nil {
+		return "", false, err
+	}
+
+	return commit, status != "", nil
+}
+
+// repoDir returns the directory to run git in for a file path.
+func repoDir(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(absPath), nil
+}
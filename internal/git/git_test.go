@@ -0,0 +1,118 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// initRepo creates a git repository in a temporary directory, skipping the test if git is unavailable.
+func initRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+	} {
+		if _, err := output(dir, args...); err != nil {
+			t.Fatalf("failed to initialise repository: %v", err)
+		}
+	}
+	return dir
+}
+
+// commitFile writes content to name and commits it.
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	for _, args := range [][]string{{"add", name}, {"commit", "-q", "-m", "update " + name}} {
+		if _, err := output(dir, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+}
+
+// TestShowFile tests reading a file as it existed at an earlier revision.
+func TestShowFile(t *testing.T) {
+	dir := initRepo(t)
+	commitFile(t, dir, "wf.toml", "first\n")
+	commitFile(t, dir, "wf.toml", "second\n")
+
+	data, err := ShowFile(filepath.Join(dir, "wf.toml"), "HEAD~1")
+	if err != nil {
+		t.Fatalf("ShowFile failed: %v", err)
+	}
+	if string(data) != "first\n" {
+		t.Errorf("expected 'first', got %q", string(data))
+	}
+
+	if _, err := ShowFile(filepath.Join(dir, "missing.toml"), "HEAD"); err == nil {
+		t.Error("expected error for file missing at revision")
+	}
+}
+
+// TestRevRejectsOptions tests that a revision cannot be passed to git as an option.
+func TestRevRejectsOptions(t *testing.T) {
+	dir := initRepo(t)
+	commitFile(t, dir, "wf.toml", "first\n")
+	path := filepath.Join(dir, "wf.toml")
+	// git show appends :./wf.toml to the revision, so --output would write to out:./wf.toml.
+	out := filepath.Join(t.TempDir(), "out")
+	if err := os.Mkdir(out+":.", 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if _, err := RevParse(path, "--output="+out); err == nil {
+		t.Error("expected RevParse to reject a revision starting with -")
+	}
+	if _, err := ShowFile(path, "--output="+out); err == nil {
+		t.Error("expected ShowFile to reject a revision starting with -")
+	}
+	if _, err := os.Stat(out + ":./wf.toml"); err == nil {
+		t.Error("expected git not to write the --output file")
+	}
+}
+
+// TestRevParseAndState tests resolving revisions and detecting uncommitted changes.
+func TestRevParseAndState(t *testing.T) {
+	dir := initRepo(t)
+	commitFile(t, dir, "wf.toml", "first\n")
+	path := filepath.Join(dir, "wf.toml")
+
+	sha, err := RevParse(path, "HEAD")
+	if err != nil {
+		t.Fatalf("RevParse failed: %v", err)
+	}
+	if len(sha) != 40 {
+		t.Errorf("expected full SHA, got %q", sha)
+	}
+
+	if _, err := RevParse(path, "no-such-rev"); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+
+	commit, dirty, err := State(path)
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	if commit != sha || dirty {
+		t.Errorf("expected clean tree at %s, got %s dirty=%v", sha, commit, dirty)
+	}
+
+	if err := os.WriteFile(path, []byte("changed\n"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	if _, dirty, _ := State(path); !dirty {
+		t.Error("expected dirty working tree")
+	}
+}
@@ -0,0 +1,97 @@
+// Package lint performs static checks on workflow definitions for patterns that are
+// valid but likely to cause problems, such as redundant edges or unsafe retries.
+package lint
+
+import (
+	"sort"
+
+	"github.com/joelfokou/workflow/internal/dag"
+)
+
+// Severity ranks how serious a finding is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// rank orders severities from least to most serious.
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeast reports whether s is as serious as other.
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// Finding is a single problem reported by a rule.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Workflow string   `json:"workflow"`
+	Task     string   `json:"task,omitempty"`
+	Message  string   `json:"message"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+}
+
+// Rule is a named check applied to every workflow.
+type Rule struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+
+	check func(d *dag.DAG) []Finding
+}
+
+// Rules returns every lint rule in ID order.
+func Rules() []Rule {
+	out := make([]Rule, len(registry))
+	copy(out, registry)
+	return out
+}
+
+// Lint runs every rule against d and returns the findings that are not suppressed by
+// "# wf:ignore" comments in src. src is the raw definition the DAG was loaded from and
+// may be nil, in which case findings carry no line numbers and nothing is suppressed.
+func Lint(d *dag.DAG, src []byte) []Finding {
+	index := indexSource(src)
+
+	var findings []Finding
+	for _, rule := range registry {
+		for _, f := range rule.check(d) {
+			f.Rule = rule.ID
+			f.Severity = rule.Severity
+			f.Workflow = d.QualifiedName()
+			if d.Source != "" && d.Source != dag.StdinSource {
+				f.File = d.Source
+			}
+
+			if index.ignored(rule.ID, f.Task) {
+				continue
+			}
+			f.Line = index.line(f.Task)
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+
+	return findings
+}
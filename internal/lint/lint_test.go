@@ -0,0 +1,235 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/joelfokou/workflow/internal/dag"
+	"github.com/joelfokou/workflow/internal/logger"
+)
+
+func init() {
+	logger.Init(logger.Config{
+		Level:  "info",
+		Format: "console",
+	})
+}
+
+// load parses a definition for linting.
+func load(t *testing.T, src string, format dag.Format) *dag.DAG {
+	t.Helper()
+	d, err := dag.LoadBytes([]byte(src), format, "/work/"+string(format))
+	if err != nil {
+		t.Fatalf("LoadBytes failed: %v", err)
+	}
+	return d
+}
+
+// rulesOf returns "RULE:task" for each finding.
+func rulesOf(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.Rule+":"+f.Task)
+	}
+	return out
+}
+
+// TestLintRules tests that each rule reports the expected tasks.
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "redundant edge",
+			src: `name = "w"
+[tasks.a]
+cmd = "echo a"
+[tasks.b]
+cmd = "echo b"
+depends_on = ["a"]
+[tasks.c]
+cmd = "echo c"
+depends_on = ["a", "b"]
+`,
+			want: []string{"WF001:c"},
+		},
+		{
+			name: "shared tmp path",
+			src: `name = "w"
+[tasks.a]
+cmd = "sort data > /tmp/sorted.txt"
+[tasks.b]
+cmd = "d=$(mktemp -d) && echo $d"
+`,
+			want: []string{"WF002:a"},
+		},
+		{
+			name: "retry non-idempotent",
+			src: `name = "w"
+[tasks.a]
+cmd = "echo row >> out.csv"
+retries = 3
+[tasks.b]
+cmd = "echo row >> out.csv"
+[tasks.c]
+cmd = "mkdir -p build"
+retries = 1
+[tasks.d]
+cmd = "curl -X POST https://example.com/hook"
+retries = 1
+`,
+			want: []string{"WF003:a", "WF003:d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rulesOf(Lint(load(t, tt.src, dag.FormatTOML), nil))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("expected findings %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestLintLongChain tests that only chains longer than the limit are reported, once, at their end.
+func TestLintLongChain(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("name = \"w\"\n")
+	for i := 0; i <= maxChainLength; i++ {
+		fmt.Fprintf(&b, "[tasks.t%02d]\ncmd = \"echo\"\n", i)
+		if i > 0 {
+			fmt.Fprintf(&b, "depends_on = [\"t%02d\"]\n", i-1)
+		}
+	}
+
+	findings := Lint(load(t, b.String(), dag.FormatTOML), nil)
+	got := rulesOf(findings)
+	want := fmt.Sprintf("WF004:t%02d", maxChainLength)
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("expected [%s], got %v", want, got)
+	}
+	if findings[0].Severity != SeverityInfo {
+		t.Errorf("expected severity info, got %s", findings[0].Severity)
+	}
+
+	// Removing the last task brings the chain within the limit
+	d := load(t, b.String(), dag.FormatTOML)
+	delete(d.Tasks, want[len("WF004:"):])
+	if got := Lint(d, nil); len(got) != 0 {
+		t.Errorf("expected no findings, got %v", rulesOf(got))
+	}
+}
+
+// TestLintLinesAndIgnores tests line numbers and wf:ignore comments in TOML and YAML.
+func TestLintLinesAndIgnores(t *testing.T) {
+	toml := `name = "w"
+
+[tasks.fetch]
+cmd = "curl -o /tmp/data.json https://example.com"
+
+# wf:ignore WF002
+[tasks.parse]
+cmd = "cat /tmp/data.json"
+depends_on = ["fetch"]
+
+[tasks.load]
+cmd = "cp /tmp/data.json out" # wf:ignore
+depends_on = ["fetch", "parse"]
+`
+	findings := Lint(load(t, toml, dag.FormatTOML), []byte(toml))
+	if got := rulesOf(findings); strings.Join(got, " ") != "WF002:fetch" {
+		t.Fatalf("expected [WF002:fetch], got %v", got)
+	}
+	if findings[0].Line != 3 {
+		t.Errorf("expected line 3, got %d", findings[0].Line)
+	}
+	if findings[0].File != "/work/toml" {
+		t.Errorf("expected file /work/toml, got %q", findings[0].File)
+	}
+
+	yaml := `# wf:ignore WF002
+name: w
+tasks:
+  fetch:
+    cmd: curl -o /tmp/data.json https://example.com
+    retries: 2
+  parse:
+    cmd: echo >> log
+    retries: 1
+  load:
+    # wf:ignore WF001
+    cmd: echo
+    depends_on: [fetch, parse]
+    retries: 1
+  done:
+    cmd: echo
+    depends_on: [fetch, parse]
+`
+	y := load(t, yaml, dag.FormatYAML)
+	y.Tasks["done"].DependsOn = []string{"load", "fetch"}
+	findings = Lint(y, []byte(yaml))
+	got := rulesOf(findings)
+	if strings.Join(got, " ") != "WF003:parse WF001:done" {
+		t.Fatalf("expected [WF003:parse WF001:done], got %v", got)
+	}
+	if findings[0].Line != 7 || findings[1].Line != 15 {
+		t.Errorf("expected lines 7 and 15, got %d and %d", findings[0].Line, findings[1].Line)
+	}
+}
+
+// TestSARIF tests the structure of SARIF output.
+func TestSARIF(t *testing.T) {
+	findings := []Finding{{
+		Rule:     "WF002",
+		Severity: SeverityWarning,
+		Workflow: "w",
+		Task:     "a",
+		Message:  "command uses shared path /tmp/x",
+		File:     "/work/w.toml",
+		Line:     4,
+	}, {
+		Rule:     "WF004",
+		Severity: SeverityInfo,
+		Workflow: "w",
+		Task:     "z",
+		Message:  "long chain",
+	}}
+
+	data, err := SARIF(findings, "1.2.3")
+	if err != nil {
+		t.Fatalf("SARIF failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected one SARIF 2.1.0 run, got version %q with %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Version != "1.2.3" || len(run.Tool.Driver.Rules) != len(Rules()) {
+		t.Errorf("unexpected driver: %+v", run.Tool.Driver)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(run.Results))
+	}
+
+	first := run.Results[0]
+	if first.Level != "warning" || first.RuleID != "WF002" || run.Tool.Driver.Rules[first.RuleIndex].ID != "WF002" {
+		t.Errorf("unexpected first result: %+v", first)
+	}
+	if len(first.Locations) != 1 || first.Locations[0].PhysicalLocation.Region.StartLine != 4 {
+		t.Errorf("expected location at line 4, got %+v", first.Locations)
+	}
+
+	if second := run.Results[1]; second.Level != "note" || len(second.Locations) != 0 {
+		t.Errorf("expected a note without location, got %+v", second)
+	}
+}
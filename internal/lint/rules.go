@@ -0,0 +1,196 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joelfokou/workflow/internal/dag"
+)
+
+// maxChainLength is the longest dependency chain, in tasks, before WF004 reports it.
+const maxChainLength = 10
+
+// registry lists every lint rule, in ID order.
+var registry = []Rule{
+	{
+		ID:          "WF001",
+		Name:        "redundant-edge",
+		Severity:    SeverityWarning,
+		Description: "A dependency that is already implied transitively through another dependency",
+		check:       checkRedundantEdges,
+	},
+	{
+		ID:          "WF002",
+		Name:        "shared-tmp-path",
+		Severity:    SeverityWarning,
+		Description: "A command using a fixed path under /tmp, which concurrent runs share",
+		check:       checkSharedTmpPaths,
+	},
+	{
+		ID:          "WF003",
+		Name:        "retry-non-idempotent",
+		Severity:    SeverityWarning,
+		Description: "Retries on a command that is unsafe to run more than once",
+		check:       checkNonIdempotentRetries,
+	},
+	{
+		ID:          "WF004",
+		Name:        "long-chain",
+		Severity:    SeverityInfo,
+		Description: fmt.Sprintf("A dependency chain longer than %d tasks", maxChainLength),
+		check:       checkLongChains,
+	},
+}
+
+// checkRedundantEdges reports dependencies that are also reachable through another dependency.
+func checkRedundantEdges(d *dag.DAG) []Finding {
+	var findings []Finding
+
+	for _, t := range sortedTasks(d) {
+		for _, dep := range t.DependsOn {
+			for _, other := range t.DependsOn {
+				if other == dep {
+					continue
+				}
+				ancestors, err := d.Ancestors(other)
+				if err != nil || !contains(ancestors, dep) {
+					continue
+				}
+
+				findings = append(findings, Finding{
+					Task:    t.Name,
+					Message: fmt.Sprintf("dependency on %s is redundant: it is already implied by %s", dep, other),
+				})
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// tmpPathPattern matches absolute paths under /tmp.
+var tmpPathPattern = regexp.MustCompile(`(?:^|[\s'"=:(<>])(/tmp/[^\s'";|&)<>]*)`)
+
+// checkSharedTmpPaths reports commands that use fixed paths under /tmp.
+func checkSharedTmpPaths(d *dag.DAG) []Finding {
+	var findings []Finding
+
+	for _, t := range sortedTasks(d) {
+		m := tmpPathPattern.FindStringSubmatch(t.Cmd)
+		if m == nil {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Task:    t.Name,
+			Message: fmt.Sprintf("command uses shared path %s; concurrent runs can collide, use mktemp or a run-specific directory", m[1]),
+		})
+	}
+
+	return findings
+}
+
+// nonIdempotentPatterns match commands with side effects that should not be repeated blindly.
+var nonIdempotentPatterns = []struct {
+	pattern *regexp.Regexp
+	reason  string
+}{
+	{regexp.MustCompile(`>>`), "appends to a file"},
+	{regexp.MustCompile(`\bgit\s+(push|commit|tag)\b`), "changes a git repository"},
+	{regexp.MustCompile(`\bcurl\b.*(-X\s*(POST|PUT|PATCH|DELETE)|--data\b|\s-d\s)`), "sends a mutating HTTP request"},
+	{regexp.MustCompile(`(?i)\b(insert\s+into|delete\s+from|update\s+\w+\s+set)\b`), "modifies database rows"},
+	{regexp.MustCompile(`\b(docker|npm|cargo|twine)\s+(push|publish|upload)\b`), "publishes an artefact"},
+	{regexp.MustCompile(`\bkubectl\s+create\b`), "creates cluster resources"},
+	{regexp.MustCompile(`\bmkdir\s+[^-\s]`), "creates a directory without -p"},
+}
+
+// checkNonIdempotentRetries reports retried tasks whose commands are unsafe to repeat.
+func checkNonIdempotentRetries(d *dag.DAG) []Finding {
+	var findings []Finding
+
+	for _, t := range sortedTasks(d) {
+		if t.Retries == 0 {
+			continue
+		}
+
+		for _, p := range nonIdempotentPatterns {
+			if p.pattern.MatchString(t.Cmd) {
+				findings = append(findings, Finding{
+					Task:    t.Name,
+					Message: fmt.Sprintf("task retries %d time(s) but its command %s; a partial failure may be repeated", t.Retries, p.reason),
+				})
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// checkLongChains reports the end of every dependency chain longer than maxChainLength.
+func checkLongChains(d *dag.DAG) []Finding {
+	order, err := d.TopologicalSort()
+	if err != nil {
+		return nil
+	}
+
+	// Longest chain ending at each task, and the predecessor on that chain
+	length := make(map[string]int, len(order))
+	prev := make(map[string]string, len(order))
+	hasChildren := make(map[string]bool, len(order))
+
+	for _, t := range order {
+		length[t.Name] = 1
+		for _, dep := range t.DependsOn {
+			hasChildren[dep] = true
+			if length[dep]+1 > length[t.Name] || (length[dep]+1 == length[t.Name] && dep < prev[t.Name]) {
+				length[t.Name] = length[dep] + 1
+				prev[t.Name] = dep
+			}
+		}
+	}
+
+	var findings []Finding
+	for _, t := range sortedTasks(d) {
+		if hasChildren[t.Name] || length[t.Name] <= maxChainLength {
+			continue
+		}
+
+		chain := []string{t.Name}
+		for n := prev[t.Name]; n != ""; n = prev[n] {
+			chain = append([]string{n}, chain...)
+		}
+
+		findings = append(findings, Finding{
+			Task:    t.Name,
+			Message: fmt.Sprintf("dependency chain of %d tasks (%s); consider whether every step must run sequentially", len(chain), strings.Join(chain, " -> ")),
+		})
+	}
+
+	return findings
+}
+
+// sortedTasks returns the tasks of d sorted by name.
+func sortedTasks(d *dag.DAG) []*dag.Task {
+	tasks := make([]*dag.Task, 0, len(d.Tasks))
+	for _, t := range d.Tasks {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Name < tasks[j].Name
+	})
+	return tasks
+}
+
+// contains reports whether s is in list.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// ignorePattern matches suppression comments such as "# wf:ignore WF001,WF003".
+	// Without rule IDs, every rule is suppressed.
+	ignorePattern = regexp.MustCompile(`#\s*wf:ignore\b([A-Za-z0-9,\s]*)`)
+
+	// tomlTaskHeader matches a [tasks.name] table header.
+	tomlTaskHeader = regexp.MustCompile(`^\s*\[\s*tasks\.("?)([A-Za-z0-9_-]+)("?)\s*\]`)
+
+	// tomlHeader matches any table header.
+	tomlHeader = regexp.MustCompile(`^\s*\[`)
+
+	// yamlKey matches a mapping key and captures its indentation.
+	yamlKey = regexp.MustCompile(`^(\s*)([A-Za-z0-9_-]+)\s*:`)
+)
+
+// ruleSet is a set of suppressed rule IDs. An entry for "*" suppresses every rule.
+type ruleSet map[string]bool
+
+func (s ruleSet) has(rule string) bool {
+	return s["*"] || s[rule]
+}
+
+// sourceIndex records where tasks are declared in a definition and which rules are
+// suppressed for each task, or for the whole file.
+type sourceIndex struct {
+	lines   map[string]int
+	file    ruleSet
+	ignores map[string]ruleSet
+}
+
+// indexSource scans a TOML or YAML definition for task declarations and wf:ignore comments.
+// A comment inside a task applies to that task; a comment on the lines directly above a
+// task applies to the task below it; a comment before any task or table applies to the file.
+// JSON has no comments, so for JSON only nothing is suppressed and no lines are recorded.
+func indexSource(src []byte) *sourceIndex {
+	idx := &sourceIndex{
+		lines:   map[string]int{},
+		file:    ruleSet{},
+		ignores: map[string]ruleSet{},
+	}
+	if len(src) == 0 {
+		return idx
+	}
+
+	var (
+		current    string // task whose body is being read
+		inOther    bool   // inside a table or mapping that is not a task
+		pending    []string
+		yamlIndent = -1 // indentation of task keys under "tasks:", -1 outside
+	)
+
+	apply := func(rules []string) {
+		set := idx.file
+		if current != "" {
+			if idx.ignores[current] == nil {
+				idx.ignores[current] = ruleSet{}
+			}
+			set = idx.ignores[current]
+		} else if inOther {
+			return
+		}
+		for _, r := range rules {
+			set[r] = true
+		}
+	}
+
+	for i, line := range strings.Split(string(src), "\n") {
+		trimmed := strings.TrimSpace(line)
+		rules, hasIgnore := parseIgnore(line)
+
+		// Comment-only lines are held until the next declaration is known
+		if strings.HasPrefix(trimmed, "#") {
+			if hasIgnore {
+				pending = append(pending, rules...)
+			}
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+
+		task, kind := declaration(line, &yamlIndent)
+		switch kind {
+		case declTask:
+			current, inOther = task, false
+			if _, ok := idx.lines[task]; !ok {
+				idx.lines[task] = i + 1
+			}
+		case declTable:
+			current, inOther = "", true
+		case declTopLevel:
+			current, inOther = "", false
+		}
+
+		if len(pending) > 0 {
+			apply(pending)
+			pending = nil
+		}
+		if hasIgnore {
+			apply(rules)
+		}
+	}
+
+	return idx
+}
+
+// declKind classifies a line of a definition.
+type declKind int
+
+const (
+	declNone     declKind = iota // continues the current declaration
+	declTask                     // starts a task
+	declTable                    // starts a table or mapping that is not a task
+	declTopLevel                 // a top-level YAML key such as "name:"
+)
+
+// declaration classifies a line and returns the task name for declTask.
+// yamlIndent tracks the indentation of task keys under "tasks:".
+func declaration(line string, yamlIndent *int) (string, declKind) {
+	if m := tomlTaskHeader.FindStringSubmatch(line); m != nil {
+		return m[2], declTask
+	}
+	if tomlHeader.MatchString(line) {
+		return "", declTable
+	}
+
+	m := yamlKey.FindStringSubmatch(line)
+	if m == nil {
+		return "", declNone
+	}
+
+	indent := len(m[1])
+	switch {
+	case indent == 0 && m[2] == "tasks":
+		*yamlIndent = 0
+		return "", declTable
+	case indent == 0 && m[2] == "groups":
+		*yamlIndent = -1
+		return "", declTable
+	case indent == 0:
+		*yamlIndent = -1
+		return "", declTopLevel
+	case *yamlIndent == 0:
+		// The first nested key fixes the indentation of task names
+		*yamlIndent = indent
+		return m[2], declTask
+	case *yamlIndent > 0 && indent == *yamlIndent:
+		return m[2], declTask
+	}
+
+	return "", declNone
+}
+
+// parseIgnore extracts the rule IDs of a wf:ignore comment on a line.
+func parseIgnore(line string) ([]string, bool) {
+	m := ignorePattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	rules := strings.FieldsFunc(m[1], func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(rules) == 0 {
+		return []string{"*"}, true
+	}
+	for i, r := range rules {
+		rules[i] = strings.ToUpper(r)
+	}
+	return rules, true
+}
+
+// ignored reports whether rule is suppressed for task, or for the whole file.
+func (idx *sourceIndex) ignored(rule, task string) bool {
+	if idx.file.has(rule) {
+		return true
+	}
+	return task != "" && idx.ignores[task].has(rule)
+}
+
+// line returns the line a task is declared on, or 0 if unknown.
+func (idx *sourceIndex) line(task string) int {
+	return idx.lines[task]
+}
@@ -0,0 +1,380 @@
+package run
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// ArchiveFormat identifies run archives written by Export.
+	ArchiveFormat = "wf-runs"
+	// ArchiveVersion is the version of the archive layout written by Export. Import reads
+	// archives up to this version.
+	ArchiveVersion = 1
+
+	archiveManifest = "manifest.json"
+)
+
+// An archive is a gzipped tar file. Its first entry is manifest.json; each run follows as
+// its log files, runs/<id>/logs/<file>, then runs/<id>/run.json holding the run and every
+// task run, including archived attempts. Logs come first so that a run is only recorded
+// once its logs are in place.
+
+// ArchiveManifest describes a run archive.
+type ArchiveManifest struct {
+	Format     string     `json:"format"`
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Host       string     `json:"host"` // Host the archive was exported from
+	Since      *time.Time `json:"since,omitempty"`
+	Workflow   string     `json:"workflow,omitempty"`
+	Runs       int        `json:"runs"`
+}
+
+// archivedRun is the JSON form of a run in an archive.
+type archivedRun struct {
+	Run   runRecord    `json:"run"`
+	Tasks []taskRecord `json:"tasks"`
+}
+
+// ExportOptions selects the runs to export.
+type ExportOptions struct {
+	Since    time.Time // Only runs started at or after this time (zero = any)
+	Workflow string    // Only runs of this workflow (empty = any)
+}
+
+// ExportResult summarises an Export.
+type ExportResult struct {
+	Manifest    ArchiveManifest
+	Runs        []*WorkflowRun
+	TaskRuns    int
+	Logs        int
+	MissingLogs int // Log files recorded for a task run but no longer on disk
+}
+
+// Export writes the selected runs, oldest first, with their task runs, definition
+// snapshots and log files to w as a run archive. Running runs are not exported.
+func Export(repo Repository, w io.Writer, opts ExportOptions) (*ExportResult, error) {
+	runs, err := repo.ListRuns(opts.Workflow, "", -1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+
+	result := &ExportResult{}
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		if r.Status == StatusRunning || r.StartedAt.Before(opts.Since) {
+			continue
+		}
+		result.Runs = append(result.Runs, r)
+	}
+
+	result.Manifest = ArchiveManifest{
+		Format:     ArchiveFormat,
+		Version:    ArchiveVersion,
+		ExportedAt: time.Now(),
+		Host:       hostname(),
+		Workflow:   opts.Workflow,
+		Runs:       len(result.Runs),
+	}
+	if !opts.Since.IsZero() {
+		result.Manifest.Since = &opts.Since
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeArchiveJSON(tw, archiveManifest, result.Manifest, result.Manifest.ExportedAt); err != nil {
+		return nil, err
+	}
+
+	for _, r := range result.Runs {
+		tasks, err := repo.LoadTaskRunHistory(r.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load task runs of %s: %w", r.ID, err)
+		}
+
+		entry := archivedRun{Run: newRunRecord(r), Tasks: []taskRecord{}}
+		written := map[string]bool{}
+		for i := range tasks {
+			entry.Tasks = append(entry.Tasks, newTaskRecord(&tasks[i]))
+
+			for _, logPath := range attemptLogs(&tasks[i]) {
+				if written[filepath.Base(logPath)] {
+					continue
+				}
+				ok, err := writeArchiveFile(tw, path.Join("runs", r.ID, "logs", filepath.Base(logPath)), logPath)
+				if err != nil {
+					return nil, fmt.Errorf("failed to add log %s: %w", logPath, err)
+				}
+				if !ok {
+					result.MissingLogs++
+					continue
+				}
+				written[filepath.Base(logPath)] = true
+				result.Logs++
+			}
+		}
+		result.TaskRuns += len(tasks)
+
+		if err := writeArchiveJSON(tw, path.Join("runs", r.ID, "run.json"), entry, r.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// attemptLogs returns the log files of every attempt of a task run. The executor writes
+// <task>_<n>.log per attempt next to the log of the last one, which LogPath records.
+func attemptLogs(tr *TaskRun) []string {
+	if tr.LogPath == "" {
+		return nil
+	}
+	dir := filepath.Dir(tr.LogPath)
+	logs := make([]string, 0, tr.Attempts)
+	for n := 1; n <= tr.Attempts; n++ {
+		logs = append(logs, filepath.Join(dir, fmt.Sprintf("%s_%d.log", tr.Name, n)))
+	}
+	if !slices.Contains(logs, tr.LogPath) {
+		logs = append(logs, tr.LogPath)
+	}
+	return logs
+}
+
+// writeArchiveJSON adds a JSON document to an archive.
+func writeArchiveJSON(tw *tar.Writer, name string, v any, modTime time.Time) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// writeArchiveFile adds a file to an archive, reporting false if it does not exist.
+func writeArchiveFile(tw *tar.Writer, name, src string) (bool, error) {
+	f, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return false, err
+	}
+	_, err = io.Copy(tw, f)
+	return err == nil, err
+}
+
+// ImportResult summarises an Import.
+type ImportResult struct {
+	Manifest ArchiveManifest
+	Imported []*WorkflowRun
+	Skipped  []*WorkflowRun // Already present in the repository
+	Logs     int
+}
+
+// Import merges the runs of an archive into repo, writing their logs under logsDir/<run-id>.
+// Runs are matched by ID, so importing an archive again changes nothing. Imported runs keep
+// their timestamps and are marked with the host they were exported from, unless they
+// already record an origin.
+func Import(repo Repository, r io.Reader, logsDir string) (*ImportResult, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a run archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	result := &ImportResult{}
+	if err := readManifest(tr, &result.Manifest); err != nil {
+		return nil, err
+	}
+
+	// exists caches whether each run of the archive was already in the repository
+	// before the import started.
+	exists := map[string]bool{}
+	present := func(id string) (bool, error) {
+		if known, ok := exists[id]; ok {
+			return known, nil
+		}
+		_, err := repo.Load(id)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		exists[id] = err == nil
+		return exists[id], nil
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		id, rest, ok := parseArchiveName(hdr.Name)
+		if !ok {
+			return result, fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+
+		found, err := present(id)
+		if err != nil {
+			return result, err
+		}
+
+		if rest == "run.json" {
+			wr, tasks, err := readArchivedRun(tr, id, result.Manifest.Host, logsDir)
+			if err != nil {
+				return result, fmt.Errorf("failed to read run %s: %w", id, err)
+			}
+			if found {
+				result.Skipped = append(result.Skipped, wr)
+				continue
+			}
+			imported, err := repo.ImportRun(wr, tasks)
+			if err != nil {
+				return result, fmt.Errorf("failed to import run %s: %w", id, err)
+			}
+			if imported {
+				result.Imported = append(result.Imported, wr)
+			} else {
+				result.Skipped = append(result.Skipped, wr)
+			}
+			continue
+		}
+
+		// A log file of the run
+		if found || logsDir == "" {
+			continue
+		}
+		if err := extractArchiveFile(tr, filepath.Join(logsDir, id, strings.TrimPrefix(rest, "logs/")), hdr.ModTime); err != nil {
+			return result, fmt.Errorf("failed to write log of run %s: %w", id, err)
+		}
+		result.Logs++
+	}
+
+	return result, nil
+}
+
+// readManifest reads and checks the manifest, which must be the first entry.
+func readManifest(tr *tar.Reader, m *ArchiveManifest) error {
+	hdr, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("not a run archive: %w", err)
+	}
+	if hdr.Name != archiveManifest {
+		return fmt.Errorf("not a run archive: first entry is %q, expected %s", hdr.Name, archiveManifest)
+	}
+	if err := json.NewDecoder(tr).Decode(m); err != nil {
+		return fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	if m.Format != ArchiveFormat {
+		return fmt.Errorf("not a run archive: format %q", m.Format)
+	}
+	if m.Version > ArchiveVersion {
+		return fmt.Errorf("archive version %d is newer than the latest version %d supported by this wf; upgrade wf", m.Version, ArchiveVersion)
+	}
+	return nil
+}
+
+// parseArchiveName splits an entry name "runs/<id>/<rest>" where rest is run.json or
+// logs/<file>, rejecting names that would escape the run's directory.
+func parseArchiveName(name string) (id, rest string, ok bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 || parts[0] != "runs" || !safeName(parts[1]) {
+		return "", "", false
+	}
+	id, rest = parts[1], strings.Join(parts[2:], "/")
+
+	if rest == "run.json" {
+		return id, rest, true
+	}
+	if len(parts) == 4 && parts[2] == "logs" && safeName(parts[3]) {
+		return id, rest, true
+	}
+	return "", "", false
+}
+
+// safeName reports whether s can be used as a single path element.
+func safeName(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
+
+// readArchivedRun decodes a run.json entry into a run ready to import, pointing its task
+// logs at logsDir/<run-id>.
+func readArchivedRun(r io.Reader, id, origin, logsDir string) (*WorkflowRun, []TaskRun, error) {
+	var entry archivedRun
+	if err := json.NewDecoder(r).Decode(&entry); err != nil {
+		return nil, nil, err
+	}
+	if entry.Run.ID != id {
+		return nil, nil, fmt.Errorf("entry holds run %q", entry.Run.ID)
+	}
+
+	wr := entry.Run.run()
+	if !wr.Origin.Valid && origin != "" {
+		wr.Origin = sql.NullString{String: origin, Valid: true}
+	}
+
+	tasks := make([]TaskRun, 0, len(entry.Tasks))
+	for _, t := range entry.Tasks {
+		task := t.task()
+		task.RunID = id
+		if task.LogPath != "" && logsDir != "" {
+			task.LogPath = filepath.Join(logsDir, id, filepath.Base(task.LogPath))
+		}
+		tasks = append(tasks, *task)
+	}
+	return wr, tasks, nil
+}
+
+// extractArchiveFile writes the current entry to dest, keeping its modification time.
+func extractArchiveFile(r io.Reader, dest string, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, modTime, modTime)
+}
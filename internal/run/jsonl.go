@@ -0,0 +1,179 @@
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JSONLRepository records runs in an append-only JSON-lines file, for environments where
+// SQLite databases are unwanted. Each line holds every change made by one operation, so
+// operations are atomic; the file is replayed into memory when opened and whenever
+// another process has appended to it. Writers take a lock file next to the journal.
+type JSONLRepository struct {
+	stateRepository
+}
+
+// OpenJSONL opens the JSON-lines repository at path, creating the file if needed.
+func OpenJSONL(path string) (*JSONLRepository, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &journal{path: path, file: file}
+	repo := &JSONLRepository{stateRepository{state: newMemState(), journal: j}}
+
+	if err := repo.view(func(*memState) error { return nil }); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return repo, nil
+}
+
+// Path returns the path of the JSON-lines file.
+func (r *JSONLRepository) Path() string {
+	return r.journal.path
+}
+
+// Close closes the JSON-lines file.
+func (r *JSONLRepository) Close() error {
+	return r.journal.file.Close()
+}
+
+const (
+	// journalLockTimeout is how long a writer waits for the journal lock file.
+	journalLockTimeout = 10 * time.Second
+	// journalLockStale is the age after which a lock file is assumed to be left by a
+	// crashed writer. Writers hold the lock only while appending one line.
+	journalLockStale = 30 * time.Second
+)
+
+// journal is the file behind a JSONLRepository.
+type journal struct {
+	path   string
+	file   *os.File
+	offset int64 // Bytes replayed so far; always the end of a complete line
+}
+
+// replay applies the complete lines appended since the last replay. A line left
+// incomplete by a writer that crashed is skipped once it has been terminated.
+func (j *journal) replay(s *memState) error {
+	info, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= j.offset {
+		return nil
+	}
+
+	buf := make([]byte, info.Size()-j.offset)
+	if _, err := j.file.ReadAt(buf, j.offset); err != nil {
+		return err
+	}
+
+	end := bytes.LastIndexByte(buf, '\n')
+	if end < 0 {
+		return nil
+	}
+
+	for _, line := range bytes.Split(buf[:end], []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		s.apply(entry.change())
+	}
+
+	j.offset += int64(end + 1)
+	return nil
+}
+
+// append writes a change as one line. It must be called with the lock held, after a
+// replay, so that the line lands after every line the state already reflects.
+func (j *journal) append(c *change) error {
+	if len(c.Runs) == 0 && len(c.Tasks) == 0 && len(c.Locks) == 0 && len(c.Released) == 0 && len(c.Deleted) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(newJournalEntry(c))
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	info, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > j.offset {
+		// Terminate the incomplete line of a crashed writer rather than extending it.
+		data = append([]byte("\n"), data...)
+	}
+
+	if _, err := j.file.Write(data); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+
+	j.offset = info.Size() + int64(len(data))
+	return nil
+}
+
+// lock takes the lock file serialising writers across processes, returning a function
+// that releases it. A lock file older than journalLockStale is removed.
+func (j *journal) lock() (func(), error) {
+	return lockFile(j.path+".lock", journalLockTimeout, journalLockStale)
+}
+
+// journalEntry is the JSON form of a change.
+type journalEntry struct {
+	Time     time.Time    `json:"time"`
+	Runs     []runRecord  `json:"runs,omitempty"`
+	Tasks    []taskRecord `json:"tasks,omitempty"`
+	Locks    []Lock       `json:"locks,omitempty"`
+	Released []string     `json:"released,omitempty"`
+	Deleted  []string     `json:"deleted,omitempty"`
+}
+
+func newJournalEntry(c *change) journalEntry {
+	entry := journalEntry{Time: time.Now(), Released: c.Released, Deleted: c.Deleted}
+	for _, r := range c.Runs {
+		entry.Runs = append(entry.Runs, newRunRecord(r))
+	}
+	for _, t := range c.Tasks {
+		entry.Tasks = append(entry.Tasks, newTaskRecord(t))
+	}
+	for _, l := range c.Locks {
+		entry.Locks = append(entry.Locks, *l)
+	}
+	return entry
+}
+
+func (e journalEntry) change() *change {
+	c := &change{Released: e.Released, Deleted: e.Deleted}
+	for _, r := range e.Runs {
+		c.Runs = append(c.Runs, r.run())
+	}
+	for _, t := range e.Tasks {
+		c.Tasks = append(c.Tasks, t.task())
+	}
+	for i := range e.Locks {
+		c.Locks = append(c.Locks, &e.Locks[i])
+	}
+	return c
+}
@@ -0,0 +1,160 @@
+package run
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LockLease is how long a lock stays valid without being renewed. Executors renew their
+// locks with every heartbeat, so a lock outlives its holder by at most one lease.
+const LockLease = 30 * time.Second
+
+// Lock is a named lease held by a workflow run, stored alongside the runs so that it is
+// shared by every wf process using the same repository.
+type Lock struct {
+	Name       string    `json:"name"`
+	RunID      string    `json:"run_id"`
+	PID        int64     `json:"pid,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// WorkflowLockName returns the name of the lock serialising runs of a workflow.
+func WorkflowLockName(workflow string) string {
+	return "workflow:" + workflow
+}
+
+// RunLockName returns the name of the lock held while a run is executed, resumed or rerun.
+func RunLockName(runID string) string {
+	return "run:" + runID
+}
+
+// Stale reports whether the lock can be taken over: its lease expired, or its holder was
+// on this host and no longer exists.
+func (l *Lock) Stale(now time.Time) bool {
+	if now.After(l.ExpiresAt) {
+		return true
+	}
+	return l.PID > 0 && l.Host == hostname() && !processAlive(int(l.PID))
+}
+
+// ownedBy reports whether the lock is held by the current process for a run.
+func (l *Lock) ownedBy(runID string) bool {
+	return l.RunID == runID && l.PID == int64(os.Getpid()) && l.Host == hostname()
+}
+
+// LockHeldError is returned by AcquireLock when another run holds the lock.
+type LockHeldError struct {
+	Lock Lock
+}
+
+func (e *LockHeldError) Error() string {
+	holder := fmt.Sprintf("run %s", e.Lock.RunID)
+	if e.Lock.PID > 0 {
+		holder += fmt.Sprintf(" (pid %d on %s)", e.Lock.PID, e.Lock.Host)
+	}
+	return fmt.Sprintf("%s is locked by %s since %s", e.Lock.Name, holder, e.Lock.AcquiredAt.Format("2006-01-02 15:04:05"))
+}
+
+// ErrLockLost is returned by RenewLock when the lock is no longer held by the run, e.g.
+// because it was taken over after the lease expired.
+var ErrLockLost = errors.New("lock is no longer held")
+
+// AcquireLock takes the named lock for a run, owned by the current process, for one lease.
+// A stale lock is taken over; a lock already held by this process for the same run is
+// refreshed. If another run, or another process executing the same run, holds the lock, a
+// *LockHeldError describing it is returned.
+func (s *Store) AcquireLock(name, runID string, lease time.Duration) (*Lock, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	held, err := scanLock(tx.QueryRow(QueryGetLock, name))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	lock, err := takeLock(held, name, runID, lease, now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(QueryAcquireLock, lock.Name, lock.RunID, lock.PID, lock.Host, lock.AcquiredAt, lock.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// takeLock returns the lock a run acquires for one lease, owned by the current process,
+// given the lock currently held under that name, if any. It returns a *LockHeldError if
+// the lock is not stale and held by another run or by another process, such as a second
+// wf resume of the same run.
+func takeLock(held *Lock, name, runID string, lease time.Duration, now time.Time) (*Lock, error) {
+	if held != nil && !held.ownedBy(runID) && !held.Stale(now) {
+		return nil, &LockHeldError{Lock: *held}
+	}
+
+	return &Lock{
+		Name:       name,
+		RunID:      runID,
+		PID:        int64(os.Getpid()),
+		Host:       hostname(),
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(lease),
+	}, nil
+}
+
+// RenewLock extends the lease of a lock held by the current process for a run. It returns
+// ErrLockLost if the lock was taken over.
+func (s *Store) RenewLock(name, runID string, lease time.Duration) error {
+	result, err := s.db.Exec(QueryRenewLock, time.Now().Add(lease), name, runID, os.Getpid(), hostname())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%s: %w", name, ErrLockLost)
+	}
+	return nil
+}
+
+// ReleaseLock releases a lock held by the current process for a run. Releasing a lock it
+// does not hold is a no-op.
+func (s *Store) ReleaseLock(name, runID string) error {
+	_, err := s.db.Exec(QueryReleaseLock, name, runID, os.Getpid(), hostname())
+	return err
+}
+
+// GetLock returns the named lock, or nil if nobody holds it.
+func (s *Store) GetLock(name string) (*Lock, error) {
+	lock, err := scanLock(s.db.QueryRow(QueryGetLock, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return lock, err
+}
+
+// scanLock reads a Lock from a row selected with the run_locks column list.
+func scanLock(row rowScanner) (*Lock, error) {
+	var (
+		lock Lock
+		pid  sql.NullInt64
+		host sql.NullString
+	)
+	if err := row.Scan(&lock.Name, &lock.RunID, &pid, &host, &lock.AcquiredAt, &lock.ExpiresAt); err != nil {
+		return nil, err
+	}
+	lock.PID = pid.Int64
+	lock.Host = host.String
+	return &lock, nil
+}
@@ -0,0 +1,36 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockFile takes a lock file serialising processes, waiting up to timeout for another
+// process to release it, and returns a function that releases it. A lock file older than
+// stale is assumed to be left by a crashed process and is removed.
+func lockFile(path string, timeout, stale time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > stale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock file %s", path)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
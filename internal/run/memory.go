@@ -0,0 +1,600 @@
+package run
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps runs, task runs and locks in memory. Nothing outlives the
+// process, which makes it suited to tests and to embedding the executor.
+type MemoryRepository struct {
+	stateRepository
+}
+
+// NewMemoryRepository returns an empty MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{stateRepository{state: newMemState()}}
+}
+
+// Close does nothing; the content of a MemoryRepository lives as long as the value.
+func (r *MemoryRepository) Close() error {
+	return nil
+}
+
+// memState is the content of an in-memory repository.
+type memState struct {
+	runs       map[string]*WorkflowRun
+	tasks      map[int64]*TaskRun
+	runTasks   map[string][]int64 // Task run IDs of each run, in creation order
+	lastTaskID int64
+	locks      map[string]*Lock
+}
+
+func newMemState() *memState {
+	return &memState{
+		runs:     map[string]*WorkflowRun{},
+		tasks:    map[int64]*TaskRun{},
+		runTasks: map[string][]int64{},
+		locks:    map[string]*Lock{},
+	}
+}
+
+// change records the effect of one repository operation: the runs, task runs and locks it
+// wrote, the names of the locks it released and the IDs of the runs it deleted. Changes
+// are applied whole, so every operation is atomic; the JSON-lines backend appends each
+// change as one line.
+type change struct {
+	Runs     []*WorkflowRun
+	Tasks    []*TaskRun
+	Locks    []*Lock
+	Released []string
+	Deleted  []string
+}
+
+// apply writes a change to the state. The state keeps its own copies of the values.
+func (s *memState) apply(c *change) {
+	for _, run := range c.Runs {
+		r := *run
+		s.runs[r.ID] = &r
+	}
+	for _, task := range c.Tasks {
+		t := *task
+		if _, ok := s.tasks[t.ID]; !ok {
+			s.runTasks[t.RunID] = append(s.runTasks[t.RunID], t.ID)
+		}
+		s.tasks[t.ID] = &t
+		if t.ID > s.lastTaskID {
+			s.lastTaskID = t.ID
+		}
+	}
+	for _, lock := range c.Locks {
+		l := *lock
+		s.locks[l.Name] = &l
+	}
+	for _, name := range c.Released {
+		delete(s.locks, name)
+	}
+	for _, id := range c.Deleted {
+		for _, taskID := range s.runTasks[id] {
+			delete(s.tasks, taskID)
+		}
+		delete(s.runTasks, id)
+		delete(s.runs, id)
+		for name, lock := range s.locks {
+			if lock.RunID == id {
+				delete(s.locks, name)
+			}
+		}
+	}
+}
+
+// currentTask returns the task run of a task that has not been archived, or nil.
+func (s *memState) currentTask(runID, name string) *TaskRun {
+	for _, id := range s.runTasks[runID] {
+		if t := s.tasks[id]; t.Name == name && !t.Archived {
+			return t
+		}
+	}
+	return nil
+}
+
+// runsByStatus returns copies of the runs matching the filters, most recent first.
+func (s *memState) runsByStatus(workflow string, status WorkflowStatus) []*WorkflowRun {
+	var runs []*WorkflowRun
+	for _, r := range s.runs {
+		if (workflow == "" || r.Workflow == workflow) && (status == "" || r.Status == status) {
+			c := *r
+			runs = append(runs, &c)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].CreatedAt.Equal(runs[j].CreatedAt) {
+			return runs[i].CreatedAt.After(runs[j].CreatedAt)
+		}
+		return runs[i].ID < runs[j].ID
+	})
+	return runs
+}
+
+// stateRepository implements Repository over a memState. When a journal is set, every
+// change is appended to it before being applied, and changes appended by other processes
+// are replayed before each operation.
+type stateRepository struct {
+	mu      sync.Mutex
+	state   *memState
+	journal *journal
+}
+
+// view runs fn against an up-to-date state.
+func (r *stateRepository) view(fn func(s *memState) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal != nil {
+		if err := r.journal.replay(r.state); err != nil {
+			return err
+		}
+	}
+	return fn(r.state)
+}
+
+// write runs fn against an up-to-date state and applies the change it returns. With a
+// journal, the journal stays locked from the replay until the change is appended, so
+// concurrent writers never act on outdated state.
+func (r *stateRepository) write(fn func(s *memState) (*change, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal != nil {
+		unlock, err := r.journal.lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := r.journal.replay(r.state); err != nil {
+			return err
+		}
+	}
+
+	c, err := fn(r.state)
+	if err != nil || c == nil {
+		return err
+	}
+
+	if r.journal != nil {
+		if err := r.journal.append(c); err != nil {
+			return err
+		}
+	}
+	r.state.apply(c)
+	return nil
+}
+
+// CreateWorkflowRun stores a new WorkflowRun, assigning its ID, status and timestamps when unset.
+func (r *stateRepository) CreateWorkflowRun(run *WorkflowRun) error {
+	prepareRun(run)
+
+	return r.write(func(s *memState) (*change, error) {
+		if _, ok := s.runs[run.ID]; ok {
+			return nil, fmt.Errorf("run %s already exists", run.ID)
+		}
+		return &change{Runs: []*WorkflowRun{run}}, nil
+	})
+}
+
+// Update persists the status, end time, exit code, metadata, hash and definition of a WorkflowRun.
+func (r *stateRepository) Update(run *WorkflowRun) error {
+	return r.write(func(s *memState) (*change, error) {
+		updated := updatedRun(s, run)
+		if updated == nil {
+			return nil, nil
+		}
+		return &change{Runs: []*WorkflowRun{updated}}, nil
+	})
+}
+
+// updatedRun returns a copy of the stored run with the fields written by Update, or nil if
+// the run does not exist.
+func updatedRun(s *memState, run *WorkflowRun) *WorkflowRun {
+	stored, ok := s.runs[run.ID]
+	if !ok {
+		return nil
+	}
+
+	updated := *stored
+	updated.Status = run.Status
+	updated.EndedAt = run.EndedAt
+	updated.ExitCode = run.ExitCode
+	updated.Meta = run.Meta
+	updated.WorkflowHash = run.WorkflowHash
+	updated.Definition = run.Definition
+	return &updated
+}
+
+// Load retrieves a WorkflowRun by its ID.
+func (r *stateRepository) Load(id string) (*WorkflowRun, error) {
+	var run *WorkflowRun
+	err := r.view(func(s *memState) error {
+		stored, ok := s.runs[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		c := *stored
+		run = &c
+		return nil
+	})
+	return run, err
+}
+
+// ListRuns retrieves workflow runs with optional filtering and pagination.
+func (r *stateRepository) ListRuns(workflow, status string, limit, offset int) ([]*WorkflowRun, error) {
+	var runs []*WorkflowRun
+	err := r.view(func(s *memState) error {
+		runs = s.runsByStatus(workflow, WorkflowStatus(status))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if offset >= len(runs) {
+		return nil, nil
+	}
+	runs = runs[offset:]
+	if limit >= 0 && limit < len(runs) {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// FindRuns retrieves the runs whose ID starts with prefix, most recent first.
+func (r *stateRepository) FindRuns(prefix string) ([]*WorkflowRun, error) {
+	var runs []*WorkflowRun
+	err := r.view(func(s *memState) error {
+		for _, run := range s.runsByStatus("", "") {
+			if strings.HasPrefix(run.ID, prefix) {
+				runs = append(runs, run)
+			}
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// ListRunsWithTasks retrieves the most recent runs of a workflow that are not running,
+// most recent first, each with its current TaskRuns.
+func (r *stateRepository) ListRunsWithTasks(workflow string, limit int) ([]RunTasks, error) {
+	var runs []RunTasks
+	err := r.view(func(s *memState) error {
+		for _, run := range s.runsByStatus(workflow, "") {
+			if limit >= 0 && len(runs) == limit {
+				break
+			}
+			if run.Status == StatusRunning {
+				continue
+			}
+			entry := RunTasks{Run: run}
+			for _, id := range s.runTasks[run.ID] {
+				if t := s.tasks[id]; !t.Archived {
+					entry.Tasks = append(entry.Tasks, *t)
+				}
+			}
+			runs = append(runs, entry)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// Reopen marks an existing run running again, owned by the current process.
+func (r *stateRepository) Reopen(run *WorkflowRun) error {
+	run.reopen(time.Now())
+
+	return r.write(func(s *memState) (*change, error) {
+		updated := updatedRun(s, run)
+		if updated == nil {
+			return nil, nil
+		}
+		updated.PID, updated.Host, updated.HeartbeatAt = run.PID, run.Host, run.HeartbeatAt
+		updated.ResumedAt = run.ResumedAt
+		return &change{Runs: []*WorkflowRun{updated}}, nil
+	})
+}
+
+// Heartbeat records that the process executing a run is still alive.
+func (r *stateRepository) Heartbeat(id string, at time.Time) error {
+	return r.write(func(s *memState) (*change, error) {
+		stored, ok := s.runs[id]
+		if !ok || stored.Status != StatusRunning {
+			return nil, nil
+		}
+		updated := *stored
+		updated.HeartbeatAt = sql.NullTime{Time: at, Valid: true}
+		return &change{Runs: []*WorkflowRun{&updated}}, nil
+	})
+}
+
+// ListStaleRuns returns the runs still marked running whose process has died or stopped
+// sending heartbeats, most recent first.
+func (r *stateRepository) ListStaleRuns(staleAfter time.Duration) ([]*WorkflowRun, error) {
+	var stale []*WorkflowRun
+	err := r.view(func(s *memState) error {
+		now := time.Now()
+		for _, run := range s.runsByStatus("", StatusRunning) {
+			if run.Stale(now, staleAfter) {
+				stale = append(stale, run)
+			}
+		}
+		return nil
+	})
+	return stale, err
+}
+
+// MarkInterrupted marks a stale run and its running tasks as interrupted and releases its
+// locks. The run is considered to have ended at its last heartbeat.
+func (r *stateRepository) MarkInterrupted(run *WorkflowRun) error {
+	endedAt := run.LastSeen()
+
+	err := r.write(func(s *memState) (*change, error) {
+		stored, ok := s.runs[run.ID]
+		if !ok || stored.Status != StatusRunning {
+			return nil, errNotRunning(run.ID)
+		}
+
+		c := &change{}
+		for _, id := range s.runTasks[run.ID] {
+			if t := s.tasks[id]; t.Status == TaskRunning && !t.Archived {
+				interrupted := *t
+				interrupted.Status = TaskInterrupted
+				interrupted.EndedAt = sql.NullTime{Time: endedAt, Valid: true}
+				interrupted.LastError = interruptReason(run)
+				c.Tasks = append(c.Tasks, &interrupted)
+			}
+		}
+		for name, lock := range s.locks {
+			if lock.RunID == run.ID {
+				c.Released = append(c.Released, name)
+			}
+		}
+
+		updated := *stored
+		updated.Status = StatusInterrupted
+		updated.EndedAt = sql.NullTime{Time: endedAt, Valid: true}
+		c.Runs = []*WorkflowRun{&updated}
+		return c, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	run.Status = StatusInterrupted
+	run.EndedAt = sql.NullTime{Time: endedAt, Valid: true}
+	return nil
+}
+
+// DeleteRun deletes a run that is not running, together with its task runs and locks.
+func (r *stateRepository) DeleteRun(id string) error {
+	return r.write(func(s *memState) (*change, error) {
+		stored, ok := s.runs[id]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		if stored.Status == StatusRunning {
+			return nil, errRunning(id)
+		}
+		return &change{Deleted: []string{id}}, nil
+	})
+}
+
+// ImportRun stores a run recorded elsewhere, with its task runs given new IDs. It reports
+// false if a run with the same ID already exists.
+func (r *stateRepository) ImportRun(run *WorkflowRun, tasks []TaskRun) (bool, error) {
+	imported := false
+	err := r.write(func(s *memState) (*change, error) {
+		if _, ok := s.runs[run.ID]; ok {
+			return nil, nil
+		}
+
+		c := &change{Runs: []*WorkflowRun{run}}
+		id := s.lastTaskID
+		for _, task := range tasks {
+			id++
+			t := task
+			t.ID = id
+			t.RunID = run.ID
+			c.Tasks = append(c.Tasks, &t)
+		}
+
+		imported = true
+		return c, nil
+	})
+	return imported, err
+}
+
+// SaveTaskRun stores a new TaskRun and assigns its ID.
+func (r *stateRepository) SaveTaskRun(task *TaskRun) error {
+	return r.write(func(s *memState) (*change, error) {
+		saved := *task
+		saved.ID = s.lastTaskID + 1
+		task.ID = saved.ID
+		return &change{Tasks: []*TaskRun{&saved}}, nil
+	})
+}
+
+// UpdateTaskRun updates an existing TaskRun.
+func (r *stateRepository) UpdateTaskRun(task *TaskRun) error {
+	return r.write(func(s *memState) (*change, error) {
+		updated := updatedTask(s, task)
+		if updated == nil {
+			return nil, nil
+		}
+		return &change{Tasks: []*TaskRun{updated}}, nil
+	})
+}
+
+// updatedTask returns a copy of the stored task run with the fields written by
+// UpdateTaskRun, or nil if the task run does not exist.
+func updatedTask(s *memState, task *TaskRun) *TaskRun {
+	stored, ok := s.tasks[task.ID]
+	if !ok {
+		return nil
+	}
+
+	updated := *stored
+	updated.Status = task.Status
+	updated.StartedAt = task.StartedAt
+	updated.EndedAt = task.EndedAt
+	updated.Attempts = task.Attempts
+	updated.PriorAttempts = task.PriorAttempts
+	updated.ExitCode = task.ExitCode
+	updated.LogPath = task.LogPath
+	updated.LastError = task.LastError
+	return &updated
+}
+
+// FinishTask persists the final state of a TaskRun together with the status change of its WorkflowRun.
+func (r *stateRepository) FinishTask(task *TaskRun, run *WorkflowRun) error {
+	return r.write(func(s *memState) (*change, error) {
+		c := &change{}
+		if updated := updatedTask(s, task); updated != nil {
+			c.Tasks = append(c.Tasks, updated)
+		}
+		if updated := updatedRun(s, run); updated != nil {
+			c.Runs = append(c.Runs, updated)
+		}
+		return c, nil
+	})
+}
+
+// LoadTaskRuns retrieves the current TaskRuns of a WorkflowRun.
+func (r *stateRepository) LoadTaskRuns(runID string) ([]TaskRun, error) {
+	var tasks []TaskRun
+	err := r.view(func(s *memState) error {
+		for _, id := range s.runTasks[runID] {
+			if t := s.tasks[id]; !t.Archived {
+				tasks = append(tasks, *t)
+			}
+		}
+		return nil
+	})
+	return tasks, err
+}
+
+// GetTaskRun retrieves the current TaskRun of a task.
+func (r *stateRepository) GetTaskRun(runID, taskName string) (*TaskRun, error) {
+	var task *TaskRun
+	err := r.view(func(s *memState) error {
+		current := s.currentTask(runID, taskName)
+		if current == nil {
+			return sql.ErrNoRows
+		}
+		c := *current
+		task = &c
+		return nil
+	})
+	return task, err
+}
+
+// LoadTaskRunHistory retrieves every TaskRun recorded for a WorkflowRun, including
+// those archived by a rerun, in the order they were created.
+func (r *stateRepository) LoadTaskRunHistory(runID string) ([]TaskRun, error) {
+	var tasks []TaskRun
+	err := r.view(func(s *memState) error {
+		for _, id := range s.runTasks[runID] {
+			tasks = append(tasks, *s.tasks[id])
+		}
+		return nil
+	})
+	return tasks, err
+}
+
+// ResetTaskRuns archives the current TaskRuns of the named tasks and replaces them with
+// pending TaskRuns carrying over the attempt count.
+func (r *stateRepository) ResetTaskRuns(runID string, names []string) error {
+	return r.write(func(s *memState) (*change, error) {
+		c := &change{}
+		id := s.lastTaskID
+
+		for _, name := range names {
+			attempts := 0
+			if current := s.currentTask(runID, name); current != nil {
+				attempts = current.Attempts
+				archived := *current
+				archived.Archived = true
+				c.Tasks = append(c.Tasks, &archived)
+			}
+
+			id++
+			c.Tasks = append(c.Tasks, &TaskRun{
+				ID:            id,
+				RunID:         runID,
+				Name:          name,
+				Status:        TaskPending,
+				StartedAt:     time.Now(),
+				Attempts:      attempts,
+				PriorAttempts: attempts,
+			})
+		}
+		return c, nil
+	})
+}
+
+// AcquireLock takes the named lock for a run, owned by the current process, for one lease.
+func (r *stateRepository) AcquireLock(name, runID string, lease time.Duration) (*Lock, error) {
+	var lock *Lock
+	err := r.write(func(s *memState) (*change, error) {
+		var err error
+		lock, err = takeLock(s.locks[name], name, runID, lease, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return &change{Locks: []*Lock{lock}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// RenewLock extends the lease of a lock held by the current process for a run.
+func (r *stateRepository) RenewLock(name, runID string, lease time.Duration) error {
+	return r.write(func(s *memState) (*change, error) {
+		held, ok := s.locks[name]
+		if !ok || !held.ownedBy(runID) {
+			return nil, fmt.Errorf("%s: %w", name, ErrLockLost)
+		}
+		renewed := *held
+		renewed.ExpiresAt = time.Now().Add(lease)
+		return &change{Locks: []*Lock{&renewed}}, nil
+	})
+}
+
+// ReleaseLock releases a lock held by the current process for a run. Releasing a lock it
+// does not hold is a no-op.
+func (r *stateRepository) ReleaseLock(name, runID string) error {
+	return r.write(func(s *memState) (*change, error) {
+		held, ok := s.locks[name]
+		if !ok || !held.ownedBy(runID) {
+			return nil, nil
+		}
+		return &change{Released: []string{name}}, nil
+	})
+}
+
+// GetLock returns the named lock, or nil if nobody holds it.
+func (r *stateRepository) GetLock(name string) (*Lock, error) {
+	var lock *Lock
+	err := r.view(func(s *memState) error {
+		if held, ok := s.locks[name]; ok {
+			c := *held
+			lock = &c
+		}
+		return nil
+	})
+	return lock, err
+}
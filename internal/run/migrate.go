@@ -0,0 +1,334 @@
+package run
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Migration is a single, ordered change to the database schema.
+// Migrations are applied in Version order, each in its own transaction.
+type Migration struct {
+	Version     int
+	Description string
+
+	up func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change, oldest first. Never edit or reorder an entry
+// that has been released: append a new migration instead.
+//
+// Databases created before versioning have no schema_version table, but may already
+// contain some of the later columns, so every migration must be safe to apply to them.
+var migrations = []Migration{
+	{1, "create workflow_runs and task_runs", execSQL(`
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id TEXT PRIMARY KEY,
+    workflow TEXT NOT NULL,
+    workflow_hash TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    exit_code INTEGER,
+    meta TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS task_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    exit_code INTEGER,
+    log_path TEXT,
+    last_error TEXT,
+    FOREIGN KEY (run_id) REFERENCES workflow_runs(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_runs_run_id ON task_runs(run_id);
+`)},
+	{2, "record the workflow source of each run", addColumns("workflow_runs", "source TEXT")},
+	{3, "record the workflow definition of each run", addColumns("workflow_runs", "definition TEXT")},
+	{4, "record the git commit and revision of each run", addColumns("workflow_runs", "git_commit TEXT", "git_dirty INTEGER", "git_rev TEXT")},
+	{5, "record the task selection of each run", addColumns("workflow_runs", "selection TEXT")},
+	{6, "keep task attempts replaced by a rerun", addColumns("task_runs", "archived INTEGER NOT NULL DEFAULT 0")},
+	{7, "record the process and heartbeat of each run", addColumns("workflow_runs", "pid INTEGER", "host TEXT", "heartbeat_at TIMESTAMP")},
+	{8, "create run_locks", execSQL(`
+CREATE TABLE IF NOT EXISTS run_locks (
+    name TEXT PRIMARY KEY,
+    run_id TEXT NOT NULL,
+    pid INTEGER,
+    host TEXT,
+    acquired_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+`)},
+	{9, "record the origin host of imported runs", addColumns("workflow_runs", "origin TEXT")},
+	{10, "record the attempts of earlier executions of each task", addColumns("task_runs", "prior_attempts INTEGER NOT NULL DEFAULT 0")},
+	{11, "record when each run was last resumed", addColumns("workflow_runs", "resumed_at TIMESTAMP")},
+}
+
+const querySchemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    description TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+);
+`
+
+// LatestSchemaVersion returns the schema version this build of wf migrates databases to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationStatus reports whether a migration has been applied to a database.
+type MigrationStatus struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"` // nil if the migration is pending
+}
+
+// MigrationResult describes the outcome of Migrate.
+type MigrationResult struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Applied []Migration `json:"-"`
+	Backup  string      `json:"backup,omitempty"` // Copy of the database taken before migrating, if any
+}
+
+// SchemaVersion returns the highest migration version applied to the database, or 0 for a
+// database created before versioning or not yet initialised.
+func (s *Store) SchemaVersion() (int, error) {
+	exists, err := s.tableExists("schema_version")
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := s.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Migrations lists every known migration and when it was applied.
+func (s *Store) Migrations() ([]MigrationStatus, error) {
+	applied := map[int]time.Time{}
+
+	exists, err := s.tableExists("schema_version")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		rows, err := s.db.Query("SELECT version, applied_at FROM schema_version")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				version   int
+				appliedAt time.Time
+			)
+			if err := rows.Scan(&version, &appliedAt); err != nil {
+				return nil, err
+			}
+			applied[version] = appliedAt
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Description: m.Description}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+const (
+	// migrateLockTimeout is how long Migrate waits for another process migrating the
+	// same database.
+	migrateLockTimeout = 5 * time.Minute
+	// migrateLockStale is the age after which a migration lock file is assumed to be left
+	// by a crashed process. Backing up a large database can take a while.
+	migrateLockStale = 15 * time.Minute
+)
+
+// Migrate applies every pending migration in order, each in its own transaction.
+// If the database already holds data, it is first copied to a backup file next to it.
+// A database migrated by a newer version of wf is refused rather than modified.
+//
+// Processes migrating the same database take turns through a lock file next to it, so
+// when several start at once, e.g. from cron, one migrates and the others find the
+// database up to date.
+func (s *Store) Migrate() (*MigrationResult, error) {
+	result, err := s.pendingMigration()
+	if err != nil || result.From == LatestSchemaVersion() {
+		return result, err
+	}
+
+	if s.path != "" {
+		unlock, err := lockFile(s.path+".migrate.lock", migrateLockTimeout, migrateLockStale)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock database for migration: %w", err)
+		}
+		defer unlock()
+
+		// Another process may have migrated the database while we waited
+		if result, err = s.pendingMigration(); err != nil || result.From == LatestSchemaVersion() {
+			return result, err
+		}
+	}
+	from := result.From
+
+	hasData, err := s.tableExists("workflow_runs")
+	if err != nil {
+		return nil, err
+	}
+	if hasData && s.path != "" {
+		backup, err := s.backup(from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+		result.Backup = backup
+	}
+
+	if _, err := s.db.Exec(querySchemaVersionTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= from {
+			continue
+		}
+
+		if err := s.apply(m); err != nil {
+			return result, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		result.To = m.Version
+		result.Applied = append(result.Applied, m)
+	}
+
+	return result, nil
+}
+
+// pendingMigration reads the schema version of the database, refusing a database migrated
+// by a newer version of wf.
+func (s *Store) pendingMigration() (*MigrationResult, error) {
+	from, err := s.SchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if from > LatestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than the latest version %d supported by this wf; upgrade wf", from, LatestSchemaVersion())
+	}
+	return &MigrationResult{From: from, To: from}, nil
+}
+
+// apply runs a migration and records it in schema_version in a single transaction.
+func (s *Store) apply(m Migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)", m.Version, m.Description, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// backup copies the database to "<path>.v<version>-<timestamp>-<pid>.bak" and returns the
+// copy's path. The PID keeps the names of backups taken within the same second apart.
+func (s *Store) backup(version int) (string, error) {
+	path := fmt.Sprintf("%s.v%d-%s-%d.bak", s.path, version, time.Now().Format("20060102T150405"), os.Getpid())
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("backup %s already exists", path)
+	}
+
+	if _, err := s.db.Exec("VACUUM INTO ?", path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// tableExists reports whether the named table exists.
+func (s *Store) tableExists(name string) (bool, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	return n > 0, err
+}
+
+// execSQL returns a migration step that executes the given statements.
+func execSQL(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// addColumns returns a migration step that adds columns to a table. Each column is given
+// as "name definition" and is skipped if the table already has it, as databases created
+// before versioning may.
+func addColumns(table string, columns ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		existing, err := columnNames(tx, table)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range columns {
+			name, _, _ := strings.Cut(c, " ")
+			if existing[name] {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, c)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// columnNames returns the set of column names of a table.
+func columnNames(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[string]bool{}
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
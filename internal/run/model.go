@@ -14,6 +14,9 @@ const (
 	StatusRunning WorkflowStatus = "running"
 	StatusSuccess WorkflowStatus = "success"
 	StatusFailed  WorkflowStatus = "failed"
+
+	// StatusInterrupted marks a run whose process died without finishing it, e.g. in a crash or reboot.
+	StatusInterrupted WorkflowStatus = "interrupted"
 )
 
 const (
@@ -21,58 +24,31 @@ const (
 	TaskRunning TaskStatus = "running"
 	TaskSuccess TaskStatus = "success"
 	TaskFailed  TaskStatus = "failed"
-)
 
-const dbschema = `
-CREATE TABLE IF NOT EXISTS workflow_runs (
-    id TEXT PRIMARY KEY,
-    workflow TEXT NOT NULL,
-    workflow_hash TEXT NOT NULL,
-    status TEXT NOT NULL,
-    started_at TIMESTAMP NOT NULL,
-    ended_at TIMESTAMP,
-    exit_code INTEGER,
-    meta TEXT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE IF NOT EXISTS task_runs (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    run_id TEXT NOT NULL,
-    name TEXT NOT NULL,
-    status TEXT NOT NULL,
-    started_at TIMESTAMP NOT NULL,
-    ended_at TIMESTAMP,
-    attempts INTEGER NOT NULL DEFAULT 0,
-    exit_code INTEGER,
-    log_path TEXT,
-    last_error TEXT,
-    FOREIGN KEY (run_id) REFERENCES workflow_runs(id)
-);
-
-CREATE INDEX IF NOT EXISTS idx_task_runs_run_id ON task_runs(run_id);
-`
+	// TaskInterrupted marks a task that was running when its run was interrupted.
+	TaskInterrupted TaskStatus = "interrupted"
+)
 
 const (
 	QueryCreateWorkflowRun = `
-        INSERT INTO workflow_runs (id, workflow, workflow_hash, status, started_at, created_at)
-        VALUES (?, ?, ?, ?, ?, ?)
+        INSERT INTO workflow_runs (id, workflow, workflow_hash, status, started_at, created_at, source, definition, git_commit, git_dirty, git_rev, selection, pid, host, heartbeat_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `
 
 	QueryUpdateWorkflowRun = `
         UPDATE workflow_runs
-        SET status = ?, ended_at = ?, exit_code = ?, meta = ?
+        SET status = ?, ended_at = ?, exit_code = ?, meta = ?, workflow_hash = ?, definition = ?
         WHERE id = ?
     `
 
 	QueryLoadWorkflowRun = `
-        SELECT id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at
+        SELECT id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at, source, definition, git_commit, git_dirty, git_rev, selection, pid, host, heartbeat_at, origin, resumed_at
         FROM workflow_runs
         WHERE id = ?
     `
 
 	QueryListRuns = `
-		SELECT id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at
+		SELECT id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at, source, definition, git_commit, git_dirty, git_rev, selection, pid, host, heartbeat_at, origin, resumed_at
 		FROM workflow_runs
 		WHERE (? = '' OR workflow = ?)
 			AND (? = '' OR status = ?)
@@ -80,30 +56,140 @@ const (
 		LIMIT ? OFFSET ?
 	`
 
+	QueryFindRuns = `
+		SELECT id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at, source, definition, git_commit, git_dirty, git_rev, selection, pid, host, heartbeat_at, origin, resumed_at
+		FROM workflow_runs
+		WHERE substr(id, 1, length(?)) = ?
+		ORDER BY created_at DESC
+	`
+
+	QueryListFinishedRuns = `
+		SELECT id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at, source, definition, git_commit, git_dirty, git_rev, selection, pid, host, heartbeat_at, origin, resumed_at
+		FROM workflow_runs
+		WHERE workflow = ? AND status != 'running'
+		ORDER BY created_at DESC
+		LIMIT ?
+	`
+
+	QueryLoadFinishedTaskRuns = `
+		SELECT t.id, t.run_id, t.name, t.status, t.started_at, t.ended_at, t.attempts, t.exit_code, t.log_path, t.last_error, t.prior_attempts
+		FROM task_runs t
+		JOIN (
+			SELECT id FROM workflow_runs
+			WHERE workflow = ? AND status != 'running'
+			ORDER BY created_at DESC
+			LIMIT ?
+		) r ON t.run_id = r.id
+		WHERE t.archived = 0
+		ORDER BY t.id
+	`
+
+	QueryListRunningRuns = `
+		SELECT id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at, source, definition, git_commit, git_dirty, git_rev, selection, pid, host, heartbeat_at, origin, resumed_at
+		FROM workflow_runs
+		WHERE status = 'running'
+		ORDER BY created_at DESC
+	`
+
+	QueryClaimWorkflowRun = `
+        UPDATE workflow_runs
+        SET pid = ?, host = ?, heartbeat_at = ?
+        WHERE id = ?
+    `
+
+	QueryResumeWorkflowRun = `
+        UPDATE workflow_runs
+        SET resumed_at = ?
+        WHERE id = ?
+    `
+
+	QueryHeartbeat = `
+        UPDATE workflow_runs
+        SET heartbeat_at = ?
+        WHERE id = ? AND status = 'running'
+    `
+
+	QueryGetLock = `
+        SELECT name, run_id, pid, host, acquired_at, expires_at
+        FROM run_locks
+        WHERE name = ?
+    `
+
+	QueryAcquireLock = `
+        INSERT OR REPLACE INTO run_locks (name, run_id, pid, host, acquired_at, expires_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `
+
+	QueryRenewLock = `
+        UPDATE run_locks
+        SET expires_at = ?
+        WHERE name = ? AND run_id = ? AND pid = ? AND host = ?
+    `
+
+	QueryReleaseLock = `
+        DELETE FROM run_locks
+        WHERE name = ? AND run_id = ? AND pid = ? AND host = ?
+    `
+
+	QueryInterruptTaskRuns = `
+        UPDATE task_runs
+        SET status = 'interrupted', ended_at = ?, last_error = ?
+        WHERE run_id = ? AND status = 'running' AND archived = 0
+    `
+
+	QueryImportWorkflowRun = `
+        INSERT INTO workflow_runs (id, workflow, workflow_hash, status, started_at, ended_at, exit_code, meta, created_at, source, definition, git_commit, git_dirty, git_rev, selection, pid, host, heartbeat_at, origin, resumed_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+
+	QueryImportTaskRun = `
+        INSERT INTO task_runs (run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error, archived, prior_attempts)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+
 	QueryCreateTaskRun = `
-        INSERT INTO task_runs (run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+        INSERT INTO task_runs (run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error, prior_attempts)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `
 
 	QueryUpdateTaskRun = `
         UPDATE task_runs
-        SET status = ?, ended_at = ?, attempts = ?, exit_code = ?, log_path = ?, last_error = ?
+        SET status = ?, started_at = ?, ended_at = ?, attempts = ?, exit_code = ?, log_path = ?, last_error = ?, prior_attempts = ?
         WHERE id = ?
     `
 
 	QueryLoadTaskRuns = `
-        SELECT id, run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error
+        SELECT id, run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error, prior_attempts
+        FROM task_runs
+        WHERE run_id = ? AND archived = 0
+    `
+
+	QueryLoadTaskRunHistory = `
+        SELECT id, run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error, archived, prior_attempts
         FROM task_runs
         WHERE run_id = ?
+        ORDER BY id
+    `
+
+	QueryArchiveTaskRun = `
+        UPDATE task_runs
+        SET archived = 1
+        WHERE run_id = ? AND name = ? AND archived = 0
     `
 
 	QueryGetTaskRun = `
-		SELECT id, run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error
+		SELECT id, run_id, name, status, started_at, ended_at, attempts, exit_code, log_path, last_error, prior_attempts
 		FROM task_runs
-		WHERE run_id = ? AND name = ?
+		WHERE run_id = ? AND name = ? AND archived = 0
 	`
 )
 
+// RunTasks is a WorkflowRun with its current TaskRuns.
+type RunTasks struct {
+	Run   *WorkflowRun
+	Tasks []TaskRun
+}
+
 // TaskPlan represents the plan for a single task in a workflow.
 type TaskPlan struct {
 	Order     int      `json:"order"`
@@ -111,12 +197,15 @@ type TaskPlan struct {
 	Cmd       string   `json:"cmd"`
 	DependsOn []string `json:"depends_on"`
 	Retries   int      `json:"retries"`
+	Priority  int      `json:"priority,omitempty"`
 }
 
 // WorkflowPlan represents the plan for a workflow.
 type WorkflowPlan struct {
-	Workflow string     `json:"workflow"`
-	Tasks    []TaskPlan `json:"tasks"`
+	Workflow  string     `json:"workflow"`
+	Tasks     []TaskPlan `json:"tasks"`
+	Selection string     `json:"selection,omitempty"` // Flags that restricted the plan to a subgraph
+	Skipped   []string   `json:"skipped,omitempty"`   // Tasks excluded by the selection
 }
 
 // WorkflowRun represents a single execution of a workflow.
@@ -130,6 +219,17 @@ type WorkflowRun struct {
 	ExitCode     sql.NullInt64  `db:"exit_code"`
 	Meta         sql.NullString `db:"meta"` // JSON string
 	CreatedAt    time.Time      `db:"created_at"`
+	Source       sql.NullString `db:"source"`       // Absolute path of the definition, or "-" for stdin
+	Definition   sql.NullString `db:"definition"`   // Normalised JSON snapshot of the DAG that ran
+	GitCommit    sql.NullString `db:"git_commit"`   // Commit the definition was read from, or HEAD for working tree runs
+	GitDirty     sql.NullBool   `db:"git_dirty"`    // Whether the working tree had uncommitted changes
+	GitRev       sql.NullString `db:"git_rev"`      // Revision requested with --rev, empty for working tree runs
+	Selection    sql.NullString `db:"selection"`    // JSON task selection when only a subgraph was run
+	PID          sql.NullInt64  `db:"pid"`          // Process executing the run
+	Host         sql.NullString `db:"host"`         // Hostname of the machine executing the run
+	HeartbeatAt  sql.NullTime   `db:"heartbeat_at"` // Last time the executing process reported it was alive
+	Origin       sql.NullString `db:"origin"`       // Host the run was exported from, for runs imported with wf import
+	ResumedAt    sql.NullTime   `db:"resumed_at"`   // Last time the run was resumed or rerun
 }
 
 // TaskRun represents the execution details of a single task within a workflow.
@@ -144,6 +244,11 @@ type TaskRun struct {
 	ExitCode  sql.NullInt64 `db:"exit_code"`
 	LogPath   string        `db:"log_path"`
 	LastError string        `db:"last_error"`
+	Archived  bool          `db:"archived"` // Superseded by a rerun; kept for audit
+
+	// PriorAttempts counts the attempts made by earlier executions of the task, before the
+	// run was resumed or rerun. Attempt numbers continue from it so that logs are kept.
+	PriorAttempts int `db:"prior_attempts"`
 }
 
 // MarshalMeta converts Meta map to JSON string for storage
@@ -177,6 +282,15 @@ func MarshalRun(w *WorkflowRun) ([]byte, error) {
 		ExitCode  *int64      `json:"exit_code,omitempty"`
 		Meta      interface{} `json:"meta,omitempty"`
 		CreatedAt time.Time   `json:"created_at"`
+		Source    string      `json:"source,omitempty"`
+		GitCommit string      `json:"git_commit,omitempty"`
+		GitDirty  bool        `json:"git_dirty,omitempty"`
+		GitRev    string      `json:"git_rev,omitempty"`
+		Selection interface{} `json:"selection,omitempty"`
+		PID       int64       `json:"pid,omitempty"`
+		Host      string      `json:"host,omitempty"`
+		Heartbeat *time.Time  `json:"heartbeat_at,omitempty"`
+		Origin    string      `json:"origin,omitempty"`
 	}
 
 	var endedAt *time.Time
@@ -194,6 +308,16 @@ func MarshalRun(w *WorkflowRun) ([]byte, error) {
 		_ = json.Unmarshal([]byte(w.Meta.String), &meta)
 	}
 
+	var heartbeat *time.Time
+	if w.HeartbeatAt.Valid {
+		heartbeat = &w.HeartbeatAt.Time
+	}
+
+	var selection interface{}
+	if w.Selection.Valid {
+		_ = json.Unmarshal([]byte(w.Selection.String), &selection)
+	}
+
 	return json.Marshal(runOutput{
 		ID:        w.ID,
 		Workflow:  w.Workflow,
@@ -203,5 +327,14 @@ func MarshalRun(w *WorkflowRun) ([]byte, error) {
 		ExitCode:  exitCode,
 		Meta:      meta,
 		CreatedAt: w.CreatedAt,
+		Source:    w.Source.String,
+		GitCommit: w.GitCommit.String,
+		GitDirty:  w.GitDirty.Bool,
+		GitRev:    w.GitRev.String,
+		Selection: selection,
+		PID:       w.PID.Int64,
+		Host:      w.Host.String,
+		Origin:    w.Origin.String,
+		Heartbeat: heartbeat,
 	})
 }
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package run
+
+import "os"
+
+// processAlive reports whether a process with the given PID exists on this machine.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// FindProcess opens a handle to the process on Windows and fails if it does not exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
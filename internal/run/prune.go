@@ -0,0 +1,162 @@
+package run
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy selects the runs to prune. A run is pruned only if it matches every
+// limit that is set; running runs are never pruned.
+type RetentionPolicy struct {
+	OlderThan time.Duration  // Prune runs created longer ago than this (0 = any age)
+	KeepLast  int            // Keep the most recent matching runs of each workflow (0 = none)
+	Status    WorkflowStatus // Only prune runs with this status (empty = any)
+	Workflow  string         // Only prune runs of this workflow (empty = any)
+}
+
+// Limited reports whether the policy bounds the age or number of runs kept. A policy
+// without either limit would prune every matching run.
+func (p RetentionPolicy) Limited() bool {
+	return p.OlderThan > 0 || p.KeepLast > 0
+}
+
+// PrunedRun describes a run removed by Prune.
+type PrunedRun struct {
+	Run      *WorkflowRun
+	TaskRuns int    // Task runs deleted with the run, including archived attempts
+	LogDir   string // Directory holding the run's task logs
+	LogBytes int64  // Size of the log directory
+}
+
+// PruneResult summarises a Prune.
+type PruneResult struct {
+	Runs          []PrunedRun
+	TaskRuns      int
+	LogBytes      int64 // Reclaimed by deleting log directories
+	DatabaseBytes int64 // Reclaimed by compacting the repository, if it supports it
+	DryRun        bool
+}
+
+// Reclaimed returns the total number of bytes reclaimed.
+func (r *PruneResult) Reclaimed() int64 {
+	return r.LogBytes + r.DatabaseBytes
+}
+
+// SelectPrunable returns the runs the policy prunes, most recent first.
+func SelectPrunable(repo Repository, policy RetentionPolicy, now time.Time) ([]*WorkflowRun, error) {
+	runs, err := repo.ListRuns(policy.Workflow, string(policy.Status), -1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := map[string]int{}
+	var prunable []*WorkflowRun
+	for _, r := range runs {
+		if r.Status == StatusRunning {
+			continue
+		}
+		if kept[r.Workflow] < policy.KeepLast {
+			kept[r.Workflow]++
+			continue
+		}
+		if policy.OlderThan > 0 && now.Sub(r.CreatedAt) <= policy.OlderThan {
+			continue
+		}
+		prunable = append(prunable, r)
+	}
+	return prunable, nil
+}
+
+// Prune deletes the runs selected by the policy, with their task runs and their log
+// directories under logsDir, then compacts the repository if it is a Compactor. With
+// dryRun set, nothing is deleted and the result describes what would be.
+func Prune(repo Repository, policy RetentionPolicy, logsDir string, dryRun bool) (*PruneResult, error) {
+	if !policy.Limited() {
+		return nil, fmt.Errorf("a retention policy needs an age or a number of runs to keep")
+	}
+
+	runs, err := SelectPrunable(repo, policy, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to select runs: %w", err)
+	}
+
+	result := &PruneResult{DryRun: dryRun}
+	for _, r := range runs {
+		tasks, err := repo.LoadTaskRunHistory(r.ID)
+		if err != nil {
+			return result, fmt.Errorf("failed to load task runs of %s: %w", r.ID, err)
+		}
+
+		pruned := PrunedRun{Run: r, TaskRuns: len(tasks)}
+		if logsDir != "" {
+			pruned.LogDir = filepath.Join(logsDir, r.ID)
+			pruned.LogBytes = dirSize(pruned.LogDir)
+		}
+
+		if !dryRun {
+			if err := repo.DeleteRun(r.ID); err != nil {
+				return result, fmt.Errorf("failed to delete run %s: %w", r.ID, err)
+			}
+			if pruned.LogDir != "" {
+				if err := os.RemoveAll(pruned.LogDir); err != nil {
+					return result, fmt.Errorf("failed to delete logs of run %s: %w", r.ID, err)
+				}
+			}
+		}
+
+		result.Runs = append(result.Runs, pruned)
+		result.TaskRuns += pruned.TaskRuns
+		result.LogBytes += pruned.LogBytes
+	}
+
+	if compactor, ok := repo.(Compactor); ok && !dryRun && len(result.Runs) > 0 {
+		reclaimed, err := compactor.Compact()
+		if err != nil {
+			return result, fmt.Errorf("failed to compact run store: %w", err)
+		}
+		result.DatabaseBytes = reclaimed
+	}
+
+	return result, nil
+}
+
+// dirSize returns the total size of the files under dir, or 0 if it does not exist.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// ParseAge parses a retention age: a Go duration such as "36h", or a whole number of
+// days or weeks such as "30d" or "2w".
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 30d, 2w or 12h)", s)
+	}
+	return d, nil
+}
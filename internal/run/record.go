@@ -0,0 +1,186 @@
+package run
+
+import (
+	"database/sql"
+	"time"
+)
+
+// runRecord is the JSON form of a WorkflowRun, with unset fields omitted.
+type runRecord struct {
+	ID           string         `json:"id"`
+	Workflow     string         `json:"workflow"`
+	WorkflowHash string         `json:"workflow_hash"`
+	Status       WorkflowStatus `json:"status"`
+	StartedAt    time.Time      `json:"started_at"`
+	EndedAt      *time.Time     `json:"ended_at,omitempty"`
+	ExitCode     *int64         `json:"exit_code,omitempty"`
+	Meta         *string        `json:"meta,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Source       *string        `json:"source,omitempty"`
+	Definition   *string        `json:"definition,omitempty"`
+	GitCommit    *string        `json:"git_commit,omitempty"`
+	GitDirty     *bool          `json:"git_dirty,omitempty"`
+	GitRev       *string        `json:"git_rev,omitempty"`
+	Selection    *string        `json:"selection,omitempty"`
+	PID          *int64         `json:"pid,omitempty"`
+	Host         *string        `json:"host,omitempty"`
+	HeartbeatAt  *time.Time     `json:"heartbeat_at,omitempty"`
+	Origin       *string        `json:"origin,omitempty"`
+	ResumedAt    *time.Time     `json:"resumed_at,omitempty"`
+}
+
+func newRunRecord(r *WorkflowRun) runRecord {
+	return runRecord{
+		ID:           r.ID,
+		Workflow:     r.Workflow,
+		WorkflowHash: r.WorkflowHash,
+		Status:       r.Status,
+		StartedAt:    r.StartedAt,
+		EndedAt:      timePtr(r.EndedAt),
+		ExitCode:     int64Ptr(r.ExitCode),
+		Meta:         stringPtr(r.Meta),
+		CreatedAt:    r.CreatedAt,
+		Source:       stringPtr(r.Source),
+		Definition:   stringPtr(r.Definition),
+		GitCommit:    stringPtr(r.GitCommit),
+		GitDirty:     boolPtr(r.GitDirty),
+		GitRev:       stringPtr(r.GitRev),
+		Selection:    stringPtr(r.Selection),
+		PID:          int64Ptr(r.PID),
+		Host:         stringPtr(r.Host),
+		HeartbeatAt:  timePtr(r.HeartbeatAt),
+		Origin:       stringPtr(r.Origin),
+		ResumedAt:    timePtr(r.ResumedAt),
+	}
+}
+
+func (r runRecord) run() *WorkflowRun {
+	return &WorkflowRun{
+		ID:           r.ID,
+		Workflow:     r.Workflow,
+		WorkflowHash: r.WorkflowHash,
+		Status:       r.Status,
+		StartedAt:    r.StartedAt,
+		EndedAt:      nullTime(r.EndedAt),
+		ExitCode:     nullInt64(r.ExitCode),
+		Meta:         nullString(r.Meta),
+		CreatedAt:    r.CreatedAt,
+		Source:       nullString(r.Source),
+		Definition:   nullString(r.Definition),
+		GitCommit:    nullString(r.GitCommit),
+		GitDirty:     nullBool(r.GitDirty),
+		GitRev:       nullString(r.GitRev),
+		Selection:    nullString(r.Selection),
+		PID:          nullInt64(r.PID),
+		Host:         nullString(r.Host),
+		HeartbeatAt:  nullTime(r.HeartbeatAt),
+		Origin:       nullString(r.Origin),
+		ResumedAt:    nullTime(r.ResumedAt),
+	}
+}
+
+// taskRecord is the JSON form of a TaskRun, with unset fields omitted.
+type taskRecord struct {
+	ID            int64      `json:"id"`
+	RunID         string     `json:"run_id"`
+	Name          string     `json:"name"`
+	Status        TaskStatus `json:"status"`
+	StartedAt     time.Time  `json:"started_at"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	Attempts      int        `json:"attempts"`
+	ExitCode      *int64     `json:"exit_code,omitempty"`
+	LogPath       string     `json:"log_path,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	Archived      bool       `json:"archived,omitempty"`
+	PriorAttempts int        `json:"prior_attempts,omitempty"`
+}
+
+func newTaskRecord(t *TaskRun) taskRecord {
+	return taskRecord{
+		ID:            t.ID,
+		RunID:         t.RunID,
+		Name:          t.Name,
+		Status:        t.Status,
+		StartedAt:     t.StartedAt,
+		EndedAt:       timePtr(t.EndedAt),
+		Attempts:      t.Attempts,
+		ExitCode:      int64Ptr(t.ExitCode),
+		LogPath:       t.LogPath,
+		LastError:     t.LastError,
+		Archived:      t.Archived,
+		PriorAttempts: t.PriorAttempts,
+	}
+}
+
+func (t taskRecord) task() *TaskRun {
+	return &TaskRun{
+		ID:            t.ID,
+		RunID:         t.RunID,
+		Name:          t.Name,
+		Status:        t.Status,
+		StartedAt:     t.StartedAt,
+		EndedAt:       nullTime(t.EndedAt),
+		Attempts:      t.Attempts,
+		ExitCode:      nullInt64(t.ExitCode),
+		LogPath:       t.LogPath,
+		LastError:     t.LastError,
+		Archived:      t.Archived,
+		PriorAttempts: t.PriorAttempts,
+	}
+}
+
+func stringPtr(n sql.NullString) *string {
+	if !n.Valid {
+		return nil
+	}
+	return &n.String
+}
+
+func nullString(p *string) sql.NullString {
+	if p == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *p, Valid: true}
+}
+
+func int64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+func nullInt64(p *int64) sql.NullInt64 {
+	if p == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *p, Valid: true}
+}
+
+func boolPtr(n sql.NullBool) *bool {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Bool
+}
+
+func nullBool(p *bool) sql.NullBool {
+	if p == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *p, Valid: true}
+}
+
+func timePtr(n sql.NullTime) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Time
+}
+
+func nullTime(p *time.Time) sql.NullTime {
+	if p == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *p, Valid: true}
+}
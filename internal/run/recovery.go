@@ -0,0 +1,173 @@
+package run
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// HeartbeatInterval is how often a process executing a run records that it is alive.
+	HeartbeatInterval = 10 * time.Second
+	// DefaultStaleAfter is how long a run may go without a heartbeat before it is considered stale.
+	DefaultStaleAfter = time.Minute
+)
+
+// claim records the current process as the one executing the run and refreshes its heartbeat.
+func (w *WorkflowRun) claim(now time.Time) {
+	w.PID = sql.NullInt64{Int64: int64(os.Getpid()), Valid: true}
+	w.Host = sql.NullString{String: hostname(), Valid: true}
+	w.HeartbeatAt = sql.NullTime{Time: now, Valid: true}
+}
+
+// LastSeen returns the last time the process executing the run was known to be alive.
+func (w *WorkflowRun) LastSeen() time.Time {
+	if w.HeartbeatAt.Valid {
+		return w.HeartbeatAt.Time
+	}
+	return w.StartedAt
+}
+
+// Stale reports whether a running run has lost the process executing it: the process no
+// longer exists on this host, or no heartbeat has been recorded for longer than staleAfter.
+// Runs recorded before heartbeats were tracked are judged by their start time.
+func (w *WorkflowRun) Stale(now time.Time, staleAfter time.Duration) bool {
+	if w.Status != StatusRunning {
+		return false
+	}
+
+	if w.PID.Valid && w.Host.String == hostname() && !processAlive(int(w.PID.Int64)) {
+		return true
+	}
+
+	return now.Sub(w.LastSeen()) > staleAfter
+}
+
+// Reopen marks an existing run running again, owned by the current process, e.g. when it
+// is resumed or rerun.
+func (s *Store) Reopen(run *WorkflowRun) error {
+	run.reopen(time.Now())
+
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(QueryUpdateWorkflowRun, run.Status, run.EndedAt, run.ExitCode, run.Meta, run.WorkflowHash, run.Definition, run.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(QueryResumeWorkflowRun, run.ResumedAt, run.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(QueryClaimWorkflowRun, run.PID, run.Host, run.HeartbeatAt, run.ID)
+		return err
+	})
+}
+
+// reopen marks the run running again from now, owned by the current process.
+func (w *WorkflowRun) reopen(now time.Time) {
+	w.Status = StatusRunning
+	w.EndedAt = sql.NullTime{}
+	w.ResumedAt = sql.NullTime{Time: now, Valid: true}
+	w.claim(now)
+}
+
+// ExecutionStart returns when the last execution of the run started: when it was last
+// resumed or rerun, or when it started if it never was.
+func (w *WorkflowRun) ExecutionStart() time.Time {
+	if w.ResumedAt.Valid && w.ResumedAt.Time.After(w.StartedAt) {
+		return w.ResumedAt.Time
+	}
+	return w.StartedAt
+}
+
+// Heartbeat records that the process executing a run is still alive.
+func (s *Store) Heartbeat(id string, at time.Time) error {
+	_, err := s.db.Exec(QueryHeartbeat, at, id)
+	return err
+}
+
+// ListStaleRuns returns the runs still marked running whose process has died or stopped
+// sending heartbeats, most recent first.
+func (s *Store) ListStaleRuns(staleAfter time.Duration) ([]*WorkflowRun, error) {
+	rows, err := s.db.Query(QueryListRunningRuns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+
+	var stale []*WorkflowRun
+	for rows.Next() {
+		run, err := scanWorkflowRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		if run.Stale(now, staleAfter) {
+			stale = append(stale, run)
+		}
+	}
+
+	return stale, rows.Err()
+}
+
+// MarkInterrupted marks a stale run and its running tasks as interrupted and releases its
+// locks, so the run can be resumed. The run is considered to have ended at its last heartbeat.
+func (s *Store) MarkInterrupted(run *WorkflowRun) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	endedAt := run.LastSeen()
+	if _, err := tx.Exec(QueryInterruptTaskRuns, endedAt, interruptReason(run), run.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM run_locks WHERE run_id = ?", run.ID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE workflow_runs SET status = ?, ended_at = ? WHERE id = ? AND status = ?", StatusInterrupted, endedAt, run.ID, StatusRunning)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errNotRunning(run.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	run.Status = StatusInterrupted
+	run.EndedAt = sql.NullTime{Time: endedAt, Valid: true}
+	return nil
+}
+
+// interruptReason returns the error recorded on the running tasks of an interrupted run.
+func interruptReason(run *WorkflowRun) string {
+	if !run.PID.Valid {
+		return "interrupted: run was left running"
+	}
+	return fmt.Sprintf("interrupted: run was left running by process %d on %s", run.PID.Int64, run.Host.String)
+}
+
+// errNotRunning is returned by MarkInterrupted when the run has already ended or been recovered.
+func errNotRunning(id string) error {
+	return fmt.Errorf("run %s is no longer running", id)
+}
+
+// errRunning is returned by DeleteRun for a run that is still running.
+func errRunning(id string) error {
+	return fmt.Errorf("run %s is running", id)
+}
+
+// hostname returns the name of this machine, or "" if it cannot be determined.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package run
+
+import (
+	"fmt"
+	"time"
+)
+
+// Repository records workflow runs, their task runs and attempts, and the run locks that
+// coordinate executors. Task output is written to log files; repositories record the
+// path of each attempt's log.
+//
+// Load and GetTaskRun return sql.ErrNoRows when nothing matches, whatever the backend.
+type Repository interface {
+	// CreateWorkflowRun stores a new WorkflowRun, assigning its ID, status and timestamps
+	// when unset. A running WorkflowRun is claimed by the current process.
+	CreateWorkflowRun(run *WorkflowRun) error
+	// Update persists the status, end time, exit code, metadata, hash and definition of a WorkflowRun.
+	Update(run *WorkflowRun) error
+	// Load retrieves a WorkflowRun by its ID.
+	Load(id string) (*WorkflowRun, error)
+	// ListRuns retrieves runs, most recent first, optionally filtered by workflow and status.
+	// A negative limit returns every run.
+	ListRuns(workflow, status string, limit, offset int) ([]*WorkflowRun, error)
+	// FindRuns retrieves the runs whose ID starts with prefix, most recent first.
+	FindRuns(prefix string) ([]*WorkflowRun, error)
+	// ListRunsWithTasks retrieves the most recent runs of a workflow that are not running,
+	// most recent first, each with its current TaskRuns. A negative limit returns every run.
+	ListRunsWithTasks(workflow string, limit int) ([]RunTasks, error)
+
+	// Reopen marks an existing run running again, owned by the current process.
+	Reopen(run *WorkflowRun) error
+	// Heartbeat records that the process executing a running run is still alive.
+	Heartbeat(id string, at time.Time) error
+	// ListStaleRuns returns the running runs whose process has died or stopped sending heartbeats.
+	ListStaleRuns(staleAfter time.Duration) ([]*WorkflowRun, error)
+	// MarkInterrupted marks a stale run and its running tasks as interrupted and releases its locks.
+	MarkInterrupted(run *WorkflowRun) error
+	// DeleteRun deletes a run that is not running, with its task runs and locks.
+	DeleteRun(id string) error
+	// ImportRun stores a run recorded elsewhere, as is, with its task runs including archived
+	// attempts. It reports false and stores nothing if a run with the same ID exists.
+	ImportRun(run *WorkflowRun, tasks []TaskRun) (bool, error)
+
+	// SaveTaskRun stores a new TaskRun and assigns its ID.
+	SaveTaskRun(task *TaskRun) error
+	// UpdateTaskRun persists changes to an existing TaskRun.
+	UpdateTaskRun(task *TaskRun) error
+	// FinishTask persists a TaskRun together with the status of its WorkflowRun, atomically.
+	FinishTask(task *TaskRun, run *WorkflowRun) error
+	// LoadTaskRuns retrieves the current TaskRuns of a WorkflowRun, in the order they were created.
+	LoadTaskRuns(runID string) ([]TaskRun, error)
+	// GetTaskRun retrieves the current TaskRun of a task.
+	GetTaskRun(runID, taskName string) (*TaskRun, error)
+	// LoadTaskRunHistory retrieves every TaskRun of a WorkflowRun, including archived attempts.
+	LoadTaskRunHistory(runID string) ([]TaskRun, error)
+	// ResetTaskRuns archives the current TaskRuns of the named tasks and replaces them with pending ones.
+	ResetTaskRuns(runID string, names []string) error
+
+	// AcquireLock takes the named lock for a run, returning a *LockHeldError if another run,
+	// or another process executing the same run, holds it.
+	AcquireLock(name, runID string, lease time.Duration) (*Lock, error)
+	// RenewLock extends the lease of a lock, returning ErrLockLost if the run no longer holds it.
+	RenewLock(name, runID string, lease time.Duration) error
+	// ReleaseLock releases a lock held by a run.
+	ReleaseLock(name, runID string) error
+	// GetLock returns the named lock, or nil if nobody holds it.
+	GetLock(name string) (*Lock, error)
+
+	// Close releases the resources held by the repository.
+	Close() error
+}
+
+// Compactor is implemented by repositories that can return the space freed by deleted
+// runs to the filesystem.
+type Compactor interface {
+	// Compact shrinks the repository's files and returns the number of bytes reclaimed.
+	Compact() (int64, error)
+}
+
+var (
+	_ Repository = (*Store)(nil)
+	_ Compactor  = (*Store)(nil)
+	_ Repository = (*MemoryRepository)(nil)
+	_ Repository = (*JSONLRepository)(nil)
+)
+
+// Backend names a Repository implementation.
+type Backend string
+
+const (
+	// BackendSQLite stores runs in a SQLite database. It is the default.
+	BackendSQLite Backend = "sqlite"
+	// BackendJSONL appends every change to a JSON-lines file.
+	BackendJSONL Backend = "jsonl"
+	// BackendMemory keeps runs in memory; nothing outlives the process.
+	BackendMemory Backend = "memory"
+)
+
+// Backends lists the supported backends.
+var Backends = []Backend{BackendSQLite, BackendJSONL, BackendMemory}
+
+// Open opens the repository for a backend. The path is the database or JSON-lines file,
+// and is ignored by the memory backend. An empty backend selects SQLite.
+func Open(backend Backend, path string) (Repository, error) {
+	switch backend {
+	case "", BackendSQLite:
+		return NewStore(path)
+	case BackendJSONL:
+		return OpenJSONL(path)
+	case BackendMemory:
+		return NewMemoryRepository(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (expected one of %v)", backend, Backends)
+	}
+}
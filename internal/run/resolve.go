@@ -0,0 +1,117 @@
+package run
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxAmbiguousMatches bounds the runs listed by an AmbiguousRunError.
+const maxAmbiguousMatches = 10
+
+// AmbiguousRunError is returned by Resolve when a run ID prefix matches several runs.
+type AmbiguousRunError struct {
+	Prefix  string
+	Matches []*WorkflowRun // Most recent first
+}
+
+func (e *AmbiguousRunError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "run ID prefix '%s' is ambiguous; it matches %d runs:", e.Prefix, len(e.Matches))
+	for i, r := range e.Matches {
+		if i == maxAmbiguousMatches {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(e.Matches)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n  %s  %s  %s  %s", r.ID, r.Workflow, r.Status, r.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+	b.WriteString("\nuse a longer prefix")
+	return b.String()
+}
+
+// Resolve finds the run a user refers to. ref is either an alias:
+//
+//	last                 the most recent run
+//	last-<status>        the most recent run with a status, e.g. last-failed
+//	<workflow>@latest    the most recent run of a workflow
+//	<workflow>@~N        the Nth run of a workflow before its most recent one
+//
+// or a run ID, or a prefix of one that matches a single run, as in git. An ambiguous
+// prefix returns an *AmbiguousRunError listing the runs it matches. Like Load, Resolve
+// returns sql.ErrNoRows when nothing matches.
+func Resolve(repo Repository, ref string) (*WorkflowRun, error) {
+	if ref == "" {
+		return nil, sql.ErrNoRows
+	}
+
+	if ref == "last" {
+		return nthRun(repo, "", "", 0)
+	}
+	if status, ok := strings.CutPrefix(ref, "last-"); ok {
+		switch WorkflowStatus(status) {
+		case StatusPending, StatusRunning, StatusSuccess, StatusFailed, StatusInterrupted:
+			return nthRun(repo, "", status, 0)
+		default:
+			return nil, fmt.Errorf("invalid run alias: unknown status %q", status)
+		}
+	}
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		workflow, n, err := parseWorkflowAlias(ref[:i], ref[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid run alias: %w", err)
+		}
+		return nthRun(repo, workflow, "", n)
+	}
+
+	run, err := repo.Load(ref)
+	if err != sql.ErrNoRows {
+		return run, err
+	}
+
+	matches, err := repo.FindRuns(ref)
+	if err != nil {
+		return nil, err
+	}
+	switch len(matches) {
+	case 0:
+		return nil, sql.ErrNoRows
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, &AmbiguousRunError{Prefix: ref, Matches: matches}
+	}
+}
+
+// parseWorkflowAlias parses the parts of a <workflow>@latest or <workflow>@~N alias,
+// returning how many runs of the workflow to skip.
+func parseWorkflowAlias(workflow, rev string) (string, int, error) {
+	if workflow == "" {
+		return "", 0, fmt.Errorf("missing workflow name before '@'")
+	}
+	if rev == "latest" {
+		return workflow, 0, nil
+	}
+	if n, ok := strings.CutPrefix(rev, "~"); ok {
+		if n == "" {
+			return workflow, 1, nil
+		}
+		count, err := strconv.Atoi(n)
+		if err == nil && count >= 0 {
+			return workflow, count, nil
+		}
+	}
+	return "", 0, fmt.Errorf("expected %s@latest or %s@~N", workflow, workflow)
+}
+
+// nthRun returns the run n places before the most recent one matching the filters.
+func nthRun(repo Repository, workflow, status string, n int) (*WorkflowRun, error) {
+	runs, err := repo.ListRuns(workflow, status, 1, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return runs[0], nil
+}
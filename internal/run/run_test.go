@@ -1,8 +1,19 @@
 package run
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // TestNewWorkflowRun tests the NewWorkflowRun method of the Store.
@@ -121,3 +132,1502 @@ func TestTaskRuns(t *testing.T) {
 		t.Errorf("expected status %s, got %s", TaskSuccess, updatedTasks[0].Status)
 	}
 }
+
+// TestCreateWorkflowRunSource tests that the definition source is persisted with the run.
+func TestCreateWorkflowRunSource(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "/test.db")
+
+	store, err := NewStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	source := "/repo/ci/build.toml"
+	run := &WorkflowRun{
+		Workflow:     "build",
+		WorkflowHash: "dag-hash",
+		Source:       sql.NullString{String: source, Valid: true},
+	}
+	if err := store.CreateWorkflowRun(run); err != nil {
+		t.Fatalf("CreateWorkflowRun failed: %v", err)
+	}
+
+	if run.ID == "" {
+		t.Error("WorkflowRun ID is empty")
+	}
+	if run.Status != StatusRunning {
+		t.Errorf("expected status Running, got %s", run.Status)
+	}
+
+	loadedRun, err := store.Load(run.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loadedRun.Source.Valid || loadedRun.Source.String != source {
+		t.Errorf("expected source %s, got %v", source, loadedRun.Source)
+	}
+}
+
+// TestResetTaskRuns tests that reset task runs are archived and replaced with pending ones.
+func TestResetTaskRuns(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "/test.db")
+
+	store, err := NewStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	run, err := store.NewWorkflowRun("test-workflow", "dag-hash")
+	if err != nil {
+		t.Fatalf("NewWorkflowRun failed: %v", err)
+	}
+
+	task := &TaskRun{
+		RunID:     run.ID,
+		Name:      "task1",
+		Status:    TaskSuccess,
+		StartedAt: time.Now(),
+		Attempts:  2,
+		LogPath:   "/logs/task1_2.log",
+	}
+	if err := store.SaveTaskRun(task); err != nil {
+		t.Fatalf("SaveTaskRun failed: %v", err)
+	}
+
+	if err := store.ResetTaskRuns(run.ID, []string{"task1"}); err != nil {
+		t.Fatalf("ResetTaskRuns failed: %v", err)
+	}
+
+	current, err := store.GetTaskRun(run.ID, "task1")
+	if err != nil {
+		t.Fatalf("GetTaskRun failed: %v", err)
+	}
+	if current.Status != TaskPending {
+		t.Errorf("expected status Pending, got %s", current.Status)
+	}
+	if current.Attempts != 2 {
+		t.Errorf("expected attempts to carry over as 2, got %d", current.Attempts)
+	}
+
+	tasks, err := store.LoadTaskRuns(run.ID)
+	if err != nil {
+		t.Fatalf("LoadTaskRuns failed: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("expected 1 current task run, got %d", len(tasks))
+	}
+
+	history, err := store.LoadTaskRunHistory(run.ID)
+	if err != nil {
+		t.Fatalf("LoadTaskRunHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 task runs in history, got %d", len(history))
+	}
+	if !history[0].Archived || history[0].LogPath != "/logs/task1_2.log" {
+		t.Errorf("expected original task run to be archived with its log, got %+v", history[0])
+	}
+	if history[1].Archived {
+		t.Error("expected reset task run not to be archived")
+	}
+}
+
+// legacySchema is the schema of databases created before migrations were versioned.
+const legacySchema = `
+CREATE TABLE workflow_runs (
+    id TEXT PRIMARY KEY,
+    workflow TEXT NOT NULL,
+    workflow_hash TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    exit_code INTEGER,
+    meta TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    source TEXT
+);
+
+CREATE TABLE task_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    exit_code INTEGER,
+    log_path TEXT,
+    last_error TEXT,
+    FOREIGN KEY (run_id) REFERENCES workflow_runs(id)
+);
+
+INSERT INTO workflow_runs (id, workflow, workflow_hash, status, started_at, source)
+VALUES ('legacy-run', 'legacy', 'hash', 'success', '2024-01-01 00:00:00', '/tmp/legacy.toml');
+
+INSERT INTO task_runs (run_id, name, status, started_at, attempts, log_path, last_error)
+VALUES ('legacy-run', 'build', 'success', '2024-01-01 00:00:00', 1, '/tmp/build.log', '');
+`
+
+// TestMigrateFreshDatabase tests that a new database is migrated to the latest schema version without a backup.
+func TestMigrateFreshDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := OpenStore(dbPath)
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if v, err := store.SchemaVersion(); err != nil || v != 0 {
+		t.Fatalf("expected version 0 before migrating, got %d (%v)", v, err)
+	}
+
+	result, err := store.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.From != 0 || result.To != LatestSchemaVersion() {
+		t.Errorf("expected migration from 0 to %d, got %d to %d", LatestSchemaVersion(), result.From, result.To)
+	}
+	if len(result.Applied) != len(migrations) {
+		t.Errorf("expected %d migrations applied, got %d", len(migrations), len(result.Applied))
+	}
+	if result.Backup != "" {
+		t.Errorf("expected no backup of a new database, got %s", result.Backup)
+	}
+
+	statuses, err := store.Migrations()
+	if err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("expected migration %d to be applied", s.Version)
+		}
+	}
+
+	// Migrating again is a no-op
+	result, err = store.Migrate()
+	if err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+	if len(result.Applied) != 0 || result.From != LatestSchemaVersion() {
+		t.Errorf("expected no migrations on second run, got %+v", result)
+	}
+}
+
+// TestMigrateLegacyDatabase tests that a database created before versioning is backed up and migrated, keeping its runs.
+func TestMigrateLegacyDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+	db.Close()
+
+	store, err := OpenStore(dbPath)
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	defer store.Close()
+
+	result, err := store.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.To != LatestSchemaVersion() {
+		t.Errorf("expected version %d, got %d", LatestSchemaVersion(), result.To)
+	}
+	if result.Backup == "" {
+		t.Fatal("expected a backup of the legacy database")
+	}
+
+	backup, err := sql.Open("sqlite", result.Backup)
+	if err != nil {
+		t.Fatalf("failed to open backup: %v", err)
+	}
+	defer backup.Close()
+	var count int
+	if err := backup.QueryRow("SELECT COUNT(*) FROM workflow_runs").Scan(&count); err != nil || count != 1 {
+		t.Errorf("expected backup to hold 1 run, got %d (%v)", count, err)
+	}
+
+	r, err := store.Load("legacy-run")
+	if err != nil {
+		t.Fatalf("Load failed after migration: %v", err)
+	}
+	if r.Source.String != "/tmp/legacy.toml" {
+		t.Errorf("expected source to survive migration, got %q", r.Source.String)
+	}
+
+	tasks, err := store.LoadTaskRuns("legacy-run")
+	if err != nil {
+		t.Fatalf("LoadTaskRuns failed after migration: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Name != "build" {
+		t.Errorf("expected task build to survive migration, got %+v", tasks)
+	}
+}
+
+// TestMigrateConcurrent tests that processes migrating the same legacy database at once
+// take turns: one backs it up and migrates it, the others find it up to date.
+func TestMigrateConcurrent(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+	db.Close()
+
+	const migrators = 5
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		applied int
+		backups []string
+		errs    []error
+	)
+	for i := 0; i < migrators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store, err := OpenStore(dbPath)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			defer store.Close()
+
+			result, err := store.Migrate()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			if result.To != LatestSchemaVersion() {
+				errs = append(errs, fmt.Errorf("migrated to version %d", result.To))
+			}
+			applied += len(result.Applied)
+			if result.Backup != "" {
+				backups = append(backups, result.Backup)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		t.Errorf("concurrent Migrate failed: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("expected %d migrations applied in total, got %d", len(migrations), applied)
+	}
+	if len(backups) != 1 {
+		t.Errorf("expected a single backup, got %v", backups)
+	}
+	if files, _ := filepath.Glob(dbPath + ".*.bak"); len(files) != 1 {
+		t.Errorf("expected a single backup file, got %v", files)
+	}
+	if _, err := os.Stat(dbPath + ".migrate.lock"); !os.IsNotExist(err) {
+		t.Errorf("expected the migration lock to be released, got %v", err)
+	}
+}
+
+// TestMigrateNewerDatabase tests that a database migrated by a newer version of wf is refused.
+func TestMigrateNewerDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	future := LatestSchemaVersion() + 1
+	if _, err := store.db.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, 'future', ?)", future, time.Now()); err != nil {
+		t.Fatalf("failed to record future version: %v", err)
+	}
+	store.Close()
+
+	if _, err := NewStore(dbPath); err == nil {
+		t.Fatal("expected NewStore to refuse a newer schema version")
+	}
+}
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int64 {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot start a process: %v", err)
+	}
+	return int64(cmd.ProcessState.Pid())
+}
+
+// TestStaleRuns tests that running runs are stale once their process dies or their heartbeat lapses.
+func TestStaleRuns(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := NewStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	host, _ := os.Hostname()
+	runs := map[string]*WorkflowRun{
+		// Claimed by this process when created
+		"alive": {ID: "alive", Workflow: "wf", WorkflowHash: "h"},
+		"dead": {ID: "dead", Workflow: "wf", WorkflowHash: "h",
+			PID:         sql.NullInt64{Int64: deadPID(t), Valid: true},
+			Host:        sql.NullString{String: host, Valid: true},
+			HeartbeatAt: sql.NullTime{Time: now, Valid: true}},
+		"remote": {ID: "remote", Workflow: "wf", WorkflowHash: "h",
+			PID:         sql.NullInt64{Int64: 1, Valid: true},
+			Host:        sql.NullString{String: "elsewhere", Valid: true},
+			HeartbeatAt: sql.NullTime{Time: now, Valid: true}},
+		"silent": {ID: "silent", Workflow: "wf", WorkflowHash: "h",
+			PID:         sql.NullInt64{Int64: 1, Valid: true},
+			Host:        sql.NullString{String: "elsewhere", Valid: true},
+			HeartbeatAt: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}},
+		"finished": {ID: "finished", Workflow: "wf", WorkflowHash: "h", Status: StatusFailed},
+	}
+	for _, r := range runs {
+		if err := store.CreateWorkflowRun(r); err != nil {
+			t.Fatalf("CreateWorkflowRun failed: %v", err)
+		}
+	}
+
+	if runs["alive"].PID.Int64 != int64(os.Getpid()) || runs["alive"].Host.String != host {
+		t.Errorf("expected new run to be claimed by this process, got pid %d on %q", runs["alive"].PID.Int64, runs["alive"].Host.String)
+	}
+
+	stale, err := store.ListStaleRuns(time.Minute)
+	if err != nil {
+		t.Fatalf("ListStaleRuns failed: %v", err)
+	}
+	got := map[string]bool{}
+	for _, r := range stale {
+		got[r.ID] = true
+	}
+	for id, want := range map[string]bool{"alive": false, "dead": true, "remote": false, "silent": true, "finished": false} {
+		if got[id] != want {
+			t.Errorf("run %s: expected stale=%v, got %v", id, want, got[id])
+		}
+	}
+
+	// A heartbeat brings a silent run back
+	if err := store.Heartbeat("silent", now); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	silent, err := store.Load("silent")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if silent.Stale(now, time.Minute) {
+		t.Error("expected run with a fresh heartbeat not to be stale")
+	}
+}
+
+// TestMarkInterrupted tests that marking a run interrupted updates the run and its running tasks.
+func TestMarkInterrupted(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := NewStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	wr, err := store.NewWorkflowRun("wf", "h")
+	if err != nil {
+		t.Fatalf("NewWorkflowRun failed: %v", err)
+	}
+	for name, status := range map[string]TaskStatus{"done": TaskSuccess, "busy": TaskRunning} {
+		if err := store.SaveTaskRun(&TaskRun{RunID: wr.ID, Name: name, Status: status, StartedAt: time.Now(), Attempts: 1}); err != nil {
+			t.Fatalf("SaveTaskRun failed: %v", err)
+		}
+	}
+
+	if err := store.MarkInterrupted(wr); err != nil {
+		t.Fatalf("MarkInterrupted failed: %v", err)
+	}
+
+	loaded, err := store.Load(wr.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Status != StatusInterrupted || !loaded.EndedAt.Valid {
+		t.Errorf("expected interrupted run with an end time, got %s (ended %v)", loaded.Status, loaded.EndedAt.Valid)
+	}
+
+	busy, err := store.GetTaskRun(wr.ID, "busy")
+	if err != nil {
+		t.Fatalf("GetTaskRun failed: %v", err)
+	}
+	if busy.Status != TaskInterrupted || busy.LastError == "" {
+		t.Errorf("expected running task to be interrupted with a reason, got %s %q", busy.Status, busy.LastError)
+	}
+	done, err := store.GetTaskRun(wr.ID, "done")
+	if err != nil {
+		t.Fatalf("GetTaskRun failed: %v", err)
+	}
+	if done.Status != TaskSuccess {
+		t.Errorf("expected completed task to stay successful, got %s", done.Status)
+	}
+
+	if err := store.MarkInterrupted(wr); err == nil {
+		t.Error("expected error marking a run that is no longer running")
+	}
+}
+
+// TestLocks tests acquiring, renewing, releasing and taking over run locks.
+func TestLocks(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := NewStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	name := WorkflowLockName("nightly")
+	lock, err := store.AcquireLock(name, "first", time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	if lock.PID != int64(os.Getpid()) {
+		t.Errorf("expected lock to be owned by pid %d, got %d", os.Getpid(), lock.PID)
+	}
+
+	// Another run is refused and told who holds the lock
+	_, err = store.AcquireLock(name, "second", time.Minute)
+	var held *LockHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("expected LockHeldError, got %v", err)
+	}
+	if held.Lock.RunID != "first" || !strings.Contains(err.Error(), "run first") {
+		t.Errorf("expected error to name the holding run, got %v", err)
+	}
+
+	// The holder may acquire again and renew
+	if _, err := store.AcquireLock(name, "first", time.Minute); err != nil {
+		t.Errorf("expected holder to reacquire its lock, got %v", err)
+	}
+	if err := store.RenewLock(name, "first", time.Minute); err != nil {
+		t.Errorf("RenewLock failed: %v", err)
+	}
+	if err := store.RenewLock(name, "second", time.Minute); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost renewing a lock not held, got %v", err)
+	}
+
+	// The same run executed by another live process, e.g. a second wf resume, is refused
+	other := Lock{Name: RunLockName("first"), RunID: "first", PID: 1, Host: "other-host", AcquiredAt: time.Now(), ExpiresAt: time.Now().Add(time.Minute)}
+	if _, err := store.db.Exec(QueryAcquireLock, other.Name, other.RunID, other.PID, other.Host, other.AcquiredAt, other.ExpiresAt); err != nil {
+		t.Fatalf("failed to insert lock: %v", err)
+	}
+	if _, err := store.AcquireLock(other.Name, "first", time.Minute); !errors.As(err, &held) || held.Lock.Host != "other-host" {
+		t.Errorf("expected LockHeldError for a lock held by another process, got %v", err)
+	}
+	if err := store.RenewLock(other.Name, "first", time.Minute); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost renewing a lock held by another process, got %v", err)
+	}
+	if err := store.ReleaseLock(other.Name, "first"); err != nil {
+		t.Fatalf("ReleaseLock failed: %v", err)
+	}
+	if l, err := store.GetLock(other.Name); err != nil || l == nil || l.Host != "other-host" {
+		t.Errorf("expected a lock held by another process to survive release, got %+v (%v)", l, err)
+	}
+
+	// Releasing frees the lock for the next run
+	if err := store.ReleaseLock(name, "first"); err != nil {
+		t.Fatalf("ReleaseLock failed: %v", err)
+	}
+	if l, err := store.GetLock(name); err != nil || l != nil {
+		t.Fatalf("expected lock to be released, got %+v (%v)", l, err)
+	}
+
+	// An expired lease is taken over
+	if _, err := store.AcquireLock(name, "second", -time.Second); err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	if _, err := store.AcquireLock(name, "third", time.Minute); err != nil {
+		t.Fatalf("expected expired lock to be taken over, got %v", err)
+	}
+	if err := store.RenewLock(name, "second", time.Minute); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost after takeover, got %v", err)
+	}
+}
+
+// TestStoreConcurrentAccess stresses the store with writers and readers using separate
+// connections to the same database, as concurrent wf processes do.
+func TestStoreConcurrentAccess(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	var mode string
+	if err := store.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("expected WAL journal mode, got %q (%v)", mode, err)
+	}
+
+	const (
+		writers       = 4
+		readers       = 4
+		runsPerWriter = 10
+	)
+
+	errs := make(chan error, writers+readers)
+	done := make(chan struct{})
+
+	var writersWG, readersWG sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writersWG.Add(1)
+		go func(w int) {
+			defer writersWG.Done()
+			errs <- func() error {
+				s, err := NewStore(dbPath)
+				if err != nil {
+					return err
+				}
+				defer s.Close()
+
+				for i := 0; i < runsPerWriter; i++ {
+					wr, err := s.NewWorkflowRun(fmt.Sprintf("writer-%d", w), "hash")
+					if err != nil {
+						return fmt.Errorf("NewWorkflowRun: %w", err)
+					}
+					tr := &TaskRun{RunID: wr.ID, Name: "task", Status: TaskRunning, StartedAt: time.Now()}
+					if err := s.SaveTaskRun(tr); err != nil {
+						return fmt.Errorf("SaveTaskRun: %w", err)
+					}
+					if err := s.Heartbeat(wr.ID, time.Now()); err != nil {
+						return fmt.Errorf("Heartbeat: %w", err)
+					}
+					tr.Attempts = 1
+					if err := s.UpdateTaskRun(tr); err != nil {
+						return fmt.Errorf("UpdateTaskRun: %w", err)
+					}
+
+					now := time.Now()
+					tr.Status, tr.EndedAt = TaskFailed, sql.NullTime{Time: now, Valid: true}
+					wr.Status, wr.EndedAt = StatusFailed, sql.NullTime{Time: now, Valid: true}
+					if err := s.FinishTask(tr, wr); err != nil {
+						return fmt.Errorf("FinishTask: %w", err)
+					}
+				}
+				return nil
+			}()
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			errs <- func() error {
+				s, err := NewStore(dbPath)
+				if err != nil {
+					return err
+				}
+				defer s.Close()
+
+				for {
+					select {
+					case <-done:
+						return nil
+					default:
+					}
+
+					runs, err := s.ListRuns("", "", 100, 0)
+					if err != nil {
+						return fmt.Errorf("ListRuns: %w", err)
+					}
+					for _, wr := range runs {
+						if _, err := s.LoadTaskRuns(wr.ID); err != nil {
+							return fmt.Errorf("LoadTaskRuns: %w", err)
+						}
+					}
+				}
+			}()
+		}()
+	}
+
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	runs, err := store.ListRuns("", "", 1000, 0)
+	if err != nil {
+		t.Fatalf("ListRuns failed: %v", err)
+	}
+	if len(runs) != writers*runsPerWriter {
+		t.Fatalf("expected %d runs, got %d", writers*runsPerWriter, len(runs))
+	}
+	for _, wr := range runs {
+		if wr.Status != StatusFailed {
+			t.Errorf("run %s: expected status %s, got %s", wr.ID, StatusFailed, wr.Status)
+		}
+		tasks, err := store.LoadTaskRuns(wr.ID)
+		if err != nil {
+			t.Fatalf("LoadTaskRuns failed: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].Status != TaskFailed {
+			t.Errorf("run %s: expected one failed task, got %+v", wr.ID, tasks)
+		}
+	}
+}
+
+// repositoryBackends opens an empty repository of each backend.
+var repositoryBackends = map[Backend]func(t *testing.T) Repository{
+	BackendSQLite: func(t *testing.T) Repository {
+		store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("NewStore failed: %v", err)
+		}
+		return store
+	},
+	BackendJSONL: func(t *testing.T) Repository {
+		repo, err := OpenJSONL(filepath.Join(t.TempDir(), "runs.jsonl"))
+		if err != nil {
+			t.Fatalf("OpenJSONL failed: %v", err)
+		}
+		return repo
+	},
+	BackendMemory: func(t *testing.T) Repository {
+		return NewMemoryRepository()
+	},
+}
+
+// TestRepositories checks that every backend behaves like the SQLite store.
+func TestRepositories(t *testing.T) {
+	for backend, open := range repositoryBackends {
+		t.Run(string(backend), func(t *testing.T) {
+			repo := open(t)
+			defer repo.Close()
+
+			// Runs
+			base := time.Now().Add(-time.Hour)
+			var ids []string
+			for i, wf := range []string{"build", "deploy", "build"} {
+				wr := &WorkflowRun{
+					Workflow:     wf,
+					WorkflowHash: "hash",
+					Status:       StatusSuccess,
+					CreatedAt:    base.Add(time.Duration(i) * time.Minute),
+					Source:       sql.NullString{String: "/wf/" + wf + ".toml", Valid: true},
+				}
+				if err := repo.CreateWorkflowRun(wr); err != nil {
+					t.Fatalf("CreateWorkflowRun failed: %v", err)
+				}
+				ids = append(ids, wr.ID)
+			}
+
+			runs, err := repo.ListRuns("build", "", 10, 0)
+			if err != nil {
+				t.Fatalf("ListRuns failed: %v", err)
+			}
+			if len(runs) != 2 || runs[0].ID != ids[2] || runs[1].ID != ids[0] {
+				t.Fatalf("expected build runs newest first, got %v", runIDs(runs))
+			}
+			if runs, _ := repo.ListRuns("", "", 1, 1); len(runs) != 1 || runs[0].ID != ids[1] {
+				t.Errorf("expected the second newest run with limit 1 offset 1, got %v", runIDs(runs))
+			}
+			if runs, _ := repo.ListRuns("", string(StatusFailed), 10, 0); len(runs) != 0 {
+				t.Errorf("expected no failed runs, got %v", runIDs(runs))
+			}
+
+			wr, err := repo.Load(ids[0])
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			wr.Status = StatusFailed
+			wr.ExitCode = sql.NullInt64{Int64: 1, Valid: true}
+			wr.Source = sql.NullString{String: "changed", Valid: true}
+			if err := repo.Update(wr); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			loaded, err := repo.Load(ids[0])
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if loaded.Status != StatusFailed || loaded.ExitCode.Int64 != 1 {
+				t.Errorf("expected failed run with exit code 1, got %s %v", loaded.Status, loaded.ExitCode)
+			}
+			if loaded.Source.String != "/wf/build.toml" {
+				t.Errorf("Update should not change the source, got %q", loaded.Source.String)
+			}
+			if _, err := repo.Load("missing"); err != sql.ErrNoRows {
+				t.Errorf("expected sql.ErrNoRows for a missing run, got %v", err)
+			}
+
+			// Tasks and attempts
+			task := &TaskRun{RunID: wr.ID, Name: "compile", Status: TaskRunning, StartedAt: time.Now(), Attempts: 1}
+			if err := repo.SaveTaskRun(task); err != nil {
+				t.Fatalf("SaveTaskRun failed: %v", err)
+			}
+			other := &TaskRun{RunID: wr.ID, Name: "test", Status: TaskPending, StartedAt: time.Now()}
+			if err := repo.SaveTaskRun(other); err != nil {
+				t.Fatalf("SaveTaskRun failed: %v", err)
+			}
+			if task.ID == 0 || other.ID <= task.ID {
+				t.Fatalf("expected increasing task IDs, got %d and %d", task.ID, other.ID)
+			}
+
+			task.Status = TaskFailed
+			task.LogPath = "/logs/compile.1.log"
+			task.LastError = "exit status 2"
+			wr.Status = StatusFailed
+			if err := repo.FinishTask(task, wr); err != nil {
+				t.Fatalf("FinishTask failed: %v", err)
+			}
+			got, err := repo.GetTaskRun(wr.ID, "compile")
+			if err != nil {
+				t.Fatalf("GetTaskRun failed: %v", err)
+			}
+			if got.Status != TaskFailed || got.LogPath != task.LogPath || got.LastError != task.LastError {
+				t.Errorf("unexpected task run after FinishTask: %+v", got)
+			}
+			if _, err := repo.GetTaskRun(wr.ID, "missing"); err != sql.ErrNoRows {
+				t.Errorf("expected sql.ErrNoRows for a missing task, got %v", err)
+			}
+
+			if err := repo.ResetTaskRuns(wr.ID, []string{"compile"}); err != nil {
+				t.Fatalf("ResetTaskRuns failed: %v", err)
+			}
+			tasks, err := repo.LoadTaskRuns(wr.ID)
+			if err != nil {
+				t.Fatalf("LoadTaskRuns failed: %v", err)
+			}
+			if len(tasks) != 2 || tasks[0].Name != "test" || tasks[1].Name != "compile" || tasks[1].Status != TaskPending || tasks[1].Attempts != 1 {
+				t.Errorf("expected test then a pending compile carrying its attempts, got %+v", tasks)
+			}
+			history, err := repo.LoadTaskRunHistory(wr.ID)
+			if err != nil {
+				t.Fatalf("LoadTaskRunHistory failed: %v", err)
+			}
+			if len(history) != 3 || !history[0].Archived || history[0].LogPath != task.LogPath {
+				t.Errorf("expected the failed attempt archived first in the history, got %+v", history)
+			}
+
+			// Recovery
+			if err := repo.Reopen(wr); err != nil {
+				t.Fatalf("Reopen failed: %v", err)
+			}
+			loaded, _ = repo.Load(wr.ID)
+			if loaded.Status != StatusRunning || loaded.EndedAt.Valid || loaded.PID.Int64 != int64(os.Getpid()) {
+				t.Errorf("expected the run reopened by this process, got %+v", loaded)
+			}
+
+			beat := time.Now().Add(-time.Hour)
+			if err := repo.Heartbeat(wr.ID, beat); err != nil {
+				t.Fatalf("Heartbeat failed: %v", err)
+			}
+			stale, err := repo.ListStaleRuns(time.Minute)
+			if err != nil {
+				t.Fatalf("ListStaleRuns failed: %v", err)
+			}
+			if len(stale) != 1 || stale[0].ID != wr.ID {
+				t.Fatalf("expected the run to be stale, got %v", runIDs(stale))
+			}
+
+			running := &TaskRun{RunID: wr.ID, Name: "compile", Status: TaskRunning, StartedAt: time.Now()}
+			current, _ := repo.GetTaskRun(wr.ID, "compile")
+			running.ID = current.ID
+			if err := repo.UpdateTaskRun(running); err != nil {
+				t.Fatalf("UpdateTaskRun failed: %v", err)
+			}
+			if _, err := repo.AcquireLock(RunLockName(wr.ID), wr.ID, LockLease); err != nil {
+				t.Fatalf("AcquireLock failed: %v", err)
+			}
+
+			if err := repo.MarkInterrupted(stale[0]); err != nil {
+				t.Fatalf("MarkInterrupted failed: %v", err)
+			}
+			if err := repo.MarkInterrupted(stale[0]); err == nil {
+				t.Error("expected MarkInterrupted to fail for a run that is no longer running")
+			}
+			loaded, _ = repo.Load(wr.ID)
+			if loaded.Status != StatusInterrupted || !loaded.EndedAt.Time.Equal(beat) {
+				t.Errorf("expected the run interrupted at its last heartbeat, got %s %v", loaded.Status, loaded.EndedAt)
+			}
+			current, _ = repo.GetTaskRun(wr.ID, "compile")
+			if current.Status != TaskInterrupted || !strings.HasPrefix(current.LastError, "interrupted") {
+				t.Errorf("expected the running task interrupted, got %s %q", current.Status, current.LastError)
+			}
+			if lock, _ := repo.GetLock(RunLockName(wr.ID)); lock != nil {
+				t.Errorf("expected the run's locks released, got %+v", lock)
+			}
+
+			// Locks
+			name := WorkflowLockName("build")
+			if _, err := repo.AcquireLock(name, "a", LockLease); err != nil {
+				t.Fatalf("AcquireLock failed: %v", err)
+			}
+			var held *LockHeldError
+			if _, err := repo.AcquireLock(name, "b", LockLease); !errors.As(err, &held) || held.Lock.RunID != "a" {
+				t.Errorf("expected the lock to be held by run a, got %v", err)
+			}
+			if err := repo.RenewLock(name, "b", LockLease); !errors.Is(err, ErrLockLost) {
+				t.Errorf("expected ErrLockLost renewing another run's lock, got %v", err)
+			}
+			if err := repo.RenewLock(name, "a", LockLease); err != nil {
+				t.Errorf("RenewLock failed: %v", err)
+			}
+			if err := repo.ReleaseLock(name, "b"); err != nil {
+				t.Errorf("ReleaseLock failed: %v", err)
+			}
+			if lock, _ := repo.GetLock(name); lock == nil || lock.RunID != "a" {
+				t.Errorf("releasing another run's lock should be a no-op, got %+v", lock)
+			}
+			if err := repo.ReleaseLock(name, "a"); err != nil {
+				t.Errorf("ReleaseLock failed: %v", err)
+			}
+			if _, err := repo.AcquireLock(name, "b", LockLease); err != nil {
+				t.Errorf("expected run b to take the released lock, got %v", err)
+			}
+		})
+	}
+}
+
+// TestJSONLReplay tests that a JSON-lines repository is rebuilt from its file and sees the
+// changes appended by other processes.
+func TestJSONLReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+
+	first, err := OpenJSONL(path)
+	if err != nil {
+		t.Fatalf("OpenJSONL failed: %v", err)
+	}
+	defer first.Close()
+
+	wr := &WorkflowRun{Workflow: "build", WorkflowHash: "hash"}
+	if err := first.CreateWorkflowRun(wr); err != nil {
+		t.Fatalf("CreateWorkflowRun failed: %v", err)
+	}
+	if _, err := first.AcquireLock(WorkflowLockName("build"), wr.ID, LockLease); err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+
+	// A writer that crashed mid-line leaves an incomplete line behind.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2024-01-01T00:00:00Z","runs":[{"id":`)
+	f.Close()
+
+	second, err := OpenJSONL(path)
+	if err != nil {
+		t.Fatalf("OpenJSONL failed: %v", err)
+	}
+	defer second.Close()
+
+	loaded, err := second.Load(wr.ID)
+	if err != nil {
+		t.Fatalf("Load from a reopened journal failed: %v", err)
+	}
+	if loaded.Workflow != "build" || loaded.Status != StatusRunning || loaded.PID.Int64 != int64(os.Getpid()) {
+		t.Errorf("unexpected replayed run: %+v", loaded)
+	}
+
+	var held *LockHeldError
+	if _, err := second.AcquireLock(WorkflowLockName("build"), "other", LockLease); !errors.As(err, &held) {
+		t.Errorf("expected the replayed lock to be held, got %v", err)
+	}
+
+	task := &TaskRun{RunID: wr.ID, Name: "compile", Status: TaskSuccess, StartedAt: time.Now()}
+	if err := second.SaveTaskRun(task); err != nil {
+		t.Fatalf("SaveTaskRun failed: %v", err)
+	}
+	tasks, err := first.LoadTaskRuns(wr.ID)
+	if err != nil {
+		t.Fatalf("LoadTaskRuns failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != task.ID {
+		t.Errorf("expected the first repository to see the task saved by the second, got %+v", tasks)
+	}
+
+	other := &TaskRun{RunID: wr.ID, Name: "test", Status: TaskPending, StartedAt: time.Now()}
+	if err := first.SaveTaskRun(other); err != nil {
+		t.Fatalf("SaveTaskRun failed: %v", err)
+	}
+	if other.ID == task.ID {
+		t.Errorf("expected distinct task IDs across repositories, got %d twice", other.ID)
+	}
+}
+
+// runIDs returns the IDs of runs, for failure messages.
+func runIDs(runs []*WorkflowRun) []string {
+	var ids []string
+	for _, r := range runs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// TestPrune tests that a retention policy deletes the selected runs with their task runs
+// and log directories, on every backend.
+func TestPrune(t *testing.T) {
+	for backend, open := range repositoryBackends {
+		t.Run(string(backend), func(t *testing.T) {
+			repo := open(t)
+			defer repo.Close()
+			logsDir := t.TempDir()
+
+			// Five etl runs a day apart, oldest first, alternating success and failure,
+			// one deploy run, and a running etl run.
+			now := time.Now()
+			var etl []string
+			for i := 0; i < 5; i++ {
+				status := StatusSuccess
+				if i%2 == 1 {
+					status = StatusFailed
+				}
+				wr := &WorkflowRun{Workflow: "etl", WorkflowHash: "hash", Status: status, CreatedAt: now.Add(-time.Duration(5-i) * 24 * time.Hour)}
+				if err := repo.CreateWorkflowRun(wr); err != nil {
+					t.Fatalf("CreateWorkflowRun failed: %v", err)
+				}
+				if err := repo.SaveTaskRun(&TaskRun{RunID: wr.ID, Name: "extract", Status: TaskSuccess, StartedAt: wr.CreatedAt}); err != nil {
+					t.Fatalf("SaveTaskRun failed: %v", err)
+				}
+				dir := filepath.Join(logsDir, wr.ID)
+				os.MkdirAll(dir, 0755)
+				os.WriteFile(filepath.Join(dir, "extract_1.log"), []byte("0123456789"), 0644)
+				etl = append(etl, wr.ID)
+			}
+			deploy := &WorkflowRun{Workflow: "deploy", WorkflowHash: "hash", Status: StatusSuccess, CreatedAt: now.Add(-10 * 24 * time.Hour)}
+			running := &WorkflowRun{Workflow: "etl", WorkflowHash: "hash", CreatedAt: now.Add(-20 * 24 * time.Hour)}
+			for _, wr := range []*WorkflowRun{deploy, running} {
+				if err := repo.CreateWorkflowRun(wr); err != nil {
+					t.Fatalf("CreateWorkflowRun failed: %v", err)
+				}
+			}
+
+			// Keep the last successful etl run, and prune those older than 36 hours: the
+			// successes from five and three days ago go.
+			policy := RetentionPolicy{OlderThan: 36 * time.Hour, KeepLast: 1, Status: StatusSuccess, Workflow: "etl"}
+			dry, err := Prune(repo, policy, logsDir, true)
+			if err != nil {
+				t.Fatalf("Prune dry run failed: %v", err)
+			}
+			if len(dry.Runs) != 2 || dry.Runs[0].Run.ID != etl[2] || dry.Runs[1].Run.ID != etl[0] || dry.LogBytes != 20 || dry.TaskRuns != 2 {
+				t.Fatalf("expected the two older etl successes to be selected, got %+v", dry)
+			}
+			if _, err := repo.Load(etl[0]); err != nil {
+				t.Fatalf("dry run deleted a run: %v", err)
+			}
+
+			result, err := Prune(repo, policy, logsDir, false)
+			if err != nil {
+				t.Fatalf("Prune failed: %v", err)
+			}
+			if len(result.Runs) != 2 || result.LogBytes != 20 {
+				t.Fatalf("expected two pruned runs with 20 bytes of logs, got %+v", result)
+			}
+			if _, err := repo.Load(etl[0]); err != sql.ErrNoRows {
+				t.Errorf("expected the pruned run to be deleted, got %v", err)
+			}
+			if history, _ := repo.LoadTaskRunHistory(etl[0]); len(history) != 0 {
+				t.Errorf("expected the pruned run's task runs to be deleted, got %d", len(history))
+			}
+			if _, err := os.Stat(filepath.Join(logsDir, etl[0])); !os.IsNotExist(err) {
+				t.Errorf("expected the pruned run's logs to be deleted, got %v", err)
+			}
+
+			// Keep the last two runs of each workflow: the oldest remaining etl run goes,
+			// the deploy run and the running run stay.
+			result, err = Prune(repo, RetentionPolicy{KeepLast: 2}, logsDir, false)
+			if err != nil {
+				t.Fatalf("Prune failed: %v", err)
+			}
+			var pruned []string
+			for _, p := range result.Runs {
+				pruned = append(pruned, p.Run.ID)
+			}
+			if len(pruned) != 1 || pruned[0] != etl[1] {
+				t.Errorf("expected etl run %s to be pruned, got %v", etl[1], pruned)
+			}
+			remaining, _ := repo.ListRuns("", "", -1, 0)
+			if len(remaining) != 4 {
+				t.Errorf("expected 4 runs left, got %v", runIDs(remaining))
+			}
+
+			if err := repo.DeleteRun(running.ID); err == nil {
+				t.Error("expected DeleteRun to refuse a running run")
+			}
+			if _, err := Prune(repo, RetentionPolicy{Status: StatusSuccess}, logsDir, false); err == nil {
+				t.Error("expected a policy without limits to be refused")
+			}
+		})
+	}
+}
+
+// TestParseAge tests parsing retention ages.
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for input, want := range tests {
+		got, err := ParseAge(input)
+		if err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "d", "-1d", "1.5d", "soon", "-2h"} {
+		if _, err := ParseAge(input); err == nil {
+			t.Errorf("ParseAge(%q) should fail", input)
+		}
+	}
+}
+
+// TestResolve tests resolving run ID prefixes and aliases on every backend.
+func TestResolve(t *testing.T) {
+	for backend, open := range repositoryBackends {
+		t.Run(string(backend), func(t *testing.T) {
+			repo := open(t)
+			defer repo.Close()
+
+			// Oldest first; "abd" is also a prefix of "abd333".
+			now := time.Now()
+			for i, wr := range []*WorkflowRun{
+				{ID: "abc111", Workflow: "etl", Status: StatusSuccess},
+				{ID: "abc222", Workflow: "etl", Status: StatusFailed},
+				{ID: "abd333", Workflow: "deploy", Status: StatusSuccess},
+				{ID: "abd", Workflow: "deploy", Status: StatusSuccess},
+			} {
+				wr.WorkflowHash = "hash"
+				wr.CreatedAt = now.Add(time.Duration(i-4) * time.Hour)
+				if err := repo.CreateWorkflowRun(wr); err != nil {
+					t.Fatalf("CreateWorkflowRun failed: %v", err)
+				}
+			}
+
+			for ref, want := range map[string]string{
+				"abc111":      "abc111",
+				"abc2":        "abc222",
+				"abd":         "abd",
+				"abd3":        "abd333",
+				"last":        "abd",
+				"last-failed": "abc222",
+				"etl@latest":  "abc222",
+				"etl@~0":      "abc222",
+				"etl@~":       "abc111",
+				"etl@~1":      "abc111",
+				"deploy@~1":   "abd333",
+			} {
+				wr, err := Resolve(repo, ref)
+				if err != nil {
+					t.Errorf("Resolve(%q) failed: %v", ref, err)
+					continue
+				}
+				if wr.ID != want {
+					t.Errorf("Resolve(%q) = %s, want %s", ref, wr.ID, want)
+				}
+			}
+
+			for _, ref := range []string{"", "zzz", "last-interrupted", "etl@~2", "build@latest"} {
+				if _, err := Resolve(repo, ref); err != sql.ErrNoRows {
+					t.Errorf("Resolve(%q) = %v, want sql.ErrNoRows", ref, err)
+				}
+			}
+
+			for _, ref := range []string{"last-bogus", "etl@head", "etl@~-1", "@latest"} {
+				if _, err := Resolve(repo, ref); err == nil || err == sql.ErrNoRows {
+					t.Errorf("Resolve(%q) = %v, want an invalid alias error", ref, err)
+				}
+			}
+
+			_, err := Resolve(repo, "abc")
+			var ambiguous *AmbiguousRunError
+			if !errors.As(err, &ambiguous) {
+				t.Fatalf("expected an AmbiguousRunError, got %v", err)
+			}
+			if got := strings.Join(runIDs(ambiguous.Matches), " "); got != "abc222 abc111" {
+				t.Errorf("expected matches abc222 abc111, got %s", got)
+			}
+			if !strings.Contains(err.Error(), "abc111") || !strings.Contains(err.Error(), "abc222") {
+				t.Errorf("expected the ambiguity error to list the matches, got %q", err)
+			}
+		})
+	}
+}
+
+// TestStats tests the statistics of a workflow's recent runs on every backend.
+func TestStats(t *testing.T) {
+	for backend, open := range repositoryBackends {
+		t.Run(string(backend), func(t *testing.T) {
+			repo := open(t)
+			defer repo.Close()
+
+			// Four etl runs an hour apart, oldest first, lasting 1 to 4 seconds; the third
+			// fails after three attempts of load. A running etl run and a deploy run are ignored.
+			base := time.Date(2026, 9, 1, 10, 0, 0, 0, time.Local)
+			for i, status := range []WorkflowStatus{StatusSuccess, StatusSuccess, StatusFailed, StatusSuccess} {
+				start := base.Add(time.Duration(i) * time.Hour)
+				wr := &WorkflowRun{Workflow: "etl", WorkflowHash: "hash", Status: status, StartedAt: start, CreatedAt: start}
+				if err := repo.CreateWorkflowRun(wr); err != nil {
+					t.Fatalf("CreateWorkflowRun failed: %v", err)
+				}
+				wr.EndedAt = sql.NullTime{Time: start.Add(time.Duration(i+1) * time.Second), Valid: true}
+				if err := repo.Update(wr); err != nil {
+					t.Fatalf("Update failed: %v", err)
+				}
+
+				load := &TaskRun{RunID: wr.ID, Name: "load", Status: TaskSuccess, StartedAt: start, Attempts: 1,
+					EndedAt: sql.NullTime{Time: start.Add(time.Duration(i+1) * 100 * time.Millisecond), Valid: true}}
+				if status == StatusFailed {
+					load.Status, load.Attempts = TaskFailed, 3
+				}
+				report := &TaskRun{RunID: wr.ID, Name: "report", Status: TaskPending, StartedAt: start}
+				for _, task := range []*TaskRun{load, report} {
+					if err := repo.SaveTaskRun(task); err != nil {
+						t.Fatalf("SaveTaskRun failed: %v", err)
+					}
+				}
+			}
+			for _, wr := range []*WorkflowRun{
+				{Workflow: "etl", WorkflowHash: "hash", CreatedAt: base.Add(5 * time.Hour)},
+				{Workflow: "deploy", WorkflowHash: "hash", Status: StatusSuccess, CreatedAt: base},
+			} {
+				if err := repo.CreateWorkflowRun(wr); err != nil {
+					t.Fatalf("CreateWorkflowRun failed: %v", err)
+				}
+			}
+
+			stats, err := Stats(repo, "etl", -1)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			if stats.Runs != 4 || stats.Succeeded != 3 || stats.Failed != 1 || stats.SuccessRate() != 0.75 {
+				t.Errorf("unexpected run counts: %+v", stats)
+			}
+			if !stats.First.Equal(base) || !stats.Last.Equal(base.Add(3*time.Hour)) {
+				t.Errorf("expected runs from %s to %s, got %s to %s", base, base.Add(3*time.Hour), stats.First, stats.Last)
+			}
+			if len(stats.Days) != 1 || stats.Days[0].Date != "2026-09-01" || stats.Days[0].Runs != 4 || stats.Days[0].Succeeded != 3 {
+				t.Errorf("unexpected days: %+v", stats.Days)
+			}
+			if len(stats.Durations) != 4 || stats.Durations[0].Duration != time.Second || stats.Durations[3].Duration != 4*time.Second {
+				t.Errorf("expected run durations of 1s to 4s, oldest first, got %+v", stats.Durations)
+			}
+
+			// report never started, so only load has statistics
+			if len(stats.Tasks) != 1 {
+				t.Fatalf("expected statistics of one task, got %d", len(stats.Tasks))
+			}
+			load := stats.Tasks[0]
+			if load.Name != "load" || load.Runs != 4 || load.Failed != 1 || load.Retries != 2 || load.Retried != 1 {
+				t.Errorf("unexpected task statistics: %+v", load)
+			}
+			if load.Percentile(50) != 200*time.Millisecond || load.Percentile(95) != 400*time.Millisecond || load.Max() != 400*time.Millisecond {
+				t.Errorf("unexpected task durations: p50 %s, p95 %s, max %s", load.Percentile(50), load.Percentile(95), load.Max())
+			}
+			if flaky := stats.Flakiest(5); len(flaky) != 1 || flaky[0].Name != "load" {
+				t.Errorf("expected load to be the only flaky task, got %v", flaky)
+			}
+
+			// A limit keeps the most recent runs
+			recent, err := Stats(repo, "etl", 2)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			if recent.Runs != 2 || recent.Failed != 1 || recent.Tasks[0].Runs != 2 || !recent.First.Equal(base.Add(2*time.Hour)) {
+				t.Errorf("expected the last two runs, got %+v", recent)
+			}
+		})
+	}
+}
+
+// TestStatsResumedRun tests that a resumed run counts neither the attempts carried over
+// from before it was resumed as retries, nor the time it spent failed in its duration.
+func TestStatsResumedRun(t *testing.T) {
+	for backend, open := range repositoryBackends {
+		t.Run(string(backend), func(t *testing.T) {
+			repo := open(t)
+			defer repo.Close()
+
+			// load fails twice, then succeeds at the first attempt when resumed two hours later
+			start := time.Now().Add(-2 * time.Hour)
+			wr := &WorkflowRun{Workflow: "etl", WorkflowHash: "hash", Status: StatusFailed, StartedAt: start, CreatedAt: start}
+			if err := repo.CreateWorkflowRun(wr); err != nil {
+				t.Fatalf("CreateWorkflowRun failed: %v", err)
+			}
+			load := &TaskRun{RunID: wr.ID, Name: "load", Status: TaskFailed, StartedAt: start, Attempts: 2,
+				EndedAt: sql.NullTime{Time: start.Add(time.Second), Valid: true}}
+			if err := repo.SaveTaskRun(load); err != nil {
+				t.Fatalf("SaveTaskRun failed: %v", err)
+			}
+
+			if err := repo.Reopen(wr); err != nil {
+				t.Fatalf("Reopen failed: %v", err)
+			}
+			resumed := wr.ExecutionStart()
+			if !resumed.After(start) {
+				t.Fatalf("expected the execution to start when the run was resumed, got %s", resumed)
+			}
+
+			load.Status, load.StartedAt, load.PriorAttempts, load.Attempts = TaskSuccess, resumed, load.Attempts, load.Attempts+1
+			load.EndedAt = sql.NullTime{Time: resumed.Add(500 * time.Millisecond), Valid: true}
+			wr.Status, wr.EndedAt = StatusSuccess, sql.NullTime{Time: resumed.Add(time.Second), Valid: true}
+			if err := repo.FinishTask(load, wr); err != nil {
+				t.Fatalf("FinishTask failed: %v", err)
+			}
+
+			stats, err := Stats(repo, "etl", -1)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			if len(stats.Durations) != 1 || stats.Durations[0].Duration != time.Second {
+				t.Errorf("expected a run duration of 1s from the resume, got %+v", stats.Durations)
+			}
+			if len(stats.Tasks) != 1 {
+				t.Fatalf("expected statistics of one task, got %d", len(stats.Tasks))
+			}
+			if task := stats.Tasks[0]; task.Retries != 0 || task.Retried != 0 || task.Max() != 500*time.Millisecond {
+				t.Errorf("expected no retries and a duration of 500ms, got %+v", task)
+			}
+		})
+	}
+}
+
+// TestPercentile tests nearest-rank percentiles of durations.
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3, 10, 6, 7, 9, 8}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 1}, {10, 1}, {50, 5}, {90, 9}, {95, 10}, {100, 10},
+	}
+	for _, tt := range tests {
+		if got := Percentile(durations, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %d, want %d", tt.p, got, tt.want)
+		}
+	}
+	if durations[0] != 5 {
+		t.Error("Percentile modified its input")
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile of no durations = %d, want 0", got)
+	}
+}
+
+// TestExportImport tests that an archive carries runs, task attempts, definitions and logs
+// from one repository to another, and that importing it again changes nothing.
+func TestExportImport(t *testing.T) {
+	src := repositoryBackends[BackendSQLite](t)
+	defer src.Close()
+	srcLogs := t.TempDir()
+
+	old := &WorkflowRun{Workflow: "etl", WorkflowHash: "hash", Status: StatusSuccess, StartedAt: time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)}
+	wr := &WorkflowRun{
+		Workflow:     "etl",
+		WorkflowHash: "hash",
+		Status:       StatusFailed,
+		StartedAt:    time.Date(2026, 9, 2, 10, 0, 0, 0, time.UTC),
+		EndedAt:      sql.NullTime{Time: time.Date(2026, 9, 2, 10, 5, 0, 0, time.UTC), Valid: true},
+		Definition:   sql.NullString{String: `{"name":"etl"}`, Valid: true},
+		PID:          sql.NullInt64{Int64: 42, Valid: true},
+		Host:         sql.NullString{String: "edge-1", Valid: true},
+	}
+	other := &WorkflowRun{Workflow: "deploy", WorkflowHash: "hash", Status: StatusSuccess, StartedAt: time.Date(2026, 9, 3, 0, 0, 0, 0, time.UTC)}
+	for _, r := range []*WorkflowRun{old, wr, other} {
+		if err := src.CreateWorkflowRun(r); err != nil {
+			t.Fatalf("CreateWorkflowRun failed: %v", err)
+		}
+	}
+	if err := src.Update(wr); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(srcLogs, wr.ID), 0755)
+	for attempt := 1; attempt <= 2; attempt++ {
+		logPath := filepath.Join(srcLogs, wr.ID, fmt.Sprintf("load_%d.log", attempt))
+		os.WriteFile(logPath, []byte(fmt.Sprintf("attempt %d\n", attempt)), 0644)
+
+		task := &TaskRun{RunID: wr.ID, Name: "load", Status: TaskFailed, StartedAt: wr.StartedAt, Attempts: attempt, LogPath: logPath}
+		if err := src.SaveTaskRun(task); err != nil {
+			t.Fatalf("SaveTaskRun failed: %v", err)
+		}
+		if attempt == 1 {
+			if err := src.ResetTaskRuns(wr.ID, []string{"load"}); err != nil {
+				t.Fatalf("ResetTaskRuns failed: %v", err)
+			}
+		}
+	}
+
+	var archive bytes.Buffer
+	exported, err := Export(src, &archive, ExportOptions{Since: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), Workflow: "etl"})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(exported.Runs) != 1 || exported.Runs[0].ID != wr.ID || exported.Logs != 2 || exported.TaskRuns != 3 {
+		t.Fatalf("expected only the September etl run with 3 task runs and 2 logs, got %d runs, %d task runs, %d logs", len(exported.Runs), exported.TaskRuns, exported.Logs)
+	}
+
+	dst := repositoryBackends[BackendJSONL](t)
+	defer dst.Close()
+	dstLogs := t.TempDir()
+
+	result, err := Import(dst, bytes.NewReader(archive.Bytes()), dstLogs)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(result.Imported) != 1 || result.Logs != 2 || result.Manifest.Host != hostname() {
+		t.Fatalf("expected one imported run with 2 logs, got %+v", result)
+	}
+
+	imported, err := dst.Load(wr.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !imported.StartedAt.Equal(wr.StartedAt) || !imported.EndedAt.Time.Equal(wr.EndedAt.Time) || imported.Status != StatusFailed {
+		t.Errorf("expected timestamps and status preserved, got %+v", imported)
+	}
+	if imported.Definition.String != wr.Definition.String || imported.Host.String != "edge-1" || imported.PID.Int64 != 42 {
+		t.Errorf("expected definition and host preserved, got %+v", imported)
+	}
+	if imported.Origin.String != hostname() {
+		t.Errorf("expected origin %q, got %q", hostname(), imported.Origin.String)
+	}
+
+	history, err := dst.LoadTaskRunHistory(wr.ID)
+	if err != nil {
+		t.Fatalf("LoadTaskRunHistory failed: %v", err)
+	}
+	if len(history) != 3 || !history[0].Archived || history[len(history)-1].Archived {
+		t.Fatalf("expected archived attempts preserved, got %+v", history)
+	}
+	data, err := os.ReadFile(history[0].LogPath)
+	if err != nil || string(data) != "attempt 1\n" || filepath.Dir(history[0].LogPath) != filepath.Join(dstLogs, wr.ID) {
+		t.Errorf("expected the first attempt's log under the new logs directory, got %s: %q (%v)", history[0].LogPath, data, err)
+	}
+
+	again, err := Import(dst, bytes.NewReader(archive.Bytes()), dstLogs)
+	if err != nil {
+		t.Fatalf("second Import failed: %v", err)
+	}
+	if len(again.Imported) != 0 || len(again.Skipped) != 1 || again.Logs != 0 {
+		t.Errorf("expected the second import to skip the run, got %+v", again)
+	}
+	if history, _ := dst.LoadTaskRunHistory(wr.ID); len(history) != 3 {
+		t.Errorf("expected the second import to add no task runs, got %d", len(history))
+	}
+
+	// Re-exporting an imported run keeps its original origin.
+	var reexported bytes.Buffer
+	if _, err := Export(dst, &reexported, ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	memory := NewMemoryRepository()
+	if _, err := Import(memory, &reexported, ""); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if r, err := memory.Load(wr.ID); err != nil || r.Origin.String != hostname() {
+		t.Errorf("expected the origin to survive a second export, got %v (%v)", r, err)
+	}
+}
+
+// TestExportAttemptLogs tests that an archive carries the log of every attempt of a task
+// that was retried, not only the log of its last attempt.
+func TestExportAttemptLogs(t *testing.T) {
+	src := repositoryBackends[BackendSQLite](t)
+	defer src.Close()
+	srcLogs := t.TempDir()
+
+	wr := &WorkflowRun{Workflow: "etl", WorkflowHash: "hash", Status: StatusFailed, StartedAt: time.Now()}
+	if err := src.CreateWorkflowRun(wr); err != nil {
+		t.Fatalf("CreateWorkflowRun failed: %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(srcLogs, wr.ID), 0755)
+	for attempt := 1; attempt <= 3; attempt++ {
+		os.WriteFile(filepath.Join(srcLogs, wr.ID, fmt.Sprintf("load_%d.log", attempt)), []byte(fmt.Sprintf("attempt %d\n", attempt)), 0644)
+	}
+	task := &TaskRun{RunID: wr.ID, Name: "load", Status: TaskFailed, StartedAt: wr.StartedAt, Attempts: 3, LogPath: filepath.Join(srcLogs, wr.ID, "load_3.log")}
+	if err := src.SaveTaskRun(task); err != nil {
+		t.Fatalf("SaveTaskRun failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	exported, err := Export(src, &archive, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if exported.Logs != 3 || exported.MissingLogs != 0 {
+		t.Fatalf("expected 3 attempt logs, got %d (%d missing)", exported.Logs, exported.MissingLogs)
+	}
+
+	dstLogs := t.TempDir()
+	result, err := Import(NewMemoryRepository(), &archive, dstLogs)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Logs != 3 {
+		t.Errorf("expected 3 imported logs, got %d", result.Logs)
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		data, err := os.ReadFile(filepath.Join(dstLogs, wr.ID, fmt.Sprintf("load_%d.log", attempt)))
+		if err != nil || string(data) != fmt.Sprintf("attempt %d\n", attempt) {
+			t.Errorf("expected the log of attempt %d to be imported, got %q (%v)", attempt, data, err)
+		}
+	}
+}
+
+// TestImportRejectsUnsafeArchive tests that archive entries cannot escape the logs directory.
+func TestImportRejectsUnsafeArchive(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		"manifest.json": `{"format":"wf-runs","version":1,"host":"edge-1"}`,
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	evil := "pwned"
+	tw.WriteHeader(&tar.Header{Name: "runs/../../logs/evil.log", Mode: 0644, Size: int64(len(evil))})
+	tw.Write([]byte(evil))
+	tw.Close()
+	gz.Close()
+
+	logsDir := t.TempDir()
+	if _, err := Import(NewMemoryRepository(), &buf, logsDir); err == nil || !strings.Contains(err.Error(), "unexpected archive entry") {
+		t.Errorf("expected the entry to be rejected, got %v", err)
+	}
+
+	if _, err := Import(NewMemoryRepository(), strings.NewReader("not an archive"), logsDir); err == nil {
+		t.Error("expected a non-archive to be rejected")
+	}
+}
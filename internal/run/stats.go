@@ -0,0 +1,189 @@
+package run
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// WorkflowStats summarises the recent runs of a workflow.
+type WorkflowStats struct {
+	Workflow    string
+	Runs        int
+	Succeeded   int
+	Failed      int
+	Interrupted int
+	First, Last time.Time    // Creation times of the oldest and most recent runs considered
+	Days        []DayStats   // Runs per day, oldest first
+	Durations   []RunSample  // Duration of each finished run, oldest first
+	Tasks       []*TaskStats // Sorted by name
+}
+
+// SuccessRate returns the fraction of runs that succeeded, or 0 without runs.
+func (s *WorkflowStats) SuccessRate() float64 {
+	return ratio(s.Succeeded, s.Runs)
+}
+
+// Flakiest returns up to n tasks that needed retries, the most retried first.
+func (s *WorkflowStats) Flakiest(n int) []*TaskStats {
+	var flaky []*TaskStats
+	for _, t := range s.Tasks {
+		if t.Retries > 0 {
+			flaky = append(flaky, t)
+		}
+	}
+	sort.SliceStable(flaky, func(i, j int) bool {
+		if flaky[i].Retries != flaky[j].Retries {
+			return flaky[i].Retries > flaky[j].Retries
+		}
+		return flaky[i].Retried > flaky[j].Retried
+	})
+	if len(flaky) > n {
+		flaky = flaky[:n]
+	}
+	return flaky
+}
+
+// DayStats counts the runs created on one day, in local time.
+type DayStats struct {
+	Date      string // 2006-01-02
+	Runs      int
+	Succeeded int
+}
+
+// SuccessRate returns the fraction of the day's runs that succeeded.
+func (d DayStats) SuccessRate() float64 {
+	return ratio(d.Succeeded, d.Runs)
+}
+
+// RunSample is the duration of one finished run.
+type RunSample struct {
+	RunID     string
+	StartedAt time.Time
+	Status    WorkflowStatus
+	Duration  time.Duration
+}
+
+// TaskStats summarises the runs of one task.
+type TaskStats struct {
+	Name      string
+	Runs      int             // Runs of the workflow in which the task started
+	Failed    int             // Runs in which the task failed
+	Retries   int             // Attempts beyond the first of each execution, over all runs
+	Retried   int             // Runs in which an execution of the task needed more than one attempt
+	Durations []time.Duration // Duration of the last execution of the task in each run, oldest first
+}
+
+// Percentile returns the duration below which p percent of the task's finished runs fall.
+func (t *TaskStats) Percentile(p float64) time.Duration {
+	return Percentile(t.Durations, p)
+}
+
+// Max returns the longest finished run of the task.
+func (t *TaskStats) Max() time.Duration {
+	return Percentile(t.Durations, 100)
+}
+
+// Stats summarises the last limit runs of a workflow that are not running. A negative
+// limit considers every run. Retries and durations are those of the last execution of a
+// run and its tasks, so a run resumed or rerun later does not count the attempts carried
+// over from before, nor the time it spent failed.
+func Stats(repo Repository, workflow string, limit int) (*WorkflowStats, error) {
+	runs, err := repo.ListRunsWithTasks(workflow, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runs: %w", err)
+	}
+
+	stats := &WorkflowStats{Workflow: workflow, Runs: len(runs)}
+	tasks := map[string]*TaskStats{}
+
+	// Oldest first, so that days and durations are in chronological order
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i].Run
+
+		switch r.Status {
+		case StatusSuccess:
+			stats.Succeeded++
+		case StatusFailed:
+			stats.Failed++
+		case StatusInterrupted:
+			stats.Interrupted++
+		}
+
+		if stats.First.IsZero() {
+			stats.First = r.CreatedAt
+		}
+		stats.Last = r.CreatedAt
+
+		date := r.CreatedAt.Local().Format("2006-01-02")
+		if n := len(stats.Days); n == 0 || stats.Days[n-1].Date != date {
+			stats.Days = append(stats.Days, DayStats{Date: date})
+		}
+		day := &stats.Days[len(stats.Days)-1]
+		day.Runs++
+		if r.Status == StatusSuccess {
+			day.Succeeded++
+		}
+
+		if r.EndedAt.Valid {
+			stats.Durations = append(stats.Durations, RunSample{
+				RunID:     r.ID,
+				StartedAt: r.StartedAt,
+				Status:    r.Status,
+				Duration:  r.EndedAt.Time.Sub(r.ExecutionStart()),
+			})
+		}
+
+		for _, tr := range runs[i].Tasks {
+			if tr.Status == TaskPending {
+				continue
+			}
+			t, ok := tasks[tr.Name]
+			if !ok {
+				t = &TaskStats{Name: tr.Name}
+				tasks[tr.Name] = t
+				stats.Tasks = append(stats.Tasks, t)
+			}
+			t.Runs++
+			if tr.Status == TaskFailed {
+				t.Failed++
+			}
+			if retries := tr.Attempts - tr.PriorAttempts - 1; retries > 0 {
+				t.Retries += retries
+				t.Retried++
+			}
+			if tr.EndedAt.Valid {
+				t.Durations = append(t.Durations, tr.EndedAt.Time.Sub(tr.StartedAt))
+			}
+		}
+	}
+
+	sort.Slice(stats.Tasks, func(i, j int) bool {
+		return stats.Tasks[i].Name < stats.Tasks[j].Name
+	})
+	return stats, nil
+}
+
+// Percentile returns the p-th percentile of durations by the nearest-rank method, or 0 if
+// there are none. durations is not modified.
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+// ratio returns n/total, or 0 if total is 0.
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
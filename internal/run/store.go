@@ -3,6 +3,8 @@ package run
 
 import (
 	"database/sql"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,75 +13,161 @@ import (
 
 // Store manages the persistence of WorkflowRun instances using SQLite.
 type Store struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
 }
 
-// NewStore initialises a new Store with SQLite database at the given path.
+// NewStore initialises a new Store with SQLite database at the given path,
+// applying any pending schema migrations.
 func NewStore(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	store, err := OpenStore(dbPath)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	if _, err := store.Migrate(); err != nil {
+		store.Close()
 		return nil, err
 	}
 
-	store := &Store{db: db}
-	if err := store.migrate(); err != nil {
+	return store, nil
+}
+
+// connParams configures every connection to the database. WAL journaling lets readers
+// such as wf runs and wf logs work while a run is writing; the busy timeout makes a
+// writer wait for another instead of failing with "database is locked"; immediate
+// transactions take the write lock when they begin, so two transactions cannot
+// deadlock upgrading from a read to a write.
+const connParams = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+
+// maxOpenConns bounds the connection pool. WAL allows concurrent readers alongside the
+// single writer, such as the heartbeat of a running executor.
+const maxOpenConns = 4
+
+// OpenStore opens the SQLite database at the given path without migrating it,
+// for inspecting or migrating the schema explicitly.
+func OpenStore(dbPath string) (*Store, error) {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite", dbPath+sep+connParams)
+	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
 
-	return store, nil
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db, path: dbPath}, nil
 }
 
-// migrate creates the necessary tables if they don't exist.
-func (s *Store) migrate() error {
-	_, err := s.db.Exec(dbschema)
-	return err
+// inTx runs fn in a transaction, committing if it succeeds and rolling back otherwise.
+func (s *Store) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Path returns the path of the database file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// NewRunID returns a new unique run ID.
+func NewRunID() string {
+	return uuid.New().String()
 }
 
 // NewWorkflowRun creates and stores a new WorkflowRun with the given workflow name and DAG hash.
 func (s *Store) NewWorkflowRun(workflow string, dagHash string) (*WorkflowRun, error) {
-	id := uuid.New().String()
-
 	run := &WorkflowRun{
-		ID:           id,
 		Workflow:     workflow,
 		WorkflowHash: dagHash,
-		Status:       StatusRunning,
-		StartedAt:    time.Now(),
-		CreatedAt:    time.Now(),
 	}
 
-	_, err := s.db.Exec(QueryCreateWorkflowRun, run.ID, run.Workflow, run.WorkflowHash, run.Status, run.StartedAt, run.CreatedAt)
-	if err != nil {
+	if err := s.CreateWorkflowRun(run); err != nil {
 		return nil, err
 	}
 
 	return run, nil
 }
 
+// CreateWorkflowRun stores a new WorkflowRun, assigning its ID, status and timestamps when unset.
+// A running WorkflowRun is claimed by the current process.
+func (s *Store) CreateWorkflowRun(run *WorkflowRun) error {
+	prepareRun(run)
+
+	_, err := s.db.Exec(QueryCreateWorkflowRun, run.ID, run.Workflow, run.WorkflowHash, run.Status, run.StartedAt, run.CreatedAt, run.Source, run.Definition, run.GitCommit, run.GitDirty, run.GitRev, run.Selection, run.PID, run.Host, run.HeartbeatAt)
+	return err
+}
+
+// prepareRun assigns the ID, status and timestamps of a new WorkflowRun when unset, and
+// claims a running WorkflowRun for the current process.
+func prepareRun(run *WorkflowRun) {
+	if run.ID == "" {
+		run.ID = NewRunID()
+	}
+	if run.Status == "" {
+		run.Status = StatusRunning
+	}
+	if run.StartedAt.IsZero() {
+		run.StartedAt = time.Now()
+	}
+	if run.CreatedAt.IsZero() {
+		run.CreatedAt = time.Now()
+	}
+	if run.Status == StatusRunning && !run.PID.Valid {
+		run.claim(time.Now())
+	}
+}
+
 // Update persists changes to an existing WorkflowRun.
 func (s *Store) Update(run *WorkflowRun) error {
-	_, err := s.db.Exec(QueryUpdateWorkflowRun, run.Status, run.EndedAt, run.ExitCode, run.Meta, run.ID)
+	_, err := s.db.Exec(QueryUpdateWorkflowRun, run.Status, run.EndedAt, run.ExitCode, run.Meta, run.WorkflowHash, run.Definition, run.ID)
 	return err
 }
 
 // Load retrieves a WorkflowRun by its ID.
 func (s *Store) Load(id string) (*WorkflowRun, error) {
-	run := &WorkflowRun{}
-	err := s.db.QueryRow(QueryLoadWorkflowRun, id).Scan(&run.ID, &run.Workflow, &run.WorkflowHash, &run.Status, &run.StartedAt, &run.EndedAt, &run.ExitCode, &run.Meta, &run.CreatedAt)
+	return scanWorkflowRun(s.db.QueryRow(QueryLoadWorkflowRun, id))
+}
+
+// ListRuns retrieves workflow runs with optional filtering and pagination.
+func (s *Store) ListRuns(workflow, status string, limit, offset int) ([]*WorkflowRun, error) {
+	rows, err := s.db.Query(QueryListRuns, workflow, workflow, status, status, limit, offset)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return run, nil
+	var runs []*WorkflowRun
+	for rows.Next() {
+		run, err := scanWorkflowRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
 }
 
-// ListRuns retrieves workflow runs with optional filtering and pagination.
-func (s *Store) ListRuns(workflow, status string, limit, offset int) ([]*WorkflowRun, error) {
-	rows, err := s.db.Query(QueryListRuns, workflow, workflow, status, status, limit, offset)
+// FindRuns retrieves the runs whose ID starts with prefix, most recent first.
+func (s *Store) FindRuns(prefix string) ([]*WorkflowRun, error) {
+	rows, err := s.db.Query(QueryFindRuns, prefix, prefix)
 	if err != nil {
 		return nil, err
 	}
@@ -87,8 +175,8 @@ func (s *Store) ListRuns(workflow, status string, limit, offset int) ([]*Workflo
 
 	var runs []*WorkflowRun
 	for rows.Next() {
-		run := &WorkflowRun{}
-		if err := rows.Scan(&run.ID, &run.Workflow, &run.WorkflowHash, &run.Status, &run.StartedAt, &run.EndedAt, &run.ExitCode, &run.Meta, &run.CreatedAt); err != nil {
+		run, err := scanWorkflowRun(rows)
+		if err != nil {
 			return nil, err
 		}
 		runs = append(runs, run)
@@ -97,9 +185,68 @@ func (s *Store) ListRuns(workflow, status string, limit, offset int) ([]*Workflo
 	return runs, rows.Err()
 }
 
+// ListRunsWithTasks retrieves the most recent runs of a workflow that are not running,
+// most recent first, each with its current TaskRuns, in two queries.
+func (s *Store) ListRunsWithTasks(workflow string, limit int) ([]RunTasks, error) {
+	rows, err := s.db.Query(QueryListFinishedRuns, workflow, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []RunTasks
+	index := map[string]int{}
+	for rows.Next() {
+		run, err := scanWorkflowRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[run.ID] = len(runs)
+		runs = append(runs, RunTasks{Run: run})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	taskRows, err := s.db.Query(QueryLoadFinishedTaskRuns, workflow, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer taskRows.Close()
+
+	for taskRows.Next() {
+		var task TaskRun
+		if err := taskRows.Scan(&task.ID, &task.RunID, &task.Name, &task.Status, &task.StartedAt, &task.EndedAt, &task.Attempts, &task.ExitCode, &task.LogPath, &task.LastError, &task.PriorAttempts); err != nil {
+			return nil, err
+		}
+		// Skip tasks of runs that finished between the two queries and shifted the window
+		if i, ok := index[task.RunID]; ok {
+			runs[i].Tasks = append(runs[i].Tasks, task)
+		}
+	}
+
+	return runs, taskRows.Err()
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanWorkflowRun reads a WorkflowRun from a row selected with the workflow_runs column list.
+func scanWorkflowRun(row rowScanner) (*WorkflowRun, error) {
+	run := &WorkflowRun{}
+	err := row.Scan(&run.ID, &run.Workflow, &run.WorkflowHash, &run.Status, &run.StartedAt, &run.EndedAt, &run.ExitCode, &run.Meta, &run.CreatedAt, &run.Source, &run.Definition, &run.GitCommit, &run.GitDirty, &run.GitRev, &run.Selection, &run.PID, &run.Host, &run.HeartbeatAt, &run.Origin, &run.ResumedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
 // SaveTaskRun persists a TaskRun to the database.
 func (s *Store) SaveTaskRun(task *TaskRun) error {
-	result, err := s.db.Exec(QueryCreateTaskRun, task.RunID, task.Name, task.Status, task.StartedAt, task.EndedAt, task.Attempts, task.ExitCode, task.LogPath, task.LastError)
+	result, err := s.db.Exec(QueryCreateTaskRun, task.RunID, task.Name, task.Status, task.StartedAt, task.EndedAt, task.Attempts, task.ExitCode, task.LogPath, task.LastError, task.PriorAttempts)
 	if err != nil {
 		return err
 	}
@@ -115,10 +262,22 @@ func (s *Store) SaveTaskRun(task *TaskRun) error {
 
 // UpdateTaskRun updates an existing TaskRun.
 func (s *Store) UpdateTaskRun(task *TaskRun) error {
-	_, err := s.db.Exec(QueryUpdateTaskRun, task.Status, task.EndedAt, task.Attempts, task.ExitCode, task.LogPath, task.LastError, task.ID)
+	_, err := s.db.Exec(QueryUpdateTaskRun, task.Status, task.StartedAt, task.EndedAt, task.Attempts, task.ExitCode, task.LogPath, task.LastError, task.PriorAttempts, task.ID)
 	return err
 }
 
+// FinishTask persists the final state of a TaskRun together with the status change of its
+// WorkflowRun in one transaction, so a crash cannot record one without the other.
+func (s *Store) FinishTask(task *TaskRun, run *WorkflowRun) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(QueryUpdateTaskRun, task.Status, task.StartedAt, task.EndedAt, task.Attempts, task.ExitCode, task.LogPath, task.LastError, task.PriorAttempts, task.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(QueryUpdateWorkflowRun, run.Status, run.EndedAt, run.ExitCode, run.Meta, run.WorkflowHash, run.Definition, run.ID)
+		return err
+	})
+}
+
 // LoadTaskRuns retrieves all TaskRuns for a given WorkflowRun.
 func (s *Store) LoadTaskRuns(runID string) ([]TaskRun, error) {
 	rows, err := s.db.Query(QueryLoadTaskRuns, runID)
@@ -130,7 +289,7 @@ func (s *Store) LoadTaskRuns(runID string) ([]TaskRun, error) {
 	var tasks []TaskRun
 	for rows.Next() {
 		var task TaskRun
-		if err := rows.Scan(&task.ID, &task.RunID, &task.Name, &task.Status, &task.StartedAt, &task.EndedAt, &task.Attempts, &task.ExitCode, &task.LogPath, &task.LastError); err != nil {
+		if err := rows.Scan(&task.ID, &task.RunID, &task.Name, &task.Status, &task.StartedAt, &task.EndedAt, &task.Attempts, &task.ExitCode, &task.LogPath, &task.LastError, &task.PriorAttempts); err != nil {
 			return nil, err
 		}
 		tasks = append(tasks, task)
@@ -142,7 +301,7 @@ func (s *Store) LoadTaskRuns(runID string) ([]TaskRun, error) {
 // GetTaskRun retrieves a specific TaskRun by run ID and task name.
 func (s *Store) GetTaskRun(runID, taskName string) (*TaskRun, error) {
 	task := &TaskRun{}
-	err := s.db.QueryRow(QueryGetTaskRun, runID, taskName).Scan(&task.ID, &task.RunID, &task.Name, &task.Status, &task.StartedAt, &task.EndedAt, &task.Attempts, &task.ExitCode, &task.LogPath, &task.LastError)
+	err := s.db.QueryRow(QueryGetTaskRun, runID, taskName).Scan(&task.ID, &task.RunID, &task.Name, &task.Status, &task.StartedAt, &task.EndedAt, &task.Attempts, &task.ExitCode, &task.LogPath, &task.LastError, &task.PriorAttempts)
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +309,140 @@ func (s *Store) GetTaskRun(runID, taskName string) (*TaskRun, error) {
 	return task, nil
 }
 
+// LoadTaskRunHistory retrieves every TaskRun recorded for a WorkflowRun, including
+// those archived by a rerun, in the order they were created.
+func (s *Store) LoadTaskRunHistory(runID string) ([]TaskRun, error) {
+	rows, err := s.db.Query(QueryLoadTaskRunHistory, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []TaskRun
+	for rows.Next() {
+		var task TaskRun
+		if err := rows.Scan(&task.ID, &task.RunID, &task.Name, &task.Status, &task.StartedAt, &task.EndedAt, &task.Attempts, &task.ExitCode, &task.LogPath, &task.LastError, &task.Archived, &task.PriorAttempts); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// ResetTaskRuns archives the current TaskRuns of the named tasks and replaces them with
+// pending TaskRuns, so the tasks run again when the WorkflowRun is resumed. The new TaskRuns
+// carry over the attempt count, so later attempts do not overwrite earlier logs.
+func (s *Store) ResetTaskRuns(runID string, names []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, name := range names {
+		var attempts int
+		err := tx.QueryRow("SELECT attempts FROM task_runs WHERE run_id = ? AND name = ? AND archived = 0", runID, name).Scan(&attempts)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if _, err := tx.Exec(QueryArchiveTaskRun, runID, name); err != nil {
+			return err
+		}
+
+		pending := &TaskRun{
+			RunID:         runID,
+			Name:          name,
+			Status:        TaskPending,
+			StartedAt:     time.Now(),
+			Attempts:      attempts,
+			PriorAttempts: attempts,
+		}
+		if _, err := tx.Exec(QueryCreateTaskRun, pending.RunID, pending.Name, pending.Status, pending.StartedAt, pending.EndedAt, pending.Attempts, pending.ExitCode, pending.LogPath, pending.LastError, pending.PriorAttempts); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteRun deletes a run that is not running, together with its task runs and locks.
+func (s *Store) DeleteRun(id string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		var status WorkflowStatus
+		if err := tx.QueryRow("SELECT status FROM workflow_runs WHERE id = ?", id).Scan(&status); err != nil {
+			return err
+		}
+		if status == StatusRunning {
+			return errRunning(id)
+		}
+
+		for _, query := range []string{
+			"DELETE FROM task_runs WHERE run_id = ?",
+			"DELETE FROM run_locks WHERE run_id = ?",
+			"DELETE FROM workflow_runs WHERE id = ?",
+		} {
+			if _, err := tx.Exec(query, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ImportRun stores a run recorded elsewhere, with its task runs, in one transaction. The
+// task runs are given new IDs. It reports false if a run with the same ID already exists.
+func (s *Store) ImportRun(run *WorkflowRun, tasks []TaskRun) (bool, error) {
+	imported := false
+	err := s.inTx(func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM workflow_runs WHERE id = ?", run.ID).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+
+		if _, err := tx.Exec(QueryImportWorkflowRun, run.ID, run.Workflow, run.WorkflowHash, run.Status, run.StartedAt, run.EndedAt, run.ExitCode, run.Meta, run.CreatedAt, run.Source, run.Definition, run.GitCommit, run.GitDirty, run.GitRev, run.Selection, run.PID, run.Host, run.HeartbeatAt, run.Origin, run.ResumedAt); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if _, err := tx.Exec(QueryImportTaskRun, run.ID, task.Name, task.Status, task.StartedAt, task.EndedAt, task.Attempts, task.ExitCode, task.LogPath, task.LastError, task.Archived, task.PriorAttempts); err != nil {
+				return err
+			}
+		}
+
+		imported = true
+		return nil
+	})
+	return imported, err
+}
+
+// Compact rebuilds the database to release the pages freed by deleted runs and truncates
+// the write-ahead log, returning the number of bytes reclaimed.
+func (s *Store) Compact() (int64, error) {
+	before := s.size()
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return 0, err
+	}
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return 0, err
+	}
+	return max(before-s.size(), 0), nil
+}
+
+// size returns the size of the database file and its write-ahead log.
+func (s *Store) size() int64 {
+	var total int64
+	for _, path := range []string{s.path, s.path + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
 // Close closes the database connection.
 func (s *Store) Close() error {
 	return s.db.Close()
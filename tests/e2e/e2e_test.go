@@ -2,6 +2,7 @@
 package e2e
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -60,6 +61,26 @@ func TestE2ECompleteWorkflow(t *testing.T) {
 		testRun(t, fs)
 	})
 
+	// Test that dry-run plans are stable
+	t.Run("deterministic_plan", func(t *testing.T) {
+		testDeterministicPlan(t, fs)
+	})
+
+	// Test run command with file paths and stdin
+	t.Run("run_from_path", func(t *testing.T) {
+		testRunFromPath(t, fs)
+	})
+
+	// Test run command at a git revision
+	t.Run("run_at_revision", func(t *testing.T) {
+		testRunAtRevision(t, fs)
+	})
+
+	// Test fmt command
+	t.Run("fmt", func(t *testing.T) {
+		testFmt(t, fs)
+	})
+
 	// Test logs command
 	t.Run("logs", func(t *testing.T) {
 		testLogs(t, fs)
@@ -70,10 +91,25 @@ func TestE2ECompleteWorkflow(t *testing.T) {
 		testRuns(t, fs)
 	})
 
+	// Test show command
+	t.Run("show", func(t *testing.T) {
+		testShow(t, fs)
+	})
+
+	// Test stats command
+	t.Run("stats", func(t *testing.T) {
+		testStats(t, fs)
+	})
+
 	// Test resume command
 	t.Run("resume", func(t *testing.T) {
 		testResume(t, fs)
 	})
+
+	// Test prune command and retention
+	t.Run("prune", func(t *testing.T) {
+		testPrune(t, fs)
+	})
 }
 
 // TestE2EErrorHandling tests error scenarios across the CLI.
@@ -118,6 +154,10 @@ func TestE2EConfigManagement(t *testing.T) {
 	t.Run("config_file_override", func(t *testing.T) {
 		testConfigFileOverride(t, fs)
 	})
+
+	t.Run("project_discovery", func(t *testing.T) {
+		testProjectDiscovery(t, fs)
+	})
 }
 
 // setupProject creates the necessary project structure for E2E testing.
@@ -349,7 +389,186 @@ func testRun(t *testing.T, fs *helpers.TestFS) {
 	}
 }
 
+// testRunFromPath tests running workflows from explicit paths and stdin.
+func testDeterministicPlan(t *testing.T, fs *helpers.TestFS) {
+	var first string
+	for i := 0; i < 10; i++ {
+		cmd := newCmd(fs, "run", "multi", "--dry-run", "--json")
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("dry-run failed: %v\noutput: %s", err, string(output))
+		}
+
+		if i == 0 {
+			first = string(output)
+			continue
+		}
+		if string(output) != first {
+			t.Fatalf("expected identical plans across runs, run %d differed:\n%s\nvs\n%s", i, first, string(output))
+		}
+	}
+}
+
+// testFmt tests that fmt --check fails for unformatted files and passes once they are formatted.
+func testFmt(t *testing.T, fs *helpers.TestFS) {
+	fs.Write("project/fmt/messy.toml", `# kept comment
+name = "messy"
+[tasks.b]
+depends_on = ["a"]
+cmd = "echo b"
+[tasks.a]
+cmd = "echo a"
+`)
+	path := fs.Path("project", "fmt", "messy.toml")
+
+	cmd := newCmd(fs, "fmt", "--check", path)
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("expected fmt --check to fail for an unformatted file\noutput: %s", string(output))
+	}
+
+	cmd = newCmd(fs, "fmt", path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("fmt failed: %v\noutput: %s", err, string(output))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read formatted file: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# kept comment\nname = \"messy\"\n\n[tasks.b]\ncmd = \"echo b\"\n") {
+		t.Errorf("unexpected formatted file:\n%s", data)
+	}
+
+	cmd = newCmd(fs, "fmt", "--check", path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expected fmt --check to pass after formatting: %v\noutput: %s", err, string(output))
+	}
+}
+
+func testRunFromPath(t *testing.T, fs *helpers.TestFS) {
+	fs.Write("project/ci/build.toml", helpers.SimpleWorkflow())
+
+	// Relative path outside the workflows directory
+	cmd := newCmd(fs, "run", "./project/ci/build.toml")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run with path failed: %v\noutput: %s", err, string(output))
+	}
+
+	// Explicit --file flag
+	cmd = newCmd(fs, "run", "-f", fs.Path("project", "ci", "build.toml"))
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run --file failed: %v\noutput: %s", err, string(output))
+	}
+
+	// Workflow definition piped through stdin
+	cmd = newCmd(fs, "run", "-")
+	cmd.Stdin = strings.NewReader(helpers.SimpleWorkflow())
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run from stdin failed: %v\noutput: %s", err, string(output))
+	}
+
+	store, err := run.NewStore(fs.Path("test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	runs, err := store.ListRuns("simple", "", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+
+	if len(runs) < 3 {
+		t.Fatalf("expected at least 3 runs, got %d", len(runs))
+	}
+
+	wantSource := fs.Path("project", "ci", "build.toml")
+	var sawFile, sawStdin bool
+	for _, r := range runs {
+		switch r.Source.String {
+		case wantSource:
+			sawFile = true
+		case "-":
+			sawStdin = true
+		}
+	}
+
+	if !sawFile {
+		t.Errorf("expected a run with source %s", wantSource)
+	}
+	if !sawStdin {
+		t.Error("expected a run with stdin source")
+	}
+}
+
 // testLogs tests the logs command.
+func testRunAtRevision(t *testing.T, fs *helpers.TestFS) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := fs.Path("gitrepo")
+	fs.Write("gitrepo/pipeline.toml", `
+name = "pipeline"
+
+[tasks.build]
+cmd = "echo v1"
+`)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %v\noutput: %s", strings.Join(args, " "), err, string(output))
+		}
+	}
+	git("init", "-q")
+	git("add", "pipeline.toml")
+	git("commit", "-q", "-m", "v1")
+
+	// Working tree now differs from HEAD and would fail if run
+	fs.Write("gitrepo/pipeline.toml", `
+name = "pipeline"
+
+[tasks.build]
+cmd = "exit 1"
+`)
+
+	cmd := newCmd(fs, "run", "--rev", "HEAD", "./gitrepo/pipeline.toml")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run --rev failed: %v\noutput: %s", err, string(output))
+	}
+
+	store, err := run.NewStore(fs.Path("test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	runs, err := store.ListRuns("pipeline", "", 1, 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one pipeline run, got %d (err: %v)", len(runs), err)
+	}
+
+	r := runs[0]
+	if r.Status != run.StatusSuccess {
+		t.Errorf("expected run at HEAD to succeed, got status %s", r.Status)
+	}
+	if !r.GitCommit.Valid || len(r.GitCommit.String) != 40 {
+		t.Errorf("expected full commit SHA to be recorded, got %q", r.GitCommit.String)
+	}
+	if r.GitRev.String != "HEAD" {
+		t.Errorf("expected revision HEAD to be recorded, got %q", r.GitRev.String)
+	}
+	if !r.GitDirty.Bool {
+		t.Error("expected dirty working tree to be recorded")
+	}
+}
+
 func testLogs(t *testing.T, fs *helpers.TestFS) {
 	// First run a workflow
 	cmd := newCmd(fs, "run", "simple")
@@ -431,6 +650,201 @@ func testRuns(t *testing.T, fs *helpers.TestFS) {
 	}
 }
 
+// testShow tests the run detail view of the show command.
+func testShow(t *testing.T, fs *helpers.TestFS) {
+	cmd := newCmd(fs, "run", "multi", "--only", "test")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("run command failed: %v\noutput: %s", err, string(output))
+	}
+
+	store, err := run.NewStore(fs.Path("test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	runs, err := store.ListRuns("multi", "", 1, 0)
+	store.Close()
+	if err != nil || len(runs) == 0 {
+		t.Fatal("no runs found to show")
+	}
+	runID := runs[0].ID
+
+	cmd = newCmd(fs, "show", runID)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("show command failed: %v\noutput: %s", err, string(output))
+	}
+
+	for _, want := range []string{"Status:    ✓ success", "Host:", "TASK", "EXIT CODE", "- skipped", "- build\n└── ✓ test\n    └── - deploy"} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("expected %q in show output, got:\n%s", want, string(output))
+		}
+	}
+
+	// Tasks are listed in execution order
+	build, test, deploy := strings.Index(string(output), "build  "), strings.Index(string(output), "test  "), strings.Index(string(output), "deploy  ")
+	if build < 0 || test < build || deploy < test {
+		t.Errorf("expected tasks in execution order, got:\n%s", string(output))
+	}
+
+	cmd = newCmd(fs, "show", runID, "--json")
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("show --json command failed: %v", err)
+	}
+
+	var detail struct {
+		Run struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"run"`
+		Tasks []struct {
+			Name     string `json:"name"`
+			Status   string `json:"status"`
+			Attempts int    `json:"attempts"`
+			ExitCode *int64 `json:"exit_code"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(output, &detail); err != nil {
+		t.Fatalf("show --json output is not JSON: %v\noutput: %s", err, string(output))
+	}
+	if detail.Run.ID != runID || detail.Run.Status != "success" {
+		t.Errorf("unexpected run in JSON output: %+v", detail.Run)
+	}
+
+	var got []string
+	for _, task := range detail.Tasks {
+		got = append(got, task.Name+":"+task.Status)
+	}
+	if want := "build:skipped test:success deploy:skipped"; strings.Join(got, " ") != want {
+		t.Errorf("expected tasks %q, got %q", want, strings.Join(got, " "))
+	}
+	if len(detail.Tasks) == 3 && (detail.Tasks[1].Attempts != 1 || detail.Tasks[1].ExitCode == nil || *detail.Tasks[1].ExitCode != 0) {
+		t.Errorf("unexpected test task in JSON output: %+v", detail.Tasks[1])
+	}
+
+	cmd = newCmd(fs, "show", "missing-run")
+	if _, err := cmd.CombinedOutput(); err == nil {
+		t.Error("expected show of an unknown run to fail")
+	}
+
+	// Runs can be referred to by a unique prefix of their ID or by an alias
+	for _, ref := range []string{runID[:8], "last", "last-success", "multi@latest"} {
+		cmd = newCmd(fs, "show", ref)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("show %s command failed: %v\noutput: %s", ref, err, string(output))
+		}
+		if !strings.Contains(string(output), runID) {
+			t.Errorf("expected show %s to resolve to run %s, got:\n%s", ref, runID, string(output))
+		}
+	}
+}
+
+// testStats tests the run statistics of the stats command.
+func testStats(t *testing.T, fs *helpers.TestFS) {
+	// multi has run once, with only its test task selected
+	cmd := newCmd(fs, "stats", "multi")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("stats command failed: %v\noutput: %s", err, string(output))
+	}
+	for _, want := range []string{"Success rate: 100.0%", "Trend:", "P95", "test", "No task needed a retry"} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("expected %q in stats output, got:\n%s", want, string(output))
+		}
+	}
+
+	cmd = newCmd(fs, "stats", "multi", "--last", "1", "--json")
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("stats --json command failed: %v", err)
+	}
+
+	var stats struct {
+		Runs  int `json:"runs"`
+		Tasks []struct {
+			Name string `json:"name"`
+			Runs int    `json:"runs"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(output, &stats); err != nil {
+		t.Fatalf("stats --json output is not JSON: %v\noutput: %s", err, string(output))
+	}
+	if stats.Runs != 1 || len(stats.Tasks) != 1 || stats.Tasks[0].Name != "test" || stats.Tasks[0].Runs != 1 {
+		t.Errorf("expected statistics of the run of the test task, got %+v", stats)
+	}
+
+	cmd = newCmd(fs, "stats", "unknown")
+	output, err = cmd.CombinedOutput()
+	if err != nil || !strings.Contains(string(output), "No finished runs") {
+		t.Errorf("expected no runs for an unknown workflow, got %v: %s", err, string(output))
+	}
+}
+
+// testPrune tests the prune command and automatic retention after a run.
+func testPrune(t *testing.T, fs *helpers.TestFS) {
+	for i := 0; i < 2; i++ {
+		if output, err := newCmd(fs, "run", "simple").CombinedOutput(); err != nil {
+			t.Fatalf("run command failed: %v\noutput: %s", err, string(output))
+		}
+	}
+
+	countRuns := func() int {
+		store, err := run.NewStore(fs.Path("test.db"))
+		if err != nil {
+			t.Fatalf("failed to open store: %v", err)
+		}
+		defer store.Close()
+
+		runs, err := store.ListRuns("simple", "", -1, 0)
+		if err != nil {
+			t.Fatalf("failed to list runs: %v", err)
+		}
+		return len(runs)
+	}
+	before := countRuns()
+
+	output, err := newCmd(fs, "prune", "--keep-last", "2", "--workflow", "simple", "--dry-run").CombinedOutput()
+	if err != nil {
+		t.Fatalf("prune --dry-run failed: %v\noutput: %s", err, string(output))
+	}
+	if !strings.Contains(string(output), "Would prune") {
+		t.Errorf("expected a dry-run summary, got: %s", string(output))
+	}
+	if got := countRuns(); got != before {
+		t.Fatalf("dry run deleted runs: %d before, %d after", before, got)
+	}
+
+	output, err = newCmd(fs, "prune", "--keep-last", "2", "--workflow", "simple").CombinedOutput()
+	if err != nil {
+		t.Fatalf("prune failed: %v\noutput: %s", err, string(output))
+	}
+	if !strings.Contains(string(output), "Pruned") || !strings.Contains(string(output), "reclaimed") {
+		t.Errorf("expected a prune summary, got: %s", string(output))
+	}
+	if got := countRuns(); got != 2 {
+		t.Errorf("expected 2 simple runs after pruning, got %d", got)
+	}
+
+	// Retention configured through the environment applies after the run
+	cmd := newCmd(fs, "run", "simple")
+	cmd.Env = append(cmd.Env, "WF_RETENTION_KEEP_LAST=1")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run with retention failed: %v\noutput: %s", err, string(output))
+	}
+	if !strings.Contains(string(output), "Retention: Pruned 2 run(s)") {
+		t.Errorf("expected a retention summary, got: %s", string(output))
+	}
+	if got := countRuns(); got != 1 {
+		t.Errorf("expected 1 simple run after retention, got %d", got)
+	}
+
+	if output, err := newCmd(fs, "prune").CombinedOutput(); err == nil {
+		t.Errorf("expected prune without limits to fail, got: %s", string(output))
+	}
+}
+
 // testResume tests the resume command.
 func testResume(t *testing.T, fs *helpers.TestFS) {
 	// Run a workflow that will fail
@@ -463,10 +877,34 @@ func testResume(t *testing.T, fs *helpers.TestFS) {
 
 	failedRunID := runs[0].ID
 
-	// Resume the workflow
+	// Resuming a changed definition is refused by default
 	cmd = newCmd(fs, "resume", failedRunID)
 	output, err = cmd.CombinedOutput()
 
+	if err == nil {
+		t.Fatalf("expected resume to refuse a changed definition\noutput: %s", string(output))
+	}
+
+	if !strings.Contains(string(output), "definition changed") {
+		t.Errorf("expected definition change error, got: %s", string(output))
+	}
+
+	// The snapshot still shows the definition that failed
+	cmd = newCmd(fs, "show", failedRunID, "--definition")
+	output, err = cmd.CombinedOutput()
+
+	if err != nil {
+		t.Fatalf("show --definition command failed: %v\noutput: %s", err, string(output))
+	}
+
+	if !strings.Contains(string(output), "exit 1") {
+		t.Errorf("expected original definition in snapshot, got: %s", string(output))
+	}
+
+	// Resume the workflow against the fixed definition
+	cmd = newCmd(fs, "resume", failedRunID, "--accept-changes")
+	output, err = cmd.CombinedOutput()
+
 	if err != nil {
 		t.Fatalf("resume command failed: %v\noutput: %s", err, string(output))
 	}
@@ -585,6 +1023,50 @@ paths:
 	}
 }
 
+// testProjectDiscovery tests that project-local workflows are found from a nested directory.
+func testProjectDiscovery(t *testing.T, fs *helpers.TestFS) {
+	setupProject(fs)
+
+	fs.Write("repo/.git/HEAD", "ref: refs/heads/main\n")
+	fs.Write("repo/.workflow/local.toml", `
+name = "local"
+
+[tasks.a]
+cmd = "echo local"
+`)
+	if err := os.MkdirAll(fs.Path("repo", "src", "pkg"), 0755); err != nil {
+		t.Fatalf("failed to create nested directory: %v", err)
+	}
+
+	cmd := newCmd(fs, "list")
+	cmd.Path = fs.Path("wf")
+	cmd.Dir = fs.Path("repo", "src", "pkg")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("list from project failed: %v\noutput: %s", err, string(output))
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "local ") && !strings.Contains(line, "project") {
+			t.Errorf("expected 'local' to be listed in project scope, got: %s", line)
+		}
+	}
+	if !strings.Contains(string(output), "local") {
+		t.Errorf("expected project workflow in list output, got: %s", string(output))
+	}
+	if !strings.Contains(string(output), "global") {
+		t.Errorf("expected global workflows in list output, got: %s", string(output))
+	}
+
+	cmd = newCmd(fs, "run", "local")
+	cmd.Path = fs.Path("wf")
+	cmd.Dir = fs.Path("repo", "src", "pkg")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run project workflow failed: %v\noutput: %s", err, string(output))
+	}
+}
+
 // newCmd creates a new command with proper environment.
 func newCmd(fs *helpers.TestFS, args ...string) *exec.Cmd {
 	binary := "./wf"
@@ -3,6 +3,12 @@ package integration
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
@@ -342,3 +348,561 @@ func TestExecutorIntegrationContextCancellation(t *testing.T) {
 		}
 	}
 }
+
+// TestExecutorIntegrationResumeChangedDefinition tests the resume policies when the definition changed after a failure.
+func TestExecutorIntegrationResumeChangedDefinition(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	fs.Write("resume.toml", helpers.ResumeWorkflow())
+	d, err := dag.LoadFile(fs.Path("resume.toml"))
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+
+	ex := executor.NewExecutor(store)
+	if err := ex.Run(context.Background(), d); err == nil {
+		t.Fatal("expected initial run to fail")
+	}
+
+	runs, err := store.ListRuns(d.Name, "", 10, 0)
+	if err != nil || len(runs) == 0 {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	runID := runs[0].ID
+
+	// Fix the workflow on disk
+	fs.Write("resume.toml", helpers.ResumeWorkflowFixed())
+
+	// Default policy refuses the changed definition
+	wr, _ := store.Load(runID)
+	err = ex.Resume(context.Background(), wr)
+	var changed *executor.DefinitionChangedError
+	if !errors.As(err, &changed) {
+		t.Fatalf("expected DefinitionChangedError, got %v", err)
+	}
+
+	// The snapshot still contains the failing command
+	ex.ResumePolicy = executor.ResumeFromSnapshot
+	wr, _ = store.Load(runID)
+	if err := ex.Resume(context.Background(), wr); err == nil {
+		t.Fatal("expected resume against the snapshot to fail again")
+	}
+
+	// Accepting the change resumes against the fixed definition
+	ex.ResumePolicy = executor.ResumeAcceptChanges
+	wr, _ = store.Load(runID)
+	if err := ex.Resume(context.Background(), wr); err != nil {
+		t.Fatalf("expected resume with accepted changes to succeed, got %v", err)
+	}
+
+	wr, err = store.Load(runID)
+	if err != nil {
+		t.Fatalf("failed to load run: %v", err)
+	}
+	if wr.Status != run.StatusSuccess {
+		t.Errorf("expected status %s, got %s", run.StatusSuccess, wr.Status)
+	}
+
+	fixed, _ := dag.LoadFile(fs.Path("resume.toml"))
+	fixedHash, _ := fixed.ComputeHash()
+	if wr.WorkflowHash != fixedHash {
+		t.Error("expected run hash to be updated to the accepted definition")
+	}
+}
+
+// TestExecutorIntegrationSelection tests that only the selected subgraph is executed and the selection is recorded.
+func TestExecutorIntegrationSelection(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	d, err := dag.LoadFromString(helpers.ComplexWorkflow())
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+
+	ex := executor.NewExecutor(store)
+	ex.Selection = dag.Selection{From: []string{"b"}}
+	if err := ex.Run(context.Background(), d); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	runs, err := store.ListRuns(d.Name, "", 10, 0)
+	if err != nil || len(runs) == 0 {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+
+	wr := runs[0]
+	if !wr.Selection.Valid {
+		t.Error("expected selection to be recorded on the run")
+	}
+
+	fullHash, _ := d.ComputeHash()
+	if wr.WorkflowHash != fullHash {
+		t.Error("expected run hash to cover the full definition")
+	}
+
+	tasks, err := store.LoadTaskRuns(wr.ID)
+	if err != nil {
+		t.Fatalf("failed to load task runs: %v", err)
+	}
+
+	var names []string
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	if strings.Join(names, ",") != "b,d" {
+		t.Errorf("expected tasks b,d to run, got %v", names)
+	}
+
+	ex.Selection = dag.Selection{Only: []string{"missing"}}
+	if err := ex.Run(context.Background(), d); err == nil {
+		t.Error("expected error for unknown task in selection")
+	}
+}
+
+// TestExecutorIntegrationRerun tests that a task and its downstream can be rerun within a completed run.
+func TestExecutorIntegrationRerun(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	d, err := dag.LoadFromString(helpers.ComplexWorkflow())
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+
+	ex := executor.NewExecutor(store)
+	if err := ex.Run(context.Background(), d); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	runs, err := store.ListRuns(d.Name, "", 10, 0)
+	if err != nil || len(runs) == 0 {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	wr := runs[0]
+
+	if err := ex.Rerun(context.Background(), wr, "b", true); err != nil {
+		t.Fatalf("expected rerun to succeed, got %v", err)
+	}
+
+	wr, err = store.Load(wr.ID)
+	if err != nil {
+		t.Fatalf("failed to load run: %v", err)
+	}
+	if wr.Status != run.StatusSuccess {
+		t.Errorf("expected status %s, got %s", run.StatusSuccess, wr.Status)
+	}
+
+	history, err := store.LoadTaskRunHistory(wr.ID)
+	if err != nil {
+		t.Fatalf("failed to load task run history: %v", err)
+	}
+
+	executions := map[string]int{}
+	for _, tr := range history {
+		executions[tr.Name]++
+	}
+	for name, want := range map[string]int{"a": 1, "b": 2, "c": 1, "d": 2} {
+		if executions[name] != want {
+			t.Errorf("expected task %s to have %d task runs, got %d", name, want, executions[name])
+		}
+	}
+
+	b, err := store.GetTaskRun(wr.ID, "b")
+	if err != nil {
+		t.Fatalf("failed to load task run: %v", err)
+	}
+	if b.Status != run.TaskSuccess || b.Attempts != 2 {
+		t.Errorf("expected rerun of b to succeed on attempt 2, got %s after %d attempts", b.Status, b.Attempts)
+	}
+
+	if err := ex.Rerun(context.Background(), wr, "missing", false); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
+
+// TestExecutorIntegrationRerunFailedRun tests that rerunning some tasks of a failed run
+// leaves it failed while other tasks have not succeeded.
+func TestExecutorIntegrationRerunFailedRun(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	marker := fs.Path("fixed")
+	d, err := dag.LoadFromString(fmt.Sprintf(`
+name = "partial"
+
+[tasks.a]
+cmd = "echo a"
+
+[tasks.b]
+cmd = "test -f %s"
+depends_on = ["a"]
+
+[tasks.c]
+cmd = "echo c"
+depends_on = ["b"]
+`, marker))
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+
+	ex := executor.NewExecutor(store)
+	if err := ex.Run(context.Background(), d); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	runs, err := store.ListRuns(d.Name, "", 1, 0)
+	if err != nil || len(runs) == 0 {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	wr := runs[0]
+
+	// Rerunning a leaves b failed and c pending, so the run stays failed
+	if err := ex.Rerun(context.Background(), wr, "a", false); err != nil {
+		t.Fatalf("expected rerun to succeed, got %v", err)
+	}
+	if wr, err = store.Load(wr.ID); err != nil {
+		t.Fatalf("failed to load run: %v", err)
+	}
+	if wr.Status != run.StatusFailed {
+		t.Errorf("expected status %s after rerunning a, got %s", run.StatusFailed, wr.Status)
+	}
+
+	// Once b and everything downstream of it succeed, so does the run
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+	if err := ex.Rerun(context.Background(), wr, "b", true); err != nil {
+		t.Fatalf("expected rerun to succeed, got %v", err)
+	}
+	if wr, err = store.Load(wr.ID); err != nil {
+		t.Fatalf("failed to load run: %v", err)
+	}
+	if wr.Status != run.StatusSuccess {
+		t.Errorf("expected status %s after rerunning b downstream, got %s", run.StatusSuccess, wr.Status)
+	}
+}
+
+// TestExecutorIntegrationResumeInterrupted tests that a run left running by a dead process can be marked interrupted and resumed.
+func TestExecutorIntegrationResumeInterrupted(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	d, err := dag.LoadFromString(helpers.ComplexWorkflow())
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+	hash, err := d.ComputeHash()
+	if err != nil {
+		t.Fatalf("failed to hash workflow: %v", err)
+	}
+	snapshot, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot workflow: %v", err)
+	}
+
+	// Simulate a run whose process died while task b was executing
+	crashed := exec.Command("true")
+	if err := crashed.Run(); err != nil {
+		t.Skipf("cannot start a process: %v", err)
+	}
+	host, _ := os.Hostname()
+	wr := &run.WorkflowRun{
+		Workflow:     d.Name,
+		WorkflowHash: hash,
+		Source:       sql.NullString{String: dag.StdinSource, Valid: true},
+		Definition:   sql.NullString{String: string(snapshot), Valid: true},
+		PID:          sql.NullInt64{Int64: int64(crashed.ProcessState.Pid()), Valid: true},
+		Host:         sql.NullString{String: host, Valid: true},
+		HeartbeatAt:  sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	if err := store.CreateWorkflowRun(wr); err != nil {
+		t.Fatalf("failed to create run: %v", err)
+	}
+	for name, status := range map[string]run.TaskStatus{"a": run.TaskSuccess, "b": run.TaskRunning} {
+		if err := store.SaveTaskRun(&run.TaskRun{RunID: wr.ID, Name: name, Status: status, StartedAt: time.Now(), Attempts: 1}); err != nil {
+			t.Fatalf("failed to save task run: %v", err)
+		}
+	}
+
+	stale, err := store.ListStaleRuns(time.Minute)
+	if err != nil {
+		t.Fatalf("failed to list stale runs: %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != wr.ID {
+		t.Fatalf("expected run %s to be stale, got %d stale runs", wr.ID, len(stale))
+	}
+	if err := store.MarkInterrupted(stale[0]); err != nil {
+		t.Fatalf("failed to mark run interrupted: %v", err)
+	}
+
+	ex := executor.NewExecutor(store)
+	ex.HeartbeatInterval = 10 * time.Millisecond
+	if err := ex.Resume(context.Background(), stale[0]); err != nil {
+		t.Fatalf("expected resume to succeed, got %v", err)
+	}
+
+	wr, err = store.Load(wr.ID)
+	if err != nil {
+		t.Fatalf("failed to load run: %v", err)
+	}
+	if wr.Status != run.StatusSuccess {
+		t.Errorf("expected status %s, got %s", run.StatusSuccess, wr.Status)
+	}
+	if wr.PID.Int64 != int64(os.Getpid()) {
+		t.Errorf("expected resumed run to be claimed by pid %d, got %d", os.Getpid(), wr.PID.Int64)
+	}
+
+	tasks, err := store.LoadTaskRuns(wr.ID)
+	if err != nil {
+		t.Fatalf("failed to load task runs: %v", err)
+	}
+	for _, tr := range tasks {
+		if tr.Status != run.TaskSuccess {
+			t.Errorf("task %s: expected status %s, got %s", tr.Name, run.TaskSuccess, tr.Status)
+		}
+		if tr.Name == "a" && tr.Attempts != 1 {
+			t.Errorf("expected completed task a to be skipped, got %d attempts", tr.Attempts)
+		}
+		if tr.Name == "b" && tr.Attempts != 2 {
+			t.Errorf("expected interrupted task b to run again, got %d attempts", tr.Attempts)
+		}
+	}
+}
+
+// TestExecutorIntegrationConcurrency tests that forbid refuses and queue waits while another run holds the workflow lock.
+func TestExecutorIntegrationConcurrency(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	d, err := dag.LoadFromString(helpers.SimpleWorkflow())
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+
+	lockName := run.WorkflowLockName(d.QualifiedName())
+	if _, err := store.AcquireLock(lockName, "holder", time.Minute); err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+
+	ex := executor.NewExecutor(store)
+	ex.LockPollInterval = 10 * time.Millisecond
+
+	t.Run("forbid", func(t *testing.T) {
+		d.Concurrency = dag.ConcurrencyForbid
+		err := ex.Run(context.Background(), d)
+
+		var held *run.LockHeldError
+		if !errors.As(err, &held) {
+			t.Fatalf("expected LockHeldError, got %v", err)
+		}
+		if held.Lock.RunID != "holder" {
+			t.Errorf("expected error to name run holder, got %s", held.Lock.RunID)
+		}
+
+		runs, _ := store.ListRuns(d.Name, "", 10, 0)
+		if len(runs) != 0 {
+			t.Errorf("expected a forbidden run not to be recorded, got %d runs", len(runs))
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		d.Concurrency = dag.ConcurrencyAllow
+		if err := ex.Run(context.Background(), d); err != nil {
+			t.Fatalf("expected run to ignore the workflow lock, got %v", err)
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		d.Concurrency = dag.ConcurrencyQueue
+
+		done := make(chan error, 1)
+		go func() {
+			done <- ex.Run(context.Background(), d)
+		}()
+
+		select {
+		case err := <-done:
+			t.Fatalf("expected queued run to wait for the lock, finished with %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if err := store.ReleaseLock(lockName, "holder"); err != nil {
+			t.Fatalf("failed to release lock: %v", err)
+		}
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("expected queued run to succeed, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued run did not start after the lock was released")
+		}
+
+		if l, err := store.GetLock(lockName); err != nil || l != nil {
+			t.Errorf("expected workflow lock to be released after the run, got %+v (%v)", l, err)
+		}
+	})
+}
+
+// TestExecutorIntegrationLockLost tests that a run stops when its lock is taken over,
+// rather than execute alongside the run that took it.
+func TestExecutorIntegrationLockLost(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	d, err := dag.LoadFromString(`
+name = "lost"
+concurrency = "forbid"
+
+[tasks.slow]
+cmd = "sleep 10"
+`)
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+
+	ex := executor.NewExecutor(store)
+	ex.HeartbeatInterval = 20 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ex.Run(context.Background(), d)
+	}()
+
+	var runs []*run.WorkflowRun
+	for deadline := time.Now().Add(5 * time.Second); len(runs) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		runs, _ = store.ListRuns(d.Name, "", 1, 0)
+	}
+	if len(runs) == 0 {
+		t.Fatal("run was not recorded")
+	}
+
+	// Take the workflow lock over, as a run on another host would after the lease expired
+	lockName := run.WorkflowLockName(d.QualifiedName())
+	if err := store.ReleaseLock(lockName, runs[0].ID); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+	if _, err := store.AcquireLock(lockName, "intruder", time.Minute); err != nil {
+		t.Fatalf("failed to take the lock over: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, run.ErrLockLost) {
+			t.Errorf("expected the run to stop with ErrLockLost, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run kept going after losing its lock")
+	}
+
+	wr, err := store.Load(runs[0].ID)
+	if err != nil {
+		t.Fatalf("failed to load run: %v", err)
+	}
+	if wr.Status == run.StatusSuccess || wr.Status == run.StatusRunning {
+		t.Errorf("expected the run to end unsuccessfully, got %s", wr.Status)
+	}
+}
+
+// TestExecutorIntegrationRunName tests that a workflow loaded by name records its runs
+// under the path-derived name listed by wf list, even when the file declares another name.
+func TestExecutorIntegrationRunName(t *testing.T) {
+	fs := helpers.NewTestFS(t)
+	defer fs.Cleanup()
+
+	config.C.Paths.Logs = fs.Path("logs")
+	config.C.Paths.Database = fs.Path("test.db")
+	config.C.Paths.Workflows = fs.Path("workflows")
+
+	fs.Write("workflows/etl/daily.toml", "name = \"nightly\"\n\n[tasks.a]\ncmd = \"echo a\"\n")
+
+	store, err := run.NewStore(config.C.Paths.Database)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	d, err := dag.Load("etl/daily")
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+
+	if err := executor.NewExecutor(store).Run(context.Background(), d); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	runs, err := store.ListRuns("etl/daily", "", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("expected the run to be recorded as etl/daily, got %d runs", len(runs))
+	}
+}